@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jihaia/aperture/apis/cmdb/store"
+	migrations "github.com/jihaia/aperture/packages/migrations"
+)
+
+type server struct {
+	db     *sql.DB
+	dbPath string
+}
+
+type statement struct {
+	SQL    string `json:"sql"`
+	Params []any  `json:"params"`
+}
+
+type executeResult struct {
+	Changes         int64 `json:"changes"`
+	LastInsertRowid int64 `json:"lastInsertRowid"`
+}
+
+// handle decodes one request and dispatches it by action.
+func (s *server) handle(msg []byte) *response {
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return failure("", "BAD_REQUEST", err.Error())
+	}
+	ctx := context.Background()
+
+	var data any
+	var err error
+	switch req.Action {
+	case "ping":
+		data = map[string]any{"pong": true, "dbPath": s.dbPath}
+	case "query":
+		data, err = s.query(ctx, req.Params)
+	case "execute":
+		data, err = s.execute(ctx, req.Params)
+	case "executeBatch":
+		data, err = s.executeBatch(ctx, req.Params)
+	case "seed":
+		data, err = s.seed(ctx, req.Params)
+	case "getSchema":
+		data, err = s.schema(ctx)
+	case "getMigrations":
+		data, err = s.listMigrations()
+	default:
+		return failure(req.ID, "UNKNOWN_ACTION", "unknown action: "+req.Action)
+	}
+
+	var be badRequest
+	if errors.As(err, &be) {
+		return failure(req.ID, "BAD_REQUEST", err.Error())
+	}
+	if err != nil {
+		return failure(req.ID, "SQLITE_ERROR", err.Error())
+	}
+	return success(req.ID, data)
+}
+
+// badRequest marks errors caused by the request rather than the database.
+type badRequest struct{ msg string }
+
+func (e badRequest) Error() string { return e.msg }
+
+// decodeParams unmarshals params keeping numbers exact, so integer
+// arguments bind as INTEGER rather than REAL.
+func decodeParams(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		raw = []byte("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return badRequest{"invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+// bindArgs converts decoded JSON values into driver arguments. Objects
+// and arrays are stored as JSON text.
+func bindArgs(params []any) []any {
+	args := make([]any, len(params))
+	for i, p := range params {
+		args[i] = bindValue(p)
+	}
+	return args
+}
+
+func bindValue(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]any, []any:
+		b, _ := json.Marshal(t)
+		return string(b)
+	default:
+		return t
+	}
+}
+
+func (s *server) query(ctx context.Context, raw json.RawMessage) (any, error) {
+	var p statement
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(p.SQL) == "" {
+		return nil, badRequest{"sql is required"}
+	}
+
+	rows, err := s.db.QueryContext(ctx, p.SQL, bindArgs(p.Params)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	results := []map[string]any{}
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(cols))
+		for i, col := range cols {
+			if b, ok := vals[i].([]byte); ok {
+				vals[i] = string(b)
+			}
+			row[col] = vals[i]
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return map[string]any{"rows": results, "count": len(results)}, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func execStatement(ctx context.Context, db execer, st statement) (executeResult, error) {
+	if strings.TrimSpace(st.SQL) == "" {
+		return executeResult{}, badRequest{"sql is required"}
+	}
+	res, err := db.ExecContext(ctx, st.SQL, bindArgs(st.Params)...)
+	if err != nil {
+		return executeResult{}, err
+	}
+	changes, _ := res.RowsAffected()
+	lastID, _ := res.LastInsertId()
+	return executeResult{Changes: changes, LastInsertRowid: lastID}, nil
+}
+
+func (s *server) execute(ctx context.Context, raw json.RawMessage) (any, error) {
+	var p statement
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, err
+	}
+	return execStatement(ctx, s.db, p)
+}
+
+// executeBatch runs every statement in one transaction; the first
+// failure rolls back the whole batch.
+func (s *server) executeBatch(ctx context.Context, raw json.RawMessage) (any, error) {
+	var p struct {
+		Statements []statement `json:"statements"`
+	}
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]executeResult, 0, len(p.Statements))
+	for i, st := range p.Statements {
+		r, err := execStatement(ctx, tx, st)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %w", i, err)
+		}
+		results = append(results, r)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return map[string]any{"results": results}, nil
+}
+
+// seed inserts rows into a table in one transaction. Table and column
+// names are checked against the schema since they cannot be bound as
+// parameters. With upsert, a row whose primary key exists is updated in
+// place: INSERT OR REPLACE would delete it first and cascade the delete
+// to every row beneath it.
+//
+// Seeded rows get what the store gives its own writes: vocabulary
+// columns and workload addresses are stored in canonical form, and rows
+// of the hierarchy and workloads are recorded in the audit log with the
+// native host as actor.
+func (s *server) seed(ctx context.Context, raw json.RawMessage) (any, error) {
+	var p struct {
+		Table  string           `json:"table"`
+		Rows   []map[string]any `json:"rows"`
+		Upsert bool             `json:"upsert"`
+	}
+	if err := decodeParams(raw, &p); err != nil {
+		return nil, err
+	}
+
+	columns, pk, err := tableColumns(ctx, s.db, p.Table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, badRequest{"unknown table: " + p.Table}
+	}
+	if p.Upsert && len(pk) == 0 {
+		return nil, badRequest{"cannot upsert into " + p.Table + ": it has no primary key"}
+	}
+	entity, audited := store.AuditedEntity(p.Table)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	canon := store.NewCanonicalizer(tx)
+	audit := store.New(tx).Audit
+	by := "native-host"
+
+	inserted := 0
+	for i, row := range p.Rows {
+		cols := make([]string, 0, len(row))
+		for col := range row {
+			if !columns[col] {
+				return nil, badRequest{fmt.Sprintf("row %d: unknown column %s.%s", i, p.Table, col)}
+			}
+			cols = append(cols, col)
+		}
+		if len(cols) == 0 {
+			continue
+		}
+		sort.Strings(cols)
+
+		args := make([]any, len(cols))
+		for j, col := range cols {
+			args[j] = bindValue(row[col])
+			if v, ok := args[j].(string); ok {
+				c, err := canon.Canonical(ctx, p.Table, col, &v)
+				var ve *store.VocabularyError
+				if errors.As(err, &ve) {
+					return nil, badRequest{fmt.Sprintf("row %d: %v", i, err)}
+				}
+				if err != nil {
+					return nil, fmt.Errorf("row %d: %w", i, err)
+				}
+				args[j] = c
+			}
+		}
+
+		// Only a row naming its id can be audited; the id of one left to
+		// the table's default is not known here.
+		var id string
+		if len(pk) == 1 {
+			id, _ = row[pk[0]].(string)
+		}
+		var before any
+		if audited && id != "" {
+			if before, err = store.Snapshot(ctx, tx, entity, id); errors.Is(err, store.ErrNotFound) {
+				before = nil
+			} else if err != nil {
+				return nil, fmt.Errorf("row %d: %w", i, err)
+			}
+		}
+
+		query := fmt.Sprintf(`INSERT INTO "%s" ("%s") VALUES (%s)`,
+			p.Table, strings.Join(cols, `", "`), strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", "))
+		if p.Upsert {
+			query += onConflict(cols, pk)
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		inserted++
+
+		if audited && id != "" {
+			e, err := store.Written(ctx, tx, entity, id, before)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", i, err)
+			}
+			if e != nil {
+				e.Actor = &by
+				if err := audit.Record(ctx, e); err != nil {
+					return nil, fmt.Errorf("row %d: %w", i, err)
+				}
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return map[string]any{"inserted": inserted}, nil
+}
+
+// onConflict is the upsert clause updating the row's non-key columns
+// from the values given, leaving the ones not given as they are.
+func onConflict(cols, pk []string) string {
+	key := map[string]bool{}
+	for _, c := range pk {
+		key[c] = true
+	}
+	var sets []string
+	for _, c := range cols {
+		if !key[c] {
+			sets = append(sets, fmt.Sprintf(`"%s" = excluded."%s"`, c, c))
+		}
+	}
+	target := `("` + strings.Join(pk, `", "`) + `")`
+	if len(sets) == 0 {
+		return " ON CONFLICT " + target + " DO NOTHING"
+	}
+	return " ON CONFLICT " + target + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// tableColumns returns the column names of a table, or none if the
+// table does not exist, and its primary key columns in key order.
+func tableColumns(ctx context.Context, db *sql.DB, table string) (map[string]bool, []string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name, pk FROM pragma_table_info(?) ORDER BY pk", table)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	cols := map[string]bool{}
+	var pk []string
+	for rows.Next() {
+		var name string
+		var n int
+		if err := rows.Scan(&name, &n); err != nil {
+			return nil, nil, err
+		}
+		cols[name] = true
+		if n > 0 {
+			pk = append(pk, name)
+		}
+	}
+	return cols, pk, rows.Err()
+}
+
+func (s *server) schema(ctx context.Context) (any, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT name, sql FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type table struct {
+		Name string `json:"name"`
+		SQL  string `json:"sql"`
+	}
+	tables := []table{}
+	for rows.Next() {
+		var t table
+		if err := rows.Scan(&t.Name, &t.SQL); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return map[string]any{"tables": tables}, rows.Err()
+}
+
+func (s *server) listMigrations() (any, error) {
+	list, err := migrations.Status(s.db)
+	if err != nil {
+		return nil, err
+	}
+	type migration struct {
+		Name      string `json:"name"`
+		AppliedAt string `json:"applied_at"`
+	}
+	out := make([]migration, len(list))
+	for i, m := range list {
+		out[i] = migration{Name: m.Name, AppliedAt: m.AppliedAt}
+	}
+	return map[string]any{"migrations": out}, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	migrations "github.com/jihaia/aperture/packages/migrations"
+	_ "modernc.org/sqlite"
+)
+
+var testDBs atomic.Int64
+
+// testServer returns a server on a fresh, fully migrated in-memory
+// database.
+func testServer(t *testing.T) *server {
+	t.Helper()
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:host%d?mode=memory&_pragma=foreign_keys(ON)", testDBs.Add(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrations.Output = io.Discard
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return &server{db: db, dbPath: ":memory:"}
+}
+
+// call sends one request and returns the response as the extension sees
+// it, after a JSON round trip.
+func call(t *testing.T, s *server, action string, params any) (data map[string]any, code string) {
+	t.Helper()
+	p, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := json.Marshal(request{ID: "1", Action: action, Params: p})
+	body, _ := json.Marshal(s.handle(msg))
+	var resp struct {
+		Data  map[string]any
+		Error *respError
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != nil {
+		return nil, resp.Error.Code
+	}
+	return resp.Data, ""
+}
+
+func count(t *testing.T, s *server, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := s.db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func seedRows(t *testing.T, s *server, table string, upsert bool, rows ...map[string]any) {
+	t.Helper()
+	if _, code := call(t, s, "seed", map[string]any{"table": table, "rows": rows, "upsert": upsert}); code != "" {
+		t.Fatalf("seed %s: %s", table, code)
+	}
+}
+
+// TestSeedUpsertKeepsChildren re-seeds a portfolio the way the extension's
+// sync does and checks nothing beneath it goes: INSERT OR REPLACE deleted
+// the row first, cascading to every asset, application, component and
+// workload link under it.
+func TestSeedUpsertKeepsChildren(t *testing.T) {
+	s := testServer(t)
+	seedRows(t, s, "portfolios", true, map[string]any{"portfolio_id": "p1", "name": "Payments", "description": "cards"})
+	seedRows(t, s, "assets", true, map[string]any{"asset_id": "a1", "name": "Ledger", "portfolio_id": "p1"})
+	seedRows(t, s, "app_groupings", false, map[string]any{"app_grouping_id": "g1", "name": "Core", "asset_id": "a1"})
+	seedRows(t, s, "applications", false, map[string]any{"application_id": "ap1", "name": "ledger-api", "app_grouping_id": "g1"})
+	seedRows(t, s, "components", false, map[string]any{"component_id": "c1", "name": "api", "application_id": "ap1"})
+	seedRows(t, s, "workloads", true, map[string]any{"workload_id": "w1", "hostname": "ledger01"})
+	seedRows(t, s, "component_workloads", true, map[string]any{"component_id": "c1", "workload_id": "w1"})
+
+	seedRows(t, s, "portfolios", true, map[string]any{"portfolio_id": "p1", "name": "Payments & Cards"})
+	seedRows(t, s, "assets", true, map[string]any{"asset_id": "a1", "name": "General Ledger", "portfolio_id": "p1"})
+	seedRows(t, s, "component_workloads", true, map[string]any{"component_id": "c1", "workload_id": "w1"})
+
+	for _, table := range []string{"assets", "app_groupings", "applications", "components", "component_workloads"} {
+		if n := count(t, s, "SELECT COUNT(*) FROM "+table); n != 1 {
+			t.Errorf("%s: %d rows after re-seeding, want 1", table, n)
+		}
+	}
+	var name, description string
+	if err := s.db.QueryRow("SELECT name, description FROM portfolios WHERE portfolio_id = 'p1'").Scan(&name, &description); err != nil {
+		t.Fatal(err)
+	}
+	// Columns the row leaves out keep their value.
+	if name != "Payments & Cards" || description != "cards" {
+		t.Errorf("portfolio = %q, %q; want the new name and the old description", name, description)
+	}
+}
+
+func TestSeedVocabularyAndAudit(t *testing.T) {
+	s := testServer(t)
+	seedRows(t, s, "portfolios", false, map[string]any{"portfolio_id": "p1", "name": "Payments"})
+	seedRows(t, s, "assets", true, map[string]any{"asset_id": "a1", "name": "Ledger", "portfolio_id": "p1", "criticality": "1 - most critical", "environment": "Prod"})
+
+	if n := count(t, s, "SELECT COUNT(*) FROM assets WHERE criticality = 'critical' AND environment = 'production'"); n != 1 {
+		t.Error("vocabulary aliases stored as sent")
+	}
+	if _, code := call(t, s, "seed", map[string]any{"table": "assets", "rows": []map[string]any{
+		{"asset_id": "a2", "name": "Loans", "portfolio_id": "p1", "criticality": "severe"},
+	}}); code != "BAD_REQUEST" {
+		t.Errorf("unknown criticality: code %q, want BAD_REQUEST", code)
+	}
+
+	// A rerun that changes nothing records nothing.
+	seedRows(t, s, "assets", true, map[string]any{"asset_id": "a1", "name": "Ledger", "portfolio_id": "p1", "criticality": "critical"})
+	seedRows(t, s, "assets", true, map[string]any{"asset_id": "a1", "name": "General Ledger", "portfolio_id": "p1"})
+
+	rows, err := s.db.Query("SELECT entity, entity_id, action, actor FROM audit_log ORDER BY audit_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var entity, id, action, actor string
+		if err := rows.Scan(&entity, &id, &action, &actor); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s:%s:%s:%s", entity, id, action, actor))
+	}
+	want := "[portfolio:p1:create:native-host asset:a1:create:native-host asset:a1:update:native-host]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("audit log = %v, want %s", got, want)
+	}
+}
+
+func TestActionErrors(t *testing.T) {
+	s := testServer(t)
+	tests := []struct {
+		name, action string
+		params       any
+		code         string
+	}{
+		{"unknown action", "drop", nil, "UNKNOWN_ACTION"},
+		{"bad params", "query", []int{1}, "BAD_REQUEST"},
+		{"empty sql", "execute", statement{SQL: " "}, "BAD_REQUEST"},
+		{"sql error", "query", statement{SQL: "SELECT * FROM nope"}, "SQLITE_ERROR"},
+		{"unknown table", "seed", map[string]any{"table": "nope", "rows": []any{}}, "BAD_REQUEST"},
+		{"unknown column", "seed", map[string]any{"table": "portfolios", "rows": []map[string]any{{"nope": 1}}}, "BAD_REQUEST"},
+		// Seeding must not be a way to interpolate SQL through a name.
+		{"quoted table", "seed", map[string]any{"table": `portfolios" --`, "rows": []any{}}, "BAD_REQUEST"},
+	}
+	for _, tt := range tests {
+		if _, code := call(t, s, tt.action, tt.params); code != tt.code {
+			t.Errorf("%s: code %q, want %s", tt.name, code, tt.code)
+		}
+	}
+}
+
+func TestExecuteBatch(t *testing.T) {
+	s := testServer(t)
+	insert := "INSERT INTO portfolios (portfolio_id, name) VALUES (?, ?)"
+	data, code := call(t, s, "executeBatch", map[string]any{"statements": []statement{
+		{SQL: insert, Params: []any{"p1", "Payments"}},
+		{SQL: insert, Params: []any{"p2", "Lending"}},
+	}})
+	if code != "" || len(data["results"].([]any)) != 2 {
+		t.Fatalf("batch: %v %s", data, code)
+	}
+
+	// The failing statement rolls back the one before it.
+	_, code = call(t, s, "executeBatch", map[string]any{"statements": []statement{
+		{SQL: insert, Params: []any{"p3", "Cards"}},
+		{SQL: insert, Params: []any{"p1", "Payments"}},
+	}})
+	if code != "SQLITE_ERROR" || count(t, s, "SELECT COUNT(*) FROM portfolios") != 2 {
+		t.Errorf("failed batch: code %q, %d portfolios; want SQLITE_ERROR and 2", code, count(t, s, "SELECT COUNT(*) FROM portfolios"))
+	}
+
+	// Integers bind as INTEGER, objects as JSON text.
+	data, code = call(t, s, "query", statement{SQL: "SELECT typeof(?) AS n, ? AS o", Params: []any{3, map[string]int{"a": 1}}})
+	if code != "" {
+		t.Fatal(code)
+	}
+	row := data["rows"].([]any)[0].(map[string]any)
+	if row["n"] != "integer" || row["o"] != `{"a":1}` {
+		t.Errorf("row = %v", row)
+	}
+}
+
+func TestServe(t *testing.T) {
+	s := testServer(t)
+	var in, out bytes.Buffer
+	for _, msg := range []string{`{"id":"a","action":"ping"}`, `{"id":"b","action":"nope"}`} {
+		writeRaw(&in, msg)
+	}
+	if err := serve(&in, &out, s); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"id":"a","ok":true`, `"id":"b","ok":false`} {
+		msg, err := readMessage(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(msg, []byte(want)) {
+			t.Errorf("response %s, want %s", msg, want)
+		}
+	}
+}
+
+func writeRaw(w io.Writer, msg string) {
+	var n [4]byte
+	n[0], n[1], n[2], n[3] = byte(len(msg)), byte(len(msg)>>8), byte(len(msg)>>16), byte(len(msg)>>24)
+	w.Write(n[:])
+	io.WriteString(w, msg)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const hostName = "com.aperture.db"
+
+// manifestDirs are the per-user native messaging host directories on Linux.
+var manifestDirs = map[string]string{
+	"chrome":   ".config/google-chrome/NativeMessagingHosts",
+	"chromium": ".config/chromium/NativeMessagingHosts",
+	"edge":     ".config/microsoft-edge/NativeMessagingHosts",
+	"brave":    ".config/BraveSoftware/Brave-Browser/NativeMessagingHosts",
+}
+
+type hostManifest struct {
+	Name           string   `json:"name"`
+	Description    string   `json:"description"`
+	Path           string   `json:"path"`
+	Type           string   `json:"type"`
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// install writes the host manifest that tells the browser where to find
+// this binary and which extensions may connect to it.
+func install(args []string) error {
+	fs := flag.NewFlagSet("install", flag.ContinueOnError)
+	extIDs := fs.String("extension-id", "", "extension ID allowed to connect (comma-separated for several)")
+	browser := fs.String("browser", "chrome", "target browser: chrome, chromium, edge or brave")
+	binPath := fs.String("path", "", "absolute path to the host binary (default: this executable)")
+	dir := fs.String("dir", "", "manifest directory (overrides -browser)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *extIDs == "" {
+		return errors.New("-extension-id is required (see chrome://extensions)")
+	}
+	var origins []string
+	for _, id := range strings.Split(*extIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			origins = append(origins, "chrome-extension://"+id+"/")
+		}
+	}
+
+	path := *binPath
+	if path == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("resolve executable: %w", err)
+		}
+		path = exe
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	target := *dir
+	if target == "" {
+		rel, ok := manifestDirs[*browser]
+		if !ok {
+			return fmt.Errorf("unknown browser: %s", *browser)
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("cannot determine home directory: %w", err)
+		}
+		target = filepath.Join(home, rel)
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("create manifest directory: %w", err)
+	}
+
+	body, err := json.MarshalIndent(hostManifest{
+		Name:           hostName,
+		Description:    "Aperture CMDB database host",
+		Path:           path,
+		Type:           "stdio",
+		AllowedOrigins: origins,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(target, hostName+".json")
+	if err := os.WriteFile(file, append(body, '\n'), 0644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	fmt.Printf("Wrote %s\n", file)
+	fmt.Printf("Host: %s\n", path)
+	return nil
+}
@@ -0,0 +1,78 @@
+// Native messaging host for the Aperture extension (com.aperture.db).
+//
+// Chrome starts the host when the extension calls
+// chrome.runtime.connectNative('com.aperture.db') and exchanges
+// length-prefixed JSON messages with it over stdin/stdout. Requests are
+// served against the same SQLite database as the CMDB API, with pending
+// migrations applied at startup.
+//
+// Usage:
+//
+//	native-host                                    # serve (started by Chrome)
+//	native-host install --extension-id <id>        # write the host manifest
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/jihaia/aperture/apis/cmdb/db"
+	migrations "github.com/jihaia/aperture/packages/migrations"
+)
+
+func main() {
+	// stdout carries the protocol; everything else goes to stderr,
+	// which Chrome forwards to its own log.
+	log.SetOutput(os.Stderr)
+	log.SetPrefix("[native-host] ")
+	migrations.Output = os.Stderr
+
+	if len(os.Args) > 1 && os.Args[1] == "install" {
+		if err := install(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	conn, err := db.Setup()
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	if err := migrations.Run(conn); err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	s := &server{db: conn, dbPath: db.Path()}
+	if err := serve(os.Stdin, os.Stdout, s); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// serve reads requests until stdin is closed (the extension disconnected)
+// and writes one response per request.
+func serve(r io.Reader, w io.Writer, s *server) error {
+	in := bufio.NewReader(r)
+	out := bufio.NewWriter(w)
+	for {
+		msg, err := readMessage(in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		resp := s.handle(msg)
+		if err := writeMessage(out, resp); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	// maxIncoming guards against a corrupt length prefix.
+	maxIncoming = 64 << 20
+	// maxOutgoing is Chrome's limit for messages sent by the host.
+	maxOutgoing = 1 << 20
+)
+
+// request mirrors DbRequest in shared/db/types.ts.
+type request struct {
+	ID     string          `json:"id"`
+	Action string          `json:"action"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// response mirrors DbResponse in shared/db/types.ts.
+type response struct {
+	ID    string     `json:"id"`
+	OK    bool       `json:"ok"`
+	Data  any        `json:"data,omitempty"`
+	Error *respError `json:"error,omitempty"`
+}
+
+type respError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// readMessage reads one message: a 32-bit length in native byte order
+// (little-endian on every platform Chrome supports) followed by that many
+// bytes of UTF-8 JSON.
+func readMessage(r io.Reader) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if n > maxIncoming {
+		return nil, fmt.Errorf("message too large: %d bytes", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+	return buf, nil
+}
+
+// writeMessage encodes resp and writes it with its length prefix. A
+// response over Chrome's 1 MB limit is replaced with an error so the
+// caller is not left waiting for its timeout.
+func writeMessage(w io.Writer, resp *response) error {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		body, _ = json.Marshal(failure(resp.ID, "ENCODE_ERROR", err.Error()))
+	}
+	if len(body) > maxOutgoing {
+		body, _ = json.Marshal(failure(resp.ID, "RESPONSE_TOO_LARGE",
+			fmt.Sprintf("response is %d bytes; Chrome accepts at most %d (add a LIMIT)", len(body), maxOutgoing)))
+	}
+
+	var prefix bytes.Buffer
+	binary.Write(&prefix, binary.LittleEndian, uint32(len(body)))
+	if _, err := w.Write(prefix.Bytes()); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func success(id string, data any) *response {
+	return &response{ID: id, OK: true, Data: data}
+}
+
+func failure(id, code, message string) *response {
+	return &response{ID: id, Error: &respError{Code: code, Message: message}}
+}
@@ -1,52 +1,74 @@
 package handlers
 
 import (
-	"net/http"
-
 	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
 )
 
-var ListAppGroupings = listHandler("app_groupings", "name", func(c *gin.Context, qb *queryBuilder) {
-	if q := c.Query("q"); q != "" {
-		qb.addLike("name", q)
+func ListAppGroupings(c *gin.Context) {
+	list, err := getStore().AppGroupings.List(c, store.AppGroupingFilter{
+		Q:      c.Query("q"),
+		Page:   listPage(c),
+		Filter: listFilter(c),
+	})
+	respondList(c, list, err)
+}
+
+func GetAppGrouping(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	g, err := getStore().AppGroupings.Get(c, id)
+	respondOne(c, g, err)
+}
+
+func DeleteAppGrouping(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
 	}
-	if aid := c.Query("asset_id"); aid != "" {
-		qb.addFilter("asset_id = ?", aid)
+	version, ok := ifMatch(c)
+	if !ok {
+		return
 	}
-	if name := c.Query("name"); name != "" {
-		qb.addFilter("name = ?", name)
+	if c.Query("dry_run") == "true" {
+		impact, err := getStore().AppGroupings.DeleteImpact(c, id, version)
+		respondImpact(c, impact, err)
+		return
 	}
-})
-
-var GetAppGrouping = getByPK("app_groupings", "app_grouping_id")
-var DeleteAppGrouping = deleteByPK("app_groupings", "app_grouping_id")
+	respondDeleted(c, audited(c, "app_grouping", store.AuditDelete, id, func(s *store.Store) (string, error) {
+		return id, s.AppGroupings.Delete(c, id, version)
+	}))
+}
 
 func CreateAppGrouping(c *gin.Context) {
 	var input struct {
 		Name        string  `json:"name" binding:"required"`
 		AssetID     string  `json:"asset_id" binding:"required"`
+		SnowSysId   *string `json:"snow_sys_id"`
 		Description *string `json:"description"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
-	id := newUUID()
-	_, err := getDB().ExecContext(c,
-		"INSERT INTO app_groupings (app_grouping_id, name, asset_id, description) VALUES (?, ?, ?, ?)",
-		id, input.Name, input.AssetID, input.Description)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	g := &store.AppGrouping{
+		Name:        input.Name,
+		AssetID:     input.AssetID,
+		SnowSysID:   input.SnowSysId,
+		Description: input.Description,
 	}
-
-	row, err := scanRow(getDB(), c, "SELECT * FROM app_groupings WHERE app_grouping_id = ?", id)
+	err := audited(c, "app_grouping", store.AuditCreate, "", func(s *store.Store) (string, error) {
+		err := s.AppGroupings.Create(c, g)
+		return g.AppGroupingID, err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
-	c.JSON(http.StatusCreated, row)
+	respondCreated(c, g)
 }
 
 func UpdateAppGrouping(c *gin.Context) {
@@ -54,32 +76,25 @@ func UpdateAppGrouping(c *gin.Context) {
 	if !ok {
 		return
 	}
-
-	var input struct {
-		Name        *string `json:"name"`
-		AssetID     *string `json:"asset_id"`
-		Description *string `json:"description"`
-	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	_, err := getDB().ExecContext(c,
-		`UPDATE app_groupings SET
-			name=COALESCE(?,name), asset_id=COALESCE(?,asset_id),
-			description=COALESCE(?,description), updated_at=datetime('now')
-		 WHERE app_grouping_id=?`,
-		input.Name, input.AssetID, input.Description, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var input store.AppGroupingUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
 		return
 	}
 
-	row, err := scanRow(getDB(), c, "SELECT * FROM app_groupings WHERE app_grouping_id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
-	}
-	c.JSON(http.StatusOK, row)
+	var g *store.AppGrouping
+	err := audited(c, "app_grouping", store.AuditUpdate, id, func(s *store.Store) (string, error) {
+		var err error
+		g, err = s.AppGroupings.Update(c, id, version, input)
+		return id, err
+	})
+	respondOne(c, g, err)
 }
+
+// PatchAppGrouping applies a JSON Merge Patch to one app grouping.
+var PatchAppGrouping = mergePatch("app_grouping", func(s *store.Store) patchFunc[store.AppGrouping] { return s.AppGroupings.Patch })
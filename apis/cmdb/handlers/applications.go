@@ -1,52 +1,74 @@
 package handlers
 
 import (
-	"net/http"
-
 	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
 )
 
-var ListApplications = listHandler("applications", "name", func(c *gin.Context, qb *queryBuilder) {
-	if q := c.Query("q"); q != "" {
-		qb.addLike("name", q)
+func ListApplications(c *gin.Context) {
+	list, err := getStore().Applications.List(c, store.ApplicationFilter{
+		Q:      c.Query("q"),
+		Page:   listPage(c),
+		Filter: listFilter(c),
+	})
+	respondList(c, list, err)
+}
+
+func GetApplication(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	a, err := getStore().Applications.Get(c, id)
+	respondOne(c, a, err)
+}
+
+func DeleteApplication(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
 	}
-	if gid := c.Query("app_grouping_id"); gid != "" {
-		qb.addFilter("app_grouping_id = ?", gid)
+	version, ok := ifMatch(c)
+	if !ok {
+		return
 	}
-	if name := c.Query("name"); name != "" {
-		qb.addFilter("name = ?", name)
+	if c.Query("dry_run") == "true" {
+		impact, err := getStore().Applications.DeleteImpact(c, id, version)
+		respondImpact(c, impact, err)
+		return
 	}
-})
-
-var GetApplication = getByPK("applications", "application_id")
-var DeleteApplication = deleteByPK("applications", "application_id")
+	respondDeleted(c, audited(c, "application", store.AuditDelete, id, func(s *store.Store) (string, error) {
+		return id, s.Applications.Delete(c, id, version)
+	}))
+}
 
 func CreateApplication(c *gin.Context) {
 	var input struct {
 		Name          string  `json:"name" binding:"required"`
 		AppGroupingID string  `json:"app_grouping_id" binding:"required"`
+		SnowSysId     *string `json:"snow_sys_id"`
 		Description   *string `json:"description"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
-	id := newUUID()
-	_, err := getDB().ExecContext(c,
-		"INSERT INTO applications (application_id, name, app_grouping_id, description) VALUES (?, ?, ?, ?)",
-		id, input.Name, input.AppGroupingID, input.Description)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	a := &store.Application{
+		Name:          input.Name,
+		AppGroupingID: input.AppGroupingID,
+		SnowSysID:     input.SnowSysId,
+		Description:   input.Description,
 	}
-
-	row, err := scanRow(getDB(), c, "SELECT * FROM applications WHERE application_id = ?", id)
+	err := audited(c, "application", store.AuditCreate, "", func(s *store.Store) (string, error) {
+		err := s.Applications.Create(c, a)
+		return a.ApplicationID, err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
-	c.JSON(http.StatusCreated, row)
+	respondCreated(c, a)
 }
 
 func UpdateApplication(c *gin.Context) {
@@ -54,32 +76,25 @@ func UpdateApplication(c *gin.Context) {
 	if !ok {
 		return
 	}
-
-	var input struct {
-		Name          *string `json:"name"`
-		AppGroupingID *string `json:"app_grouping_id"`
-		Description   *string `json:"description"`
-	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	_, err := getDB().ExecContext(c,
-		`UPDATE applications SET
-			name=COALESCE(?,name), app_grouping_id=COALESCE(?,app_grouping_id),
-			description=COALESCE(?,description), updated_at=datetime('now')
-		 WHERE application_id=?`,
-		input.Name, input.AppGroupingID, input.Description, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var input store.ApplicationUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
 		return
 	}
 
-	row, err := scanRow(getDB(), c, "SELECT * FROM applications WHERE application_id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
-	}
-	c.JSON(http.StatusOK, row)
+	var a *store.Application
+	err := audited(c, "application", store.AuditUpdate, id, func(s *store.Store) (string, error) {
+		var err error
+		a, err = s.Applications.Update(c, id, version, input)
+		return id, err
+	})
+	respondOne(c, a, err)
 }
+
+// PatchApplication applies a JSON Merge Patch to one application.
+var PatchApplication = mergePatch("application", func(s *store.Store) patchFunc[store.Application] { return s.Applications.Patch })
@@ -1,25 +1,46 @@
 package handlers
 
 import (
-	"net/http"
-
 	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
 )
 
-var ListAssets = listHandler("assets", "name", func(c *gin.Context, qb *queryBuilder) {
-	if q := c.Query("q"); q != "" {
-		qb.addLike("name", q)
+func ListAssets(c *gin.Context) {
+	list, err := getStore().Assets.List(c, store.AssetFilter{
+		Q:      c.Query("q"),
+		Page:   listPage(c),
+		Filter: listFilter(c),
+	})
+	respondList(c, list, err)
+}
+
+func GetAsset(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
 	}
-	if pid := c.Query("portfolio_id"); pid != "" {
-		qb.addFilter("portfolio_id = ?", pid)
+	a, err := getStore().Assets.Get(c, id)
+	respondOne(c, a, err)
+}
+
+func DeleteAsset(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
 	}
-	if name := c.Query("name"); name != "" {
-		qb.addFilter("name = ?", name)
+	version, ok := ifMatch(c)
+	if !ok {
+		return
 	}
-})
-
-var GetAsset = getByPK("assets", "asset_id")
-var DeleteAsset = deleteByPK("assets", "asset_id")
+	if c.Query("dry_run") == "true" {
+		impact, err := getStore().Assets.DeleteImpact(c, id, version)
+		respondImpact(c, impact, err)
+		return
+	}
+	respondDeleted(c, audited(c, "asset", store.AuditDelete, id, func(s *store.Store) (string, error) {
+		return id, s.Assets.Delete(c, id, version)
+	}))
+}
 
 func CreateAsset(c *gin.Context) {
 	var input struct {
@@ -34,27 +55,30 @@ func CreateAsset(c *gin.Context) {
 		Infrastructure *string `json:"infrastructure"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
-	id := newUUID()
-	_, err := getDB().ExecContext(c,
-		`INSERT INTO assets (asset_id, name, portfolio_id, snow_sys_id, full_name, description, criticality, environment, category, infrastructure)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		id, input.Name, input.PortfolioID, input.SnowSysId, input.FullName, input.Description,
-		input.Criticality, input.Environment, input.Category, input.Infrastructure)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	a := &store.Asset{
+		Name:           input.Name,
+		PortfolioID:    input.PortfolioID,
+		SnowSysID:      input.SnowSysId,
+		FullName:       input.FullName,
+		Description:    input.Description,
+		Criticality:    input.Criticality,
+		Environment:    input.Environment,
+		Category:       input.Category,
+		Infrastructure: input.Infrastructure,
 	}
-
-	row, err := scanRow(getDB(), c, "SELECT * FROM assets WHERE asset_id = ?", id)
+	err := audited(c, "asset", store.AuditCreate, "", func(s *store.Store) (string, error) {
+		err := s.Assets.Create(c, a)
+		return a.AssetID, err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
-	c.JSON(http.StatusCreated, row)
+	respondCreated(c, a)
 }
 
 func UpdateAsset(c *gin.Context) {
@@ -62,43 +86,25 @@ func UpdateAsset(c *gin.Context) {
 	if !ok {
 		return
 	}
-
-	var input struct {
-		Name           *string `json:"name"`
-		PortfolioID    *string `json:"portfolio_id"`
-		SnowSysId      *string `json:"snow_sys_id"`
-		FullName       *string `json:"full_name"`
-		Description    *string `json:"description"`
-		Criticality    *string `json:"criticality"`
-		Environment    *string `json:"environment"`
-		Category       *string `json:"category"`
-		Infrastructure *string `json:"infrastructure"`
-	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	_, err := getDB().ExecContext(c,
-		`UPDATE assets SET
-			name=COALESCE(?,name), portfolio_id=COALESCE(?,portfolio_id),
-			snow_sys_id=COALESCE(?,snow_sys_id), full_name=COALESCE(?,full_name),
-			description=COALESCE(?,description), criticality=COALESCE(?,criticality),
-			environment=COALESCE(?,environment), category=COALESCE(?,category),
-			infrastructure=COALESCE(?,infrastructure), updated_at=datetime('now')
-		 WHERE asset_id=?`,
-		input.Name, input.PortfolioID, input.SnowSysId, input.FullName,
-		input.Description, input.Criticality, input.Environment, input.Category,
-		input.Infrastructure, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var input store.AssetUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
 		return
 	}
 
-	row, err := scanRow(getDB(), c, "SELECT * FROM assets WHERE asset_id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
-	}
-	c.JSON(http.StatusOK, row)
+	var a *store.Asset
+	err := audited(c, "asset", store.AuditUpdate, id, func(s *store.Store) (string, error) {
+		var err error
+		a, err = s.Assets.Update(c, id, version, input)
+		return id, err
+	})
+	respondOne(c, a, err)
 }
+
+// PatchAsset applies a JSON Merge Patch to one asset.
+var PatchAsset = mergePatch("asset", func(s *store.Store) patchFunc[store.Asset] { return s.Assets.Patch })
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+const requestIDKey = "request_id"
+
+// Identify tags each request with an id for the audit log: the caller's
+// X-Request-ID, or a new one. Either way it is echoed on the response.
+func Identify(c *gin.Context) {
+	id := strings.TrimSpace(c.GetHeader("X-Request-ID"))
+	if id == "" {
+		id = newUUID()
+	}
+	c.Set(requestIDKey, id)
+	c.Header("X-Request-ID", id)
+	c.Next()
+}
+
+// actor is who the caller says they are, from X-Actor; nil if unset.
+func actor(c *gin.Context) *string {
+	a := strings.TrimSpace(c.GetHeader("X-Actor"))
+	if a == "" {
+		return nil
+	}
+	return &a
+}
+
+func requestID(c *gin.Context) *string {
+	id := c.GetString(requestIDKey)
+	if id == "" {
+		return nil
+	}
+	return &id
+}
+
+// transact runs write in one transaction and records the audit entry it
+// returns, if any, in the same transaction, so no change is committed
+// without its record.
+func transact(c *gin.Context, write func(tx store.DBTX) (*store.AuditEntry, error)) error {
+	tx, err := getDB().BeginTx(c, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	e, err := write(tx)
+	if err != nil {
+		return err
+	}
+	if e != nil {
+		if err := record(c, tx, e); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// record writes entries in tx, stamped with the request's id and, unless
+// an entry already names one, its actor.
+func record(c *gin.Context, tx store.DBTX, entries ...*store.AuditEntry) error {
+	a := store.New(tx).Audit
+	for _, e := range entries {
+		if e.Actor == nil {
+			e.Actor = actor(c)
+		}
+		e.RequestID = requestID(c)
+		if err := a.Record(c, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cascade records what an action on one row carried with it beyond the
+// row itself; see store.Cascade. It is built before apply runs and
+// recorded after.
+func cascade(c *gin.Context, tx store.DBTX, entity, id, action string, apply func() error) error {
+	cs, err := store.NewCascade(c, tx, entity, id, action)
+	if err != nil {
+		return err
+	}
+	if err := apply(); err != nil {
+		return err
+	}
+	entries, err := cs.Entries(c, tx)
+	if err != nil {
+		return err
+	}
+	return record(c, tx, entries...)
+}
+
+// audited runs write, a create, update or delete of one row of entity,
+// recording the row as it was before and after. write returns the row's
+// id, which for a create is only known once it has run.
+func audited(c *gin.Context, entity, action, id string, write func(s *store.Store) (string, error)) error {
+	return transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		var before, after any
+		var err error
+		if action != store.AuditCreate {
+			if before, err = store.Snapshot(c, tx, entity, id); err != nil {
+				return nil, err
+			}
+		}
+		if action == store.AuditDelete {
+			err = cascade(c, tx, entity, id, action, func() error {
+				_, err := write(store.New(tx))
+				return err
+			})
+		} else {
+			id, err = write(store.New(tx))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if action != store.AuditDelete {
+			if after, err = store.Snapshot(c, tx, entity, id); err != nil {
+				return nil, err
+			}
+		}
+		return store.Change(entity, id, action, before, after), nil
+	})
+}
+
+// ListAudit returns the audit log, newest first. The generic column
+// filters apply, e.g. ?entity=component&action=update&actor=jdoe or
+// ?created_at[gte]=2026-01-01.
+func ListAudit(c *gin.Context) {
+	list, err := getStore().Audit.List(c, store.AuditFilter{Page: listPage(c), Filter: listFilter(c)})
+	respondList(c, list, err)
+}
+
+// history lists the audit entries for one row of entity, including links
+// and validations that name it as the related row.
+func history(entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := idParam(c)
+		if !ok {
+			return
+		}
+		list, err := getStore().Audit.History(c, entity, id, store.AuditFilter{Page: listPage(c), Filter: listFilter(c)})
+		respondList(c, list, err)
+	}
+}
+
+var (
+	PortfolioHistory   = history("portfolio")
+	AssetHistory       = history("asset")
+	AppGroupingHistory = history("app_grouping")
+	ApplicationHistory = history("application")
+	ComponentHistory   = history("component")
+	WorkloadHistory    = history("workload")
+)
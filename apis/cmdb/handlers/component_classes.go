@@ -1,11 +1,24 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
 
-var ListComponentClasses = listHandler("component_classes", "label", func(c *gin.Context, qb *queryBuilder) {
-	if q := c.Query("q"); q != "" {
-		qb.addLike("label", q)
-	}
-})
+func ListComponentClasses(c *gin.Context) {
+	list, err := getStore().ComponentClasses.List(c, store.ComponentClassFilter{
+		Q:      c.Query("q"),
+		Page:   listPage(c),
+		Filter: listFilter(c),
+	})
+	respondList(c, list, err)
+}
 
-var GetComponentClass = getByPK("component_classes", "component_class_id")
+func GetComponentClass(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	cc, err := getStore().ComponentClasses.Get(c, id)
+	respondOne(c, cc, err)
+}
@@ -1,14 +1,25 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
 
-var ListComponentTypes = listHandler("component_types", "label", func(c *gin.Context, qb *queryBuilder) {
-	if q := c.Query("q"); q != "" {
-		qb.addLike("label", q)
-	}
-	if classID := c.Query("class_id"); classID != "" {
-		qb.addFilter("component_class_id = ?", classID)
-	}
-})
+func ListComponentTypes(c *gin.Context) {
+	list, err := getStore().ComponentTypes.List(c, store.ComponentTypeFilter{
+		Q:                c.Query("q"),
+		ComponentClassID: c.Query("class_id"),
+		Page:             listPage(c),
+		Filter:           listFilter(c),
+	})
+	respondList(c, list, err)
+}
 
-var GetComponentType = getByPK("component_types", "component_type_id")
+func GetComponentType(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	t, err := getStore().ComponentTypes.Get(c, id)
+	respondOne(c, t, err)
+}
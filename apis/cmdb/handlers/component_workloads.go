@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
 )
 
+var errLinkNotFound = errors.New("link not found")
+
 // ListComponentWorkloads returns all workloads linked to a component.
 func ListComponentWorkloads(c *gin.Context) {
 	id, ok := idParam(c)
@@ -13,31 +17,8 @@ func ListComponentWorkloads(c *gin.Context) {
 		return
 	}
 
-	rows, err := getDB().QueryContext(c,
-		`SELECT w.*
-		 FROM workloads w
-		 JOIN component_workloads cw ON cw.workload_id = w.workload_id
-		 WHERE cw.component_id = ?
-		 ORDER BY w.hostname`, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	defer rows.Close()
-
-	results, err := scanRows(rows)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if results == nil {
-		results = []map[string]any{}
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data":  results,
-		"count": len(results),
-	})
+	list, err := getStore().Workloads.ListByComponent(c, id)
+	respondAll(c, list, err)
 }
 
 // LinkWorkload creates a component-workload association.
@@ -51,15 +32,25 @@ func LinkWorkload(c *gin.Context) {
 		WorkloadID string `json:"workload_id" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
-	_, err := getDB().ExecContext(c,
-		"INSERT OR IGNORE INTO component_workloads (component_id, workload_id) VALUES (?, ?)",
-		componentID, input.WorkloadID)
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		res, err := tx.ExecContext(c,
+			"INSERT OR IGNORE INTO component_workloads (component_id, workload_id) VALUES (?, ?)",
+			componentID, input.WorkloadID)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil, nil // already linked
+		}
+		link := gin.H{"component_id": componentID, "workload_id": input.WorkloadID}
+		return store.Change("component", componentID, store.AuditLink, nil, link).Relate("workload", input.WorkloadID), nil
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
 
@@ -71,21 +62,29 @@ func UnlinkWorkload(c *gin.Context) {
 	componentID := c.Param("id")
 	workloadID := c.Param("workload_id")
 	if componentID == "" || workloadID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "component_id and workload_id required"})
+		badRequest(c, "component_id and workload_id required")
 		return
 	}
 
-	result, err := getDB().ExecContext(c,
-		"DELETE FROM component_workloads WHERE component_id = ? AND workload_id = ?",
-		componentID, workloadID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		res, err := tx.ExecContext(c,
+			"DELETE FROM component_workloads WHERE component_id = ? AND workload_id = ?",
+			componentID, workloadID)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil, errLinkNotFound
+		}
+		link := gin.H{"component_id": componentID, "workload_id": workloadID}
+		return store.Change("component", componentID, store.AuditUnlink, link, nil).Relate("workload", workloadID), nil
+	})
+	if err == errLinkNotFound {
+		c.JSON(http.StatusNotFound, apiError{Error: "link not found", Code: codeNotFound})
 		return
 	}
-
-	affected, _ := result.RowsAffected()
-	if affected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+	if err != nil {
+		storeError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"deleted": true})
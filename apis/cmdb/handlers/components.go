@@ -1,31 +1,50 @@
 package handlers
 
 import (
-	"net/http"
+	"encoding/json"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
 )
 
-var ListComponents = listHandler("components", "created_at", func(c *gin.Context, qb *queryBuilder) {
-	if q := c.Query("q"); q != "" {
-		qb.addLike("name", q)
-	}
-	if aid := c.Query("application_id"); aid != "" {
-		qb.addFilter("application_id = ?", aid)
+func ListComponents(c *gin.Context) {
+	list, err := getStore().Components.List(c, store.ComponentFilter{
+		Q:                c.Query("q"),
+		ComponentTypeID:  c.Query("type_id"),
+		ComponentClassID: c.Query("class_id"),
+		Page:             listPage(c),
+		Filter:           listFilter(c),
+	})
+	respondList(c, list, err)
+}
+
+func GetComponent(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
 	}
-	if tid := c.Query("type_id"); tid != "" {
-		qb.addFilter("component_type_id = ?", tid)
+	comp, err := getStore().Components.Get(c, id)
+	respondOne(c, comp, err)
+}
+
+func DeleteComponent(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
 	}
-	if cid := c.Query("class_id"); cid != "" {
-		qb.addFilter("component_class_id = ?", cid)
+	version, ok := ifMatch(c)
+	if !ok {
+		return
 	}
-	if name := c.Query("name"); name != "" {
-		qb.addFilter("name = ?", name)
+	if c.Query("dry_run") == "true" {
+		impact, err := getStore().Components.DeleteImpact(c, id, version)
+		respondImpact(c, impact, err)
+		return
 	}
-})
-
-var GetComponent = getByPK("components", "component_id")
-var DeleteComponent = deleteByPK("components", "component_id")
+	respondDeleted(c, audited(c, "component", store.AuditDelete, id, func(s *store.Store) (string, error) {
+		return id, s.Components.Delete(c, id, version)
+	}))
+}
 
 func CreateComponent(c *gin.Context) {
 	var input struct {
@@ -37,26 +56,27 @@ func CreateComponent(c *gin.Context) {
 		Description      *string `json:"description"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
-	id := newUUID()
-	_, err := getDB().ExecContext(c,
-		`INSERT INTO components (component_id, name, application_id, component_class_id, component_type_id, snow_sys_id, description)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		id, input.Name, input.ApplicationID, input.ComponentClassID, input.ComponentTypeID, input.SnowSysId, input.Description)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	comp := &store.Component{
+		Name:             input.Name,
+		ApplicationID:    input.ApplicationID,
+		ComponentClassID: input.ComponentClassID,
+		ComponentTypeID:  input.ComponentTypeID,
+		SnowSysID:        input.SnowSysId,
+		Description:      input.Description,
 	}
-
-	row, err := scanRow(getDB(), c, "SELECT * FROM components WHERE component_id = ?", id)
+	err := audited(c, "component", store.AuditCreate, "", func(s *store.Store) (string, error) {
+		err := s.Components.Create(c, comp)
+		return comp.ComponentID, err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
-	c.JSON(http.StatusCreated, row)
+	respondCreated(c, comp)
 }
 
 func UpdateComponent(c *gin.Context) {
@@ -64,37 +84,65 @@ func UpdateComponent(c *gin.Context) {
 	if !ok {
 		return
 	}
-
-	var input struct {
-		Name             *string `json:"name"`
-		ApplicationID    *string `json:"application_id"`
-		ComponentClassID *string `json:"component_class_id"`
-		ComponentTypeID  *string `json:"component_type_id"`
-		SnowSysId        *string `json:"snow_sys_id"`
-		Description      *string `json:"description"`
+	version, ok := ifMatch(c)
+	if !ok {
+		return
 	}
+
+	var input store.ComponentUpdate
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
-	_, err := getDB().ExecContext(c,
-		`UPDATE components SET
-			name=COALESCE(?,name), application_id=COALESCE(?,application_id),
-			component_class_id=COALESCE(?,component_class_id), component_type_id=COALESCE(?,component_type_id),
-			snow_sys_id=COALESCE(?,snow_sys_id), description=COALESCE(?,description),
-			updated_at=datetime('now')
-		 WHERE component_id=?`,
-		input.Name, input.ApplicationID, input.ComponentClassID, input.ComponentTypeID, input.SnowSysId, input.Description, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if c.Query("dry_run") == "true" {
+		impact, err := getStore().Components.ReparentImpact(c, id, version, input.ApplicationID)
+		respondImpact(c, impact, err)
 		return
 	}
 
-	row, err := scanRow(getDB(), c, "SELECT * FROM components WHERE component_id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	var comp *store.Component
+	err := audited(c, "component", store.AuditUpdate, id, func(s *store.Store) (string, error) {
+		var err error
+		comp, err = s.Components.Update(c, id, version, input)
+		return id, err
+	})
+	respondOne(c, comp, err)
+}
+
+var patchComponent = mergePatch("component", func(s *store.Store) patchFunc[store.Component] { return s.Components.Patch })
+
+// PatchComponent applies a JSON Merge Patch to one component. With
+// ?dry_run=true, as on PUT, it previews a change of application_id
+// instead.
+func PatchComponent(c *gin.Context) {
+	if c.Query("dry_run") != "true" {
+		patchComponent(c)
+		return
+	}
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ApplicationID json.RawMessage `json:"application_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, "body must be a JSON object")
 		return
 	}
-	c.JSON(http.StatusOK, row)
+	var applicationID *string
+	if input.ApplicationID != nil {
+		if err := json.Unmarshal(input.ApplicationID, &applicationID); err != nil || applicationID == nil {
+			badRequest(c, "application_id must be a string")
+			return
+		}
+	}
+	impact, err := getStore().Components.ReparentImpact(c, id, version, applicationID)
+	respondImpact(c, impact, err)
 }
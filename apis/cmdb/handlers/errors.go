@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/illumio"
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+	"github.com/jihaia/aperture/apis/cmdb/servicenow"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+// apiError is the body of every error response. Code is stable for
+// clients to switch on; Error is for people.
+type apiError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+	// Field is the offending column, when known.
+	Field string `json:"field,omitempty"`
+	// ExistingID is the row already holding a duplicate value.
+	ExistingID string `json:"existing_id,omitempty"`
+}
+
+// Error codes.
+const (
+	codeNotFound         = "not_found"
+	codeVersionMismatch  = "version_mismatch"
+	codeInvalidRequest   = "invalid_request"
+	codeDuplicate        = "duplicate"
+	codeInvalidReference = "invalid_reference"
+	codeMissingField     = "missing_field"
+	codeInvalidValue     = "invalid_value"
+	codeInternal         = "internal"
+	codeNotConfigured    = "not_configured"
+	codeUpstream         = "upstream_error"
+	codeUnavailable      = "unavailable"
+)
+
+// badRequest rejects a request the handler itself found malformed.
+func badRequest(c *gin.Context, msg string) {
+	c.JSON(http.StatusBadRequest, apiError{Error: msg, Code: codeInvalidRequest})
+}
+
+// clientError reports a PCE or ServiceNow client that could not be set
+// up: 503 when the integration is not configured, 500 when its settings
+// are invalid.
+func clientError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, illumio.ErrNotConfigured) || errors.Is(err, servicenow.ErrNotConfigured) {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, apiError{Error: err.Error(), Code: codeNotConfigured})
+}
+
+// upstreamError reports a failed call to the PCE or ServiceNow.
+func upstreamError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadGateway, apiError{Error: err.Error(), Code: codeUpstream})
+}
+
+// storeError writes the response for a repository or database error.
+// Constraint failures become 409 (duplicates) or 422 (bad references,
+// missing or invalid values); anything unrecognised is logged and
+// reported as a bare 500, so driver messages never reach the client.
+func storeError(c *gin.Context, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, apiError{Error: "not found", Code: codeNotFound})
+		return
+	}
+	if errors.Is(err, store.ErrVersionMismatch) {
+		c.JSON(http.StatusPreconditionFailed, apiError{Error: "row has changed; fetch it again and retry", Code: codeVersionMismatch})
+		return
+	}
+	var filterErr *listing.FilterError
+	var expandErr *store.ExpandError
+	var validationErr *store.ValidationError
+	if errors.Is(err, listing.ErrBadCursor) || errors.As(err, &filterErr) || errors.As(err, &expandErr) ||
+		errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, apiError{Error: err.Error(), Code: codeInvalidRequest})
+		return
+	}
+
+	var vocabErr *store.VocabularyError
+	if errors.As(err, &vocabErr) {
+		c.JSON(http.StatusUnprocessableEntity, apiError{Error: err.Error(), Code: codeInvalidValue, Field: vocabErr.Field})
+		return
+	}
+
+	var ce *store.ConstraintError
+	if !errors.As(err, &ce) && !errors.As(store.Classify(err), &ce) {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, apiError{Error: "internal server error", Code: codeInternal})
+		return
+	}
+	body := apiError{Error: ce.Error(), ExistingID: ce.ExistingID}
+	if len(ce.Fields) > 0 {
+		body.Field = ce.Fields[0]
+	}
+	status := http.StatusUnprocessableEntity
+	switch ce.Constraint {
+	case store.ConstraintUnique:
+		status, body.Code = http.StatusConflict, codeDuplicate
+	case store.ConstraintForeignKey:
+		body.Code = codeInvalidReference
+	case store.ConstraintNotNull:
+		body.Code = codeMissingField
+	default:
+		body.Code = codeInvalidValue
+	}
+	c.JSON(status, body)
+}
@@ -0,0 +1,45 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestErrorCodes checks error responses from each kind of handler carry
+// a code for clients to switch on.
+func TestErrorCodes(t *testing.T) {
+	for _, v := range []string{"ILLUMIO_PCE_URL", "SERVICENOW_INSTANCE"} {
+		t.Setenv(v, "")
+	}
+	b := seedBranch(t)
+
+	tests := []struct {
+		name, method, path string
+		body               any
+		status             int
+		code               string
+	}{
+		{"bad body", "POST", "/portfolios", []int{1}, http.StatusBadRequest, "invalid_request"},
+		{"bad update body", "PUT", "/assets/" + b.Asset, "x", http.StatusBadRequest, "invalid_request"},
+		{"typed list filter", "GET", "/portfolios?nope[eq]=1", nil, http.StatusBadRequest, "invalid_request"},
+		{"generic list filter", "GET", "/reconciliations?nope[eq]=1", nil, http.StatusBadRequest, "invalid_request"},
+		{"generic list cursor", "GET", "/reconciliations?cursor=!!!", nil, http.StatusBadRequest, "invalid_request"},
+		{"missing row", "GET", "/portfolios/missing", nil, http.StatusNotFound, "not_found"},
+		{"missing ip list", "GET", "/portfolios/missing/ip-list", nil, http.StatusNotFound, "not_found"},
+		{"missing link", "DELETE", "/components/" + b.Component + "/workloads/missing", nil, http.StatusNotFound, "not_found"},
+		{"lookup without identifier", "GET", "/workloads/lookup", nil, http.StatusBadRequest, "invalid_request"},
+		{"validation of missing workload", "POST", "/workloads/missing/validations", map[string]string{"status": "validated"}, http.StatusNotFound, "not_found"},
+		{"unknown tier", "POST", "/sync/servicenow", map[string][]string{"tiers": {"nope"}}, http.StatusBadRequest, "invalid_request"},
+		{"pce not configured", "POST", "/sync/illumio", nil, http.StatusServiceUnavailable, "not_configured"},
+		{"servicenow not configured", "POST", "/sync/servicenow", nil, http.StatusServiceUnavailable, "not_configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct{ Error, Code string }
+			status := call(t, tt.method, tt.path, tt.body, &resp)
+			if status != tt.status || resp.Code != tt.code || resp.Error == "" {
+				t.Errorf("%d %+v, want %d with code %s", status, resp, tt.status, tt.code)
+			}
+		})
+	}
+}
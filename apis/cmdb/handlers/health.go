@@ -5,6 +5,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jihaia/aperture/apis/cmdb/db"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+	migrations "github.com/jihaia/aperture/packages/migrations"
 )
 
 func Health(c *gin.Context) {
@@ -13,12 +15,29 @@ func Health(c *gin.Context) {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"status": "error",
 			"error":  err.Error(),
+			"code":   codeUnavailable,
 		})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "ok",
-		"db_path": db.Path(),
+
+	report, err := migrations.Check(db.DB())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "error",
+			"error":  err.Error(),
+			"code":   codeUnavailable,
+		})
+		return
+	}
+
+	status, code := "ok", http.StatusOK
+	if !report.UpToDate {
+		status, code = "migrations_pending", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status":     status,
+		"db_path":    db.Path(),
+		"migrations": report,
 	})
 }
 
@@ -26,7 +45,7 @@ func Schema(c *gin.Context) {
 	rows, err := db.DB().QueryContext(c,
 		"SELECT name, sql FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -34,15 +53,25 @@ func Schema(c *gin.Context) {
 	type table struct {
 		Name string `json:"name"`
 		SQL  string `json:"sql"`
+		// Expand lists the relations ?expand can follow from this table.
+		Expand []string `json:"expand,omitempty"`
 	}
+	relations := store.Relations()
 	var tables []table
 	for rows.Next() {
 		var t table
 		if err := rows.Scan(&t.Name, &t.SQL); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			storeError(c, err)
 			return
 		}
+		t.Expand = relations[t.Name]
 		tables = append(tables, t)
 	}
-	c.JSON(http.StatusOK, gin.H{"tables": tables})
+
+	report, err := migrations.Check(db.DB())
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tables": tables, "migrations": report})
 }
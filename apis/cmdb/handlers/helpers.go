@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,6 +12,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jihaia/aperture/apis/cmdb/db"
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+	"github.com/jihaia/aperture/apis/cmdb/store"
 )
 
 // pagination extracts limit/offset from query params with sensible defaults.
@@ -28,6 +32,42 @@ func pagination(c *gin.Context) (limit, offset int) {
 	return
 }
 
+// listPage reads ?limit, ?offset and ?cursor. A cursor takes precedence
+// over the offset, which is kept for older clients.
+func listPage(c *gin.Context) listing.Page {
+	limit, offset := pagination(c)
+	return listing.Page{Limit: limit, Offset: offset, Cursor: c.Query("cursor")}
+}
+
+// listFilter reads the generic column filters and ?sort (see
+// listing.ParseFilter); they are checked against the table when the
+// query runs.
+func listFilter(c *gin.Context) listing.Filter {
+	return listing.ParseFilter(c.Request.URL.Query())
+}
+
+// writeList writes a page in the {data, count, total, next_cursor}
+// envelope. When there is a next page it is also advertised in a Link
+// header pointing at the current URL with the cursor swapped in.
+func writeList(c *gin.Context, data any, count, total int, next string) {
+	var cursor any
+	if next != "" {
+		cursor = next
+		u := *c.Request.URL
+		q := u.Query()
+		q.Del("offset")
+		q.Set("cursor", next)
+		u.RawQuery = q.Encode()
+		c.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":        data,
+		"count":       count,
+		"total":       total,
+		"next_cursor": cursor,
+	})
+}
+
 // queryBuilder helps build dynamic SQL queries.
 type queryBuilder struct {
 	where []string
@@ -55,12 +95,35 @@ func (qb *queryBuilder) whereClause() string {
 func idParam(c *gin.Context) (string, bool) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		badRequest(c, "invalid id")
 		return "", false
 	}
 	return id, true
 }
 
+// ifMatch reads the version named by an If-Match header: "3", W/"3" or a
+// bare 3. A missing header or * gives 0, meaning unconditional. Anything
+// else fails with 412.
+func ifMatch(c *gin.Context) (int, bool) {
+	h := strings.TrimSpace(c.GetHeader("If-Match"))
+	if h == "" || h == "*" {
+		return 0, true
+	}
+	v, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(h, "W/"), `"`))
+	if err != nil || v < 1 {
+		c.JSON(http.StatusPreconditionFailed, apiError{Error: "If-Match must be a version ETag", Code: codeInvalidRequest})
+		return 0, false
+	}
+	return v, true
+}
+
+// setETag sets the ETag header from the row's version, if it has one.
+func setETag(c *gin.Context, row any) {
+	if v, ok := store.VersionOf(row); ok {
+		c.Header("ETag", fmt.Sprintf("%q", strconv.Itoa(v)))
+	}
+}
+
 // newUUID generates a new UUID v4 string.
 func newUUID() string {
 	return uuid.New().String()
@@ -92,8 +155,20 @@ func scanRows(rows *sql.Rows) ([]map[string]any, error) {
 	return results, nil
 }
 
+// jsonColumns replaces JSON text columns with raw JSON so they are
+// returned as structured data rather than strings.
+func jsonColumns(rows []map[string]any, cols ...string) {
+	for _, r := range rows {
+		for _, col := range cols {
+			if s, ok := r[col].(string); ok {
+				r[col] = json.RawMessage(s)
+			}
+		}
+	}
+}
+
 // scanRow scans a single row into a map.
-func scanRow(db *sql.DB, c *gin.Context, query string, args ...any) (map[string]any, error) {
+func scanRow(db store.DBTX, c *gin.Context, query string, args ...any) (map[string]any, error) {
 	rows, err := db.QueryContext(c, query, args...)
 	if err != nil {
 		return nil, err
@@ -110,39 +185,67 @@ func scanRow(db *sql.DB, c *gin.Context, query string, args ...any) (map[string]
 	return results[0], nil
 }
 
-// listHandler is a generic list handler factory.
-func listHandler(table, orderBy string, filters func(c *gin.Context, qb *queryBuilder)) gin.HandlerFunc {
+// listHandler is a generic list handler factory. Rows are ordered by
+// order (or ?sort) plus the primary key and paged with keyset cursors;
+// any column of the table can be filtered on.
+func listHandler(table, pk string, order []listing.Order, filters func(c *gin.Context, qb *queryBuilder)) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		qb := &queryBuilder{}
 		if filters != nil {
 			filters(c, qb)
 		}
 
-		limit, offset := pagination(c)
-
-		query := fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s LIMIT ? OFFSET ?", table, qb.whereClause(), orderBy)
-		qb.args = append(qb.args, limit, offset)
+		schema, err := listing.Describe(c, getDB(), table)
+		if err != nil {
+			storeError(c, err)
+			return
+		}
+		q, err := listFilter(c).Apply(listing.Query{
+			Columns: "*",
+			From:    table,
+			Where:   qb.where,
+			Args:    qb.args,
+			Order:   order,
+			PK:      pk,
+		}, schema)
+		if err != nil {
+			storeError(c, err)
+			return
+		}
 
-		rows, err := getDB().QueryContext(c, query, qb.args...)
+		page := listPage(c)
+		query, args, err := q.Select(page)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			storeError(c, err)
+			return
+		}
+		rows, err := getDB().QueryContext(c, query, args...)
+		if err != nil {
+			storeError(c, err)
 			return
 		}
 		defer rows.Close()
 
 		results, err := scanRows(rows)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			storeError(c, err)
 			return
 		}
 		if results == nil {
 			results = []map[string]any{}
 		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"data":  results,
-			"count": len(results),
+		results, next := listing.Trim(q, page, results, func(row map[string]any, col string) any {
+			return row[col]
 		})
+
+		var total int
+		query, args = q.Count()
+		if err := getDB().QueryRowContext(c, query, args...).Scan(&total); err != nil {
+			storeError(c, err)
+			return
+		}
+
+		writeList(c, results, len(results), total, next)
 	}
 }
 
@@ -157,40 +260,127 @@ func getByPK(table, pkColumn string) gin.HandlerFunc {
 		query := fmt.Sprintf("SELECT * FROM %s WHERE %s = ?", table, pkColumn)
 		row, err := scanRow(getDB(), c, query, id)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			storeError(c, store.ErrNotFound)
 			return
 		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			storeError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, row)
 	}
 }
 
-// deleteByPK is a generic delete-by-primary-key handler factory.
-func deleteByPK(table, pkColumn string) gin.HandlerFunc {
+func getDB() *sql.DB {
+	return db.DB()
+}
+
+// getStore returns the typed repositories over the shared connection.
+func getStore() *store.Store {
+	return store.New(getDB())
+}
+
+// respondList writes one page of a typed list (see writeList), with the
+// relations named by ?expand embedded in each row.
+func respondList[T any](c *gin.Context, list *listing.Result[T], err error) {
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	if expand := c.Query("expand"); expand != "" {
+		rows, err := store.Expand(c, getDB(), list.Items, expand)
+		if err != nil {
+			storeError(c, err)
+			return
+		}
+		writeList(c, rows, len(rows), list.Total, list.NextCursor)
+		return
+	}
+	writeList(c, list.Items, len(list.Items), list.Total, list.NextCursor)
+}
+
+// respondAll writes an unpaged typed list; total is simply its length.
+func respondAll[T any](c *gin.Context, list []T, err error) {
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	writeList(c, list, len(list), len(list), "")
+}
+
+// respondOne writes a single typed row, or the error for a failed lookup.
+// ?expand embeds related rows as on lists.
+func respondOne[T any](c *gin.Context, v *T, err error) {
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	setETag(c, v)
+	if expand := c.Query("expand"); expand != "" {
+		rows, err := store.Expand(c, getDB(), []T{*v}, expand)
+		if err != nil {
+			storeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, rows[0])
+		return
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+// patchFunc is a repository's Patch method.
+type patchFunc[T any] func(ctx context.Context, id string, version int, p store.Patch) (*T, error)
+
+// mergePatch handles PATCH for one entity. The body is a JSON Merge Patch
+// (RFC 7396): absent fields are left alone and null clears a field. If-Match
+// works as on PUT.
+func mergePatch[T any](entity string, repo func(*store.Store) patchFunc[T]) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, ok := idParam(c)
 		if !ok {
 			return
 		}
-
-		query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", table, pkColumn)
-		result, err := getDB().ExecContext(c, query, id)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		version, ok := ifMatch(c)
+		if !ok {
 			return
 		}
-		affected, _ := result.RowsAffected()
-		if affected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+
+		var patch store.Patch
+		if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil || patch == nil {
+			badRequest(c, "body must be a JSON object")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"deleted": true})
+
+		var row *T
+		err := audited(c, entity, store.AuditUpdate, id, func(s *store.Store) (string, error) {
+			var err error
+			row, err = repo(s)(c, id, version, patch)
+			return id, err
+		})
+		respondOne(c, row, err)
 	}
 }
 
-func getDB() *sql.DB {
-	return db.DB()
+// respondCreated writes a newly created row with its ETag.
+func respondCreated(c *gin.Context, row any) {
+	setETag(c, row)
+	c.JSON(http.StatusCreated, row)
+}
+
+// respondImpact writes the preview of a ?dry_run=true request.
+func respondImpact(c *gin.Context, impact any, err error) {
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dry_run": true, "impact": impact})
+}
+
+// respondDeleted writes the result of a repository delete.
+func respondDeleted(c *gin.Context, err error) {
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
 }
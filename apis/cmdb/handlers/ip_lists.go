@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/illumio"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+type ipListServer struct {
+	WorkloadID string   `json:"workload_id"`
+	Hostname   string   `json:"hostname"`
+	IP         *string  `json:"ip"`
+	Assets     []string `json:"assets"`
+	// Addresses lists what the server contributes to the list: its
+	// primary address and interfaces, less any listed for an earlier one.
+	Addresses   []string `json:"addresses"`
+	Description string   `json:"description"`
+}
+
+type portfolioIPList struct {
+	Name        string            `json:"name"`
+	Portfolio   string            `json:"portfolio"`
+	PortfolioID string            `json:"portfolio_id"`
+	ServerCount int               `json:"server_count"`
+	Servers     []ipListServer    `json:"servers"`
+	IPRanges    []illumio.IPRange `json:"ip_ranges"`
+}
+
+// portfolioWorkloads selects the workloads linked to a live component
+// under the portfolio.
+const portfolioWorkloads = `
+	FROM workloads w
+	JOIN component_workloads cw ON cw.workload_id = w.workload_id
+	JOIN components c ON c.component_id = cw.component_id AND c.deleted_at IS NULL
+	JOIN applications a ON a.application_id = c.application_id
+	JOIN app_groupings ag ON ag.app_grouping_id = a.app_grouping_id
+	JOIN assets ast ON ast.asset_id = ag.asset_id
+	WHERE ast.portfolio_id = ?`
+
+// buildPortfolioIPList collects the distinct workloads linked to any
+// component under the portfolio, with every address of each: the primary
+// one and its interfaces. Each address is described as
+// "hostname | asset, asset" (truncated to the PCE's 255 characters); one
+// shared by several workloads is listed once, for the first by hostname.
+func buildPortfolioIPList(c *gin.Context, portfolioID string) (*portfolioIPList, error) {
+	var name string
+	if err := getDB().QueryRowContext(c, "SELECT name FROM portfolios WHERE portfolio_id = ? AND deleted_at IS NULL", portfolioID).Scan(&name); err != nil {
+		return nil, err
+	}
+
+	rows, err := getDB().QueryContext(c,
+		`SELECT DISTINCT w.workload_id, w.hostname, w.ip_address, ast.name`+portfolioWorkloads+`
+		 ORDER BY w.hostname, ast.name`, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := &portfolioIPList{
+		Name:        illumio.IPListPrefix + " " + name,
+		Portfolio:   name,
+		PortfolioID: portfolioID,
+		Servers:     []ipListServer{},
+		IPRanges:    []illumio.IPRange{},
+	}
+	for rows.Next() {
+		var id, hostname, asset string
+		var ip sql.NullString
+		if err := rows.Scan(&id, &hostname, &ip, &asset); err != nil {
+			return nil, err
+		}
+		if n := len(list.Servers); n > 0 && list.Servers[n-1].WorkloadID == id {
+			list.Servers[n-1].Assets = append(list.Servers[n-1].Assets, asset)
+			continue
+		}
+		s := ipListServer{WorkloadID: id, Hostname: hostname, Assets: []string{asset}, Addresses: []string{}}
+		if ip.Valid && ip.String != "" {
+			s.IP = &ip.String
+		}
+		list.Servers = append(list.Servers, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	interfaces := map[string][]string{}
+	rows, err = getDB().QueryContext(c,
+		`SELECT wi.workload_id, wi.address FROM workload_interfaces wi
+		 WHERE wi.workload_id IN (SELECT w.workload_id`+portfolioWorkloads+`)
+		 ORDER BY wi.workload_id, wi.family, wi.address`, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, addr string
+		if err := rows.Scan(&id, &addr); err != nil {
+			return nil, err
+		}
+		interfaces[id] = append(interfaces[id], addr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	listed := map[string]bool{}
+	for i := range list.Servers {
+		s := &list.Servers[i]
+		s.Description = truncate(s.Hostname+" | "+strings.Join(s.Assets, ", "), 255)
+		addrs := interfaces[s.WorkloadID]
+		if s.IP != nil {
+			addrs = append([]string{*s.IP}, addrs...)
+		}
+		for _, addr := range addrs {
+			if listed[addr] {
+				continue
+			}
+			listed[addr] = true
+			s.Addresses = append(s.Addresses, addr)
+			list.IPRanges = append(list.IPRanges, illumio.IPRange{FromIP: addr, Description: s.Description})
+		}
+	}
+	list.ServerCount = len(list.Servers)
+	return list, nil
+}
+
+// GetPortfolioIPList builds the portfolio's IP list from the CMDB.
+func GetPortfolioIPList(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	list, err := buildPortfolioIPList(c, id)
+	if err == sql.ErrNoRows {
+		storeError(c, store.ErrNotFound)
+		return
+	}
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// PublishPortfolioIPList creates or updates the portfolio's draft IP list
+// on the PCE and reports the ranges added, removed and redescribed
+// compared to what is already there. ?dry_run=true reports without
+// writing.
+func PublishPortfolioIPList(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	list, err := buildPortfolioIPList(c, id)
+	if err == sql.ErrNoRows {
+		storeError(c, store.ErrNotFound)
+		return
+	}
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+
+	client, err := illumio.FromEnv()
+	if err != nil {
+		clientError(c, err)
+		return
+	}
+
+	existing, err := client.GetIPListByName(c, list.Name)
+	if err != nil {
+		upstreamError(c, err)
+		return
+	}
+
+	var have []illumio.IPRange
+	if existing != nil {
+		have = existing.IPRanges
+	}
+	added, removed, changed := illumio.DiffRanges(have, list.IPRanges)
+
+	action := "unchanged"
+	var href string
+	switch {
+	case existing == nil:
+		action = "created"
+		if !dryRun {
+			created, err := client.CreateIPList(c, list.Name, "Portfolio "+list.Portfolio+" (managed by Aperture)", list.IPRanges)
+			if err != nil {
+				upstreamError(c, err)
+				return
+			}
+			href = created.Href
+		}
+	case len(added) > 0 || len(removed) > 0 || len(changed) > 0:
+		action = "updated"
+		href = existing.Href
+		if !dryRun {
+			if err := client.UpdateIPList(c, existing.Href, list.IPRanges); err != nil {
+				upstreamError(c, err)
+				return
+			}
+		}
+	default:
+		href = existing.Href
+	}
+
+	resp := gin.H{
+		"dry_run": dryRun,
+		"action":  action,
+		"name":    list.Name,
+		"href":    nilIfEmpty(href),
+		"added":   nonNilRanges(added),
+		"removed": nonNilRanges(removed),
+		"changed": nonNilRanges(changed),
+	}
+	if href != "" {
+		resp["explorer_url"] = client.ExplorerURL(href)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// truncate cuts s to at most n characters, never inside one.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+func nilIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nonNilRanges(r []illumio.IPRange) []illumio.IPRange {
+	if r == nil {
+		return []illumio.IPRange{}
+	}
+	return r
+}
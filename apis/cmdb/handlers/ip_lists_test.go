@@ -0,0 +1,113 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jihaia/aperture/apis/cmdb/illumio"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+type ipList struct {
+	Name    string
+	Servers []struct {
+		Hostname, Description string
+		Addresses             []string
+	}
+	IPRanges []illumio.IPRange `json:"ip_ranges"`
+}
+
+func TestPortfolioIPList(t *testing.T) {
+	b := seedBranch(t)
+	// The long host sorts first and its description, of multi-byte
+	// characters, runs past the PCE's 255.
+	long := strings.Repeat("é", 300)
+	linkWorkload(t, b.Component, &store.Workload{Hostname: "a" + long, IPAddress: ptr("10.9.0.9")})
+	linkWorkload(t, b.Component, &store.Workload{Hostname: "web01", IPAddress: ptr("10.9.0.1"),
+		Interfaces: []store.Interface{{Address: "fd00::1"}, {Address: "10.9.0.2"}, {Address: "10.9.0.1"}}})
+	// Shares an address with web01, which lists it first.
+	linkWorkload(t, b.Component, &store.Workload{Hostname: "web02", IPAddress: ptr("10.9.0.2")})
+
+	var list ipList
+	if code := call(t, "GET", "/portfolios/"+b.Portfolio+"/ip-list", nil, &list); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	var got []string
+	for _, r := range list.IPRanges {
+		got = append(got, r.FromIP)
+	}
+	if fmt.Sprint(got) != "[10.9.0.9 10.9.0.1 10.9.0.2 fd00::1]" {
+		t.Errorf("ranges = %v", got)
+	}
+	if len(list.Servers) != 3 || len(list.Servers[2].Addresses) != 0 {
+		t.Errorf("servers = %+v, want web02 with nothing left to list", list.Servers)
+	}
+	d := list.Servers[0].Description
+	if !utf8.ValidString(d) || utf8.RuneCountInString(d) != 255 {
+		t.Errorf("description is %d runes, valid UTF-8 %v", utf8.RuneCountInString(d), utf8.ValidString(d))
+	}
+
+	if code := call(t, "GET", "/portfolios/missing/ip-list", nil, nil); code != http.StatusNotFound {
+		t.Errorf("missing portfolio: status %d", code)
+	}
+}
+
+// TestPublishIPList publishes against a fake PCE holding the list with
+// one address gone and one described differently.
+func TestPublishIPList(t *testing.T) {
+	b := seedBranch(t)
+	linkWorkload(t, b.Component, &store.Workload{Hostname: "db01", IPAddress: ptr("10.8.0.1")})
+	linkWorkload(t, b.Component, &store.Workload{Hostname: "db02", IPAddress: ptr("2001:db8::2")})
+
+	var put []illumio.IPRange
+	pce := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode([]illumio.IPList{{
+				Href: "/orgs/1/sec_policy/draft/ip_lists/7",
+				Name: illumio.IPListPrefix + " " + t.Name(),
+				IPRanges: []illumio.IPRange{
+					{FromIP: "10.8.0.1", Description: "db01 | renamed"},
+					// Written differently, but the same address.
+					{FromIP: "2001:DB8::2", Description: "db02 | " + t.Name() + " asset"},
+					{FromIP: "10.8.0.3", Description: "gone"},
+				},
+			}})
+		case http.MethodPut:
+			var body struct {
+				IPRanges []illumio.IPRange `json:"ip_ranges"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			put = body.IPRanges
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer pce.Close()
+	t.Setenv("ILLUMIO_PCE_URL", pce.URL)
+	t.Setenv("ILLUMIO_API_KEY_ID", "key")
+	t.Setenv("ILLUMIO_API_KEY_SECRET", "secret")
+
+	var resp struct {
+		Action                  string
+		Added, Removed, Changed []illumio.IPRange
+	}
+	if code := call(t, "POST", "/portfolios/"+b.Portfolio+"/ip-list/publish?dry_run=true", nil, &resp); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if resp.Action != "updated" || len(resp.Added) != 0 || len(resp.Removed) != 1 || len(resp.Changed) != 1 {
+		t.Errorf("dry run = %+v, want one removed, one changed", resp)
+	}
+	if put != nil {
+		t.Error("dry run wrote to the PCE")
+	}
+
+	call(t, "POST", "/portfolios/"+b.Portfolio+"/ip-list/publish", nil, &resp)
+	if len(put) != 2 || put[0].Description != "db01 | "+t.Name()+" asset" {
+		t.Errorf("put %+v", put)
+	}
+}
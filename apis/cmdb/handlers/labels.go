@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/illumio"
+	"github.com/jihaia/aperture/apis/cmdb/labels"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+// ListLabelMappings returns the configured label mappings and the
+// sources they may use.
+func ListLabelMappings(c *gin.Context) {
+	mappings, err := labels.LoadMappings(c, getDB())
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	if mappings == nil {
+		mappings = []labels.Mapping{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":    mappings,
+		"count":   len(mappings),
+		"sources": labels.SourceNames(),
+	})
+}
+
+// PutLabelMapping creates or replaces the mapping for one label key.
+func PutLabelMapping(c *gin.Context) {
+	key := c.Param("key")
+	var input struct {
+		Source string `json:"source" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	if !labels.ValidSource(input.Source) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown source: " + input.Source, "code": codeInvalidRequest, "sources": labels.SourceNames()})
+		return
+	}
+
+	mapping := labels.Mapping{Key: key, Source: input.Source}
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		before, err := getLabelMapping(c, tx, key)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		_, err = tx.ExecContext(c,
+			`INSERT INTO label_mappings (label_key, source) VALUES (?, ?)
+			 ON CONFLICT(label_key) DO UPDATE SET source=excluded.source, updated_at=datetime('now')`,
+			key, input.Source)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case before == nil:
+			return store.Change("label_mapping", key, store.AuditCreate, nil, mapping), nil
+		case *before != mapping:
+			return store.Change("label_mapping", key, store.AuditUpdate, before, mapping), nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, mapping)
+}
+
+func DeleteLabelMapping(c *gin.Context) {
+	key := c.Param("key")
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		before, err := getLabelMapping(c, tx, key)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(c, "DELETE FROM label_mappings WHERE label_key = ?", key); err != nil {
+			return nil, err
+		}
+		return store.Change("label_mapping", key, store.AuditDelete, before, nil), nil
+	})
+	if err == sql.ErrNoRows {
+		storeError(c, store.ErrNotFound)
+		return
+	}
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+func getLabelMapping(c *gin.Context, db store.DBTX, key string) (*labels.Mapping, error) {
+	m := labels.Mapping{Key: key}
+	err := db.QueryRowContext(c, "SELECT source FROM label_mappings WHERE label_key = ?", key).Scan(&m.Source)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetWorkloadLabels returns the label set a workload should carry on the PCE.
+func GetWorkloadLabels(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	mappings, err := labels.LoadMappings(c, getDB())
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	expected, err := labels.Compute(c, getDB(), mappings, []string{id})
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	if len(expected) == 0 {
+		storeError(c, store.ErrNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, expected[0])
+}
+
+// SyncLabels computes the expected labels for every workload (or the
+// posted workload_ids), compares them with the PCE and pushes the changes.
+// With ?dry_run=true nothing is written and the planned changes are returned.
+func SyncLabels(c *gin.Context) {
+	var input struct {
+		WorkloadIDs []string `json:"workload_ids"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			badRequest(c, err.Error())
+			return
+		}
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	client, err := illumio.FromEnv()
+	if err != nil {
+		clientError(c, err)
+		return
+	}
+
+	mappings, err := labels.LoadMappings(c, getDB())
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	expected, err := labels.Compute(c, getDB(), mappings, input.WorkloadIDs)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+
+	pce, err := client.GetAllWorkloads(c)
+	if err != nil {
+		upstreamError(c, err)
+		return
+	}
+	existing, err := client.ListLabels(c, "")
+	if err != nil {
+		upstreamError(c, err)
+		return
+	}
+
+	plan := labels.BuildPlan(expected, pce, existing)
+	resp := gin.H{
+		"dry_run":  dryRun,
+		"mappings": mappings,
+		"plan":     plan,
+	}
+	if !dryRun {
+		result, err := labels.Apply(c, client, plan, existing)
+		if err != nil {
+			resp["error"], resp["code"] = err.Error(), codeUpstream
+			c.JSON(http.StatusBadGateway, resp)
+			return
+		}
+		resp["result"] = result
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,112 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/db"
+	"github.com/jihaia/aperture/apis/cmdb/routes"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+	migrations "github.com/jihaia/aperture/packages/migrations"
+)
+
+// The handlers share the db package's one connection, so every test in
+// the package runs against one migrated database, in a temporary file.
+// Tests name what they create after themselves to keep clear of others.
+var router http.Handler
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "cmdb-handlers")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("APERTURE_DB_PATH", filepath.Join(dir, "test.db"))
+	conn, err := db.Setup()
+	if err == nil {
+		migrations.Output = io.Discard
+		err = migrations.Run(conn)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	router = routes.NewRouter()
+
+	code := m.Run()
+	conn.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// call sends a request to the API and decodes the JSON response into out,
+// if given, returning the status.
+func call(t *testing.T, method, path string, body, out any) int {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		must(t, err)
+		r = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, "/v1/cmdb"+path, r)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %d %s: %v", method, path, w.Code, w.Body, err)
+		}
+	}
+	return w.Code
+}
+
+// branch is one portfolio-to-component chain named after the test.
+type branch struct {
+	Portfolio, Asset, AppGrouping, Application, Component string
+}
+
+func seedBranch(t *testing.T) branch {
+	t.Helper()
+	ctx := context.Background()
+	s := store.New(db.DB())
+	p := &store.Portfolio{Name: t.Name()}
+	must(t, s.Portfolios.Create(ctx, p))
+	a := &store.Asset{Name: t.Name() + " asset", PortfolioID: p.PortfolioID}
+	must(t, s.Assets.Create(ctx, a))
+	g := &store.AppGrouping{Name: t.Name() + " grouping", AssetID: a.AssetID}
+	must(t, s.AppGroupings.Create(ctx, g))
+	app := &store.Application{Name: t.Name() + " app", AppGroupingID: g.AppGroupingID}
+	must(t, s.Applications.Create(ctx, app))
+	c := &store.Component{Name: ptr(t.Name() + " component"), ApplicationID: app.ApplicationID}
+	must(t, s.Components.Create(ctx, c))
+	return branch{p.PortfolioID, a.AssetID, g.AppGroupingID, app.ApplicationID, c.ComponentID}
+}
+
+// linkWorkload creates w and links it to the component.
+func linkWorkload(t *testing.T, component string, w *store.Workload) {
+	t.Helper()
+	must(t, store.New(db.DB()).Workloads.Create(context.Background(), w))
+	_, err := db.DB().Exec("INSERT INTO component_workloads (component_id, workload_id) VALUES (?, ?)", component, w.WorkloadID)
+	must(t, err)
+}
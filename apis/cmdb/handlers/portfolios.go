@@ -1,22 +1,46 @@
 package handlers
 
 import (
-	"net/http"
-
 	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
 )
 
-var ListPortfolios = listHandler("portfolios", "name", func(c *gin.Context, qb *queryBuilder) {
-	if q := c.Query("q"); q != "" {
-		qb.addLike("name", q)
-	}
-	if name := c.Query("name"); name != "" {
-		qb.addFilter("name = ?", name)
+func ListPortfolios(c *gin.Context) {
+	list, err := getStore().Portfolios.List(c, store.PortfolioFilter{
+		Q:      c.Query("q"),
+		Page:   listPage(c),
+		Filter: listFilter(c),
+	})
+	respondList(c, list, err)
+}
+
+func GetPortfolio(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
 	}
-})
+	p, err := getStore().Portfolios.Get(c, id)
+	respondOne(c, p, err)
+}
 
-var GetPortfolio = getByPK("portfolios", "portfolio_id")
-var DeletePortfolio = deleteByPK("portfolios", "portfolio_id")
+func DeletePortfolio(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if c.Query("dry_run") == "true" {
+		impact, err := getStore().Portfolios.DeleteImpact(c, id, version)
+		respondImpact(c, impact, err)
+		return
+	}
+	respondDeleted(c, audited(c, "portfolio", store.AuditDelete, id, func(s *store.Store) (string, error) {
+		return id, s.Portfolios.Delete(c, id, version)
+	}))
+}
 
 func CreatePortfolio(c *gin.Context) {
 	var input struct {
@@ -26,25 +50,25 @@ func CreatePortfolio(c *gin.Context) {
 		Description *string `json:"description"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
-	id := newUUID()
-	_, err := getDB().ExecContext(c,
-		"INSERT INTO portfolios (portfolio_id, name, snow_sys_id, state, description) VALUES (?, ?, ?, ?, ?)",
-		id, input.Name, input.SnowSysId, input.State, input.Description)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	p := &store.Portfolio{
+		Name:        input.Name,
+		SnowSysID:   input.SnowSysId,
+		State:       input.State,
+		Description: input.Description,
 	}
-
-	row, err := scanRow(getDB(), c, "SELECT * FROM portfolios WHERE portfolio_id = ?", id)
+	err := audited(c, "portfolio", store.AuditCreate, "", func(s *store.Store) (string, error) {
+		err := s.Portfolios.Create(c, p)
+		return p.PortfolioID, err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
-	c.JSON(http.StatusCreated, row)
+	respondCreated(c, p)
 }
 
 func UpdatePortfolio(c *gin.Context) {
@@ -52,30 +76,25 @@ func UpdatePortfolio(c *gin.Context) {
 	if !ok {
 		return
 	}
-
-	var input struct {
-		Name        *string `json:"name"`
-		SnowSysId   *string `json:"snow_sys_id"`
-		State       *string `json:"state"`
-		Description *string `json:"description"`
-	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	_, err := getDB().ExecContext(c,
-		"UPDATE portfolios SET name=COALESCE(?,name), snow_sys_id=COALESCE(?,snow_sys_id), state=COALESCE(?,state), description=COALESCE(?,description), updated_at=datetime('now') WHERE portfolio_id=?",
-		input.Name, input.SnowSysId, input.State, input.Description, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var input store.PortfolioUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
 		return
 	}
 
-	row, err := scanRow(getDB(), c, "SELECT * FROM portfolios WHERE portfolio_id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
-	}
-	c.JSON(http.StatusOK, row)
+	var p *store.Portfolio
+	err := audited(c, "portfolio", store.AuditUpdate, id, func(s *store.Store) (string, error) {
+		var err error
+		p, err = s.Portfolios.Update(c, id, version, input)
+		return id, err
+	})
+	respondOne(c, p, err)
 }
+
+// PatchPortfolio applies a JSON Merge Patch to one portfolio.
+var PatchPortfolio = mergePatch("portfolio", func(s *store.Store) patchFunc[store.Portfolio] { return s.Portfolios.Patch })
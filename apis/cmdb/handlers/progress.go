@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// progressBase resolves each workload's current status (its latest
+// validation, or pending), whether it was ever visited (has any
+// validation, even one left pending) and whether it is linked to any
+// component.
+const progressBase = `
+WITH latest AS (
+  SELECT v.workload_id, v.status
+  FROM workload_validations v
+  WHERE v.rowid = (
+    SELECT v2.rowid FROM workload_validations v2
+    WHERE v2.workload_id = v.workload_id
+    ORDER BY v2.validated_at DESC, v2.rowid DESC LIMIT 1)
+),
+ws AS (
+  SELECT w.workload_id, w.environment, w.location,
+         COALESCE(l.status, 'pending') AS status,
+         l.workload_id IS NOT NULL AS visited,
+         EXISTS (SELECT 1 FROM component_workloads cw JOIN components c ON c.component_id = cw.component_id
+                 WHERE cw.workload_id = w.workload_id AND c.deleted_at IS NULL) AS linked
+  FROM workloads w
+  LEFT JOIN latest l ON l.workload_id = w.workload_id
+),
+hier AS (
+  SELECT DISTINCT cw.workload_id, ast.asset_id, ast.name AS asset_name,
+         p.portfolio_id, p.name AS portfolio_name
+  FROM component_workloads cw
+  JOIN components c ON c.component_id = cw.component_id AND c.deleted_at IS NULL
+  JOIN applications a ON a.application_id = c.application_id
+  JOIN app_groupings ag ON ag.app_grouping_id = a.app_grouping_id
+  JOIN assets ast ON ast.asset_id = ag.asset_id
+  JOIN portfolios p ON p.portfolio_id = ast.portfolio_id
+)`
+
+// progressCounts aggregates the ws rows of one group. visited counts the
+// workloads with at least one validation; validated those whose latest
+// is validated or edited.
+const progressCounts = `
+  COUNT(*) AS total,
+  COALESCE(SUM(ws.visited), 0) AS visited,
+  COALESCE(SUM(ws.status IN ('validated', 'edited')), 0) AS validated,
+  COALESCE(SUM(ws.status = 'no_match'), 0) AS no_match,
+  COALESCE(SUM(ws.status = 'pending'), 0) AS pending,
+  COALESCE(SUM(ws.linked), 0) AS linked,
+  CAST(ROUND(100.0 * COALESCE(SUM(ws.status IN ('validated', 'edited', 'no_match')), 0) / COUNT(*)) AS INTEGER) AS percent_complete`
+
+var progressBreakdowns = []struct {
+	key   string
+	query string
+}{
+	{"by_portfolio", `SELECT h.portfolio_id, h.portfolio_name AS name,` + progressCounts + `
+		FROM ws JOIN (SELECT DISTINCT workload_id, portfolio_id, portfolio_name FROM hier) h ON h.workload_id = ws.workload_id
+		GROUP BY h.portfolio_id ORDER BY h.portfolio_name`},
+	{"by_asset", `SELECT h.asset_id, h.asset_name AS name, h.portfolio_id,` + progressCounts + `
+		FROM ws JOIN hier h ON h.workload_id = ws.workload_id
+		GROUP BY h.asset_id ORDER BY h.portfolio_name, h.asset_name`},
+	{"by_environment", `SELECT ws.environment,` + progressCounts + `
+		FROM ws GROUP BY ws.environment ORDER BY ws.environment`},
+	{"by_location", `SELECT ws.location,` + progressCounts + `
+		FROM ws GROUP BY ws.location ORDER BY ws.location`},
+}
+
+// Progress reports team-wide validation progress across all workloads:
+// how many were visited and validated, in total and broken down by
+// portfolio, asset, environment and location.
+// A workload under several assets is counted once in each.
+func Progress(c *gin.Context) {
+	totals, err := scanRow(getDB(), c, progressBase+`
+		SELECT`+progressCounts+`, COALESCE(SUM(NOT ws.linked), 0) AS unlinked FROM ws`)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	if totals["total"] == int64(0) {
+		totals["percent_complete"] = int64(0)
+	}
+
+	resp := gin.H{"totals": totals}
+	for _, b := range progressBreakdowns {
+		rows, err := getDB().QueryContext(c, progressBase+"\n"+b.query)
+		if err != nil {
+			storeError(c, fmt.Errorf("%s: %w", b.key, err))
+			return
+		}
+		results, err := scanRows(rows)
+		rows.Close()
+		if err != nil {
+			storeError(c, err)
+			return
+		}
+		if results == nil {
+			results = []map[string]any{}
+		}
+		resp[b.key] = results
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
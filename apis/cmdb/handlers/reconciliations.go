@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/illumio"
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+	"github.com/jihaia/aperture/apis/cmdb/reconcile"
+)
+
+var ListReconciliations = listHandler("reconciliations", "reconciliation_id",
+	[]listing.Order{{Column: "created_at", Desc: true}}, nil)
+var GetReconciliation = getByPK("reconciliations", "reconciliation_id")
+
+// CreateReconciliation compares the posted Illumio workloads against the
+// workloads table and persists the resulting report.
+func CreateReconciliation(c *gin.Context) {
+	var input struct {
+		Workloads []illumio.Workload `json:"workloads" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	id, _, err := reconcile.Run(c, getDB(), input.Workloads)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+
+	row, err := scanRow(getDB(), c, "SELECT * FROM reconciliations WHERE reconciliation_id = ?", id)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, row)
+}
+
+// ListReconciliationItems pages through the items of one report,
+// optionally filtered by ?bucket=only_in_illumio|only_in_cmdb|drift.
+func ListReconciliationItems(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	qb := &queryBuilder{}
+	qb.addFilter("reconciliation_id = ?", id)
+	if bucket := c.Query("bucket"); bucket != "" {
+		qb.addFilter("bucket = ?", bucket)
+	}
+	if q := c.Query("q"); q != "" {
+		qb.addLike("hostname", q)
+	}
+
+	limit, offset := pagination(c)
+	qb.args = append(qb.args, limit, offset)
+
+	rows, err := getDB().QueryContext(c,
+		"SELECT * FROM reconciliation_items"+qb.whereClause()+" ORDER BY bucket, hostname LIMIT ? OFFSET ?",
+		qb.args...)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	results, err := scanRows(rows)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	if results == nil {
+		results = []map[string]any{}
+	}
+
+	jsonColumns(results, "drift")
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  results,
+		"count": len(results),
+	})
+}
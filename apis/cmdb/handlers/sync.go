@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/illumio"
+	"github.com/jihaia/aperture/apis/cmdb/servicenow"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+// SyncIllumio pulls every workload from the PCE using the async export
+// and upserts them into the workloads table by hostname.
+func SyncIllumio(c *gin.Context) {
+	client, err := illumio.FromEnv()
+	if err != nil {
+		clientError(c, err)
+		return
+	}
+
+	workloads, err := client.GetAllWorkloads(c)
+	if err != nil {
+		upstreamError(c, err)
+		return
+	}
+
+	// Deduplicate by hostname — Illumio often has multiple VEN registrations
+	// per host. Keep the first entry for each hostname.
+	seen := map[string]bool{}
+	var mapped []store.WorkloadUpdate
+	for _, w := range workloads {
+		m := mapIllumioWorkload(w)
+		if m.Hostname == nil || seen[*m.Hostname] {
+			continue
+		}
+		seen[*m.Hostname] = true
+		mapped = append(mapped, m)
+	}
+
+	result, err := importWorkloads(c, "illumio", mapped)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"fetched":  len(workloads),
+		"created":  result.Created,
+		"updated":  result.Updated,
+		"errors":   result.Errors,
+		"failures": result.Failures,
+		"total":    result.Total,
+	})
+}
+
+// SyncServiceNow imports the BIA hierarchy from ServiceNow, upserting every
+// tier by snow_sys_id. An optional {"tiers": [...]} body limits the run.
+// Reruns are idempotent: unchanged rows are counted but not rewritten.
+func SyncServiceNow(c *gin.Context) {
+	var input struct {
+		Tiers []string `json:"tiers"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			badRequest(c, err.Error())
+			return
+		}
+	}
+	for _, t := range input.Tiers {
+		if !slices.Contains(servicenow.AllTiers, t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown tier: " + t, "code": codeInvalidRequest, "tiers": servicenow.AllTiers})
+			return
+		}
+	}
+
+	client, err := servicenow.FromEnv()
+	if err != nil {
+		clientError(c, err)
+		return
+	}
+
+	// The importer runs its own transaction; each row it writes and the
+	// run as a whole are recorded in it before it commits.
+	by := importActor(c, "servicenow")
+	im := &servicenow.Importer{Client: client, DB: getDB(), Mapping: servicenow.DefaultMapping,
+		Audit: func(tx store.DBTX, results map[string]*servicenow.TierResult) error {
+			var entries []*store.AuditEntry
+			for _, tier := range servicenow.AllTiers {
+				if res, ok := results[tier]; ok {
+					entries = append(entries, res.Changes...)
+				}
+			}
+			entries = append(entries, store.Change("import", "servicenow", store.AuditImport, nil, results))
+			for _, e := range entries {
+				e.Actor = by
+			}
+			return record(c, tx, entries...)
+		}}
+	results, err := im.Run(c, input.Tiers)
+	if err != nil {
+		upstreamError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// importWorkloads upserts rows by hostname and records the run in the
+// audit log: each workload created or changed, and one import from source
+// with its counts. Entries name source as the actor unless the caller
+// gave one.
+func importWorkloads(c *gin.Context, source string, rows []store.WorkloadUpdate) (*store.UpsertResult, error) {
+	var result *store.UpsertResult
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		var err error
+		if result, err = store.New(tx).Workloads.Upsert(c, rows); err != nil {
+			return nil, err
+		}
+		by := importActor(c, source)
+		for _, e := range result.Changes {
+			e.Actor = by
+		}
+		if err := record(c, tx, result.Changes...); err != nil {
+			return nil, err
+		}
+		e := store.Change("import", source, store.AuditImport, nil, result)
+		e.Actor = by
+		return e, nil
+	})
+	return result, err
+}
+
+// importActor is the caller's X-Actor, or the sync source when a job
+// runs without one.
+func importActor(c *gin.Context, source string) *string {
+	if a := actor(c); a != nil {
+		return a
+	}
+	return &source
+}
+
+// mapIllumioWorkload converts a PCE workload into a workloads row.
+func mapIllumioWorkload(w illumio.Workload) store.WorkloadUpdate {
+	return store.WorkloadUpdate{
+		Hostname:    optString(strings.TrimSpace(w.DisplayHostname())),
+		IPAddress:   optString(w.FirstIPv4()),
+		OS:          optString(w.OS()),
+		Environment: optString(w.Label("env")),
+		Location:    optString(w.Label("loc")),
+		Description: w.Description,
+		Interfaces:  illumioInterfaces(w.Interfaces),
+	}
+}
+
+// illumioInterfaces keeps every PCE interface with an address, IPv4 and
+// IPv6 alike.
+func illumioInterfaces(list []illumio.Interface) []store.Interface {
+	out := []store.Interface{}
+	for _, i := range list {
+		if i.Address == "" {
+			continue
+		}
+		out = append(out, store.Interface{
+			Name:      optString(i.Name),
+			Address:   i.Address,
+			CIDRBlock: i.CIDRBlock,
+			Source:    "illumio",
+		})
+	}
+	return out
+}
+
+// optString returns nil for an empty string so COALESCE keeps the existing value.
+func optString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+// ListTrash returns the deleted hierarchy rows, newest first, optionally
+// narrowed with ?type=portfolio|asset|app_grouping|application|component.
+// Rows deleted along with a parent are counted in its entry's cascaded.
+func ListTrash(c *gin.Context) {
+	entries, err := getStore().Trash.List(c, c.Query("type"))
+	respondAll(c, entries, err)
+}
+
+// RestoreTrash brings a deleted row back together with everything that
+// was deleted with it. It fails with 422 while the row's parent is itself
+// in the trash.
+func RestoreTrash(c *gin.Context) {
+	entity, id := c.Param("type"), c.Param("id")
+	var n int
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		err := cascade(c, tx, entity, id, store.AuditRestore, func() error {
+			var err error
+			n, err = store.New(tx).Trash.Restore(c, entity, id)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		after, err := store.Snapshot(c, tx, entity, id)
+		if err != nil {
+			return nil, err
+		}
+		return store.Change(entity, id, store.AuditRestore, nil, after), nil
+	})
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"restored": n})
+}
+
+// PurgeTrash permanently deletes a row in the trash and everything
+// beneath it. Rows that are not in the trash are not found. ?dry_run=true
+// lists what would be deleted instead.
+func PurgeTrash(c *gin.Context) {
+	if c.Query("dry_run") == "true" {
+		impact, err := getStore().Trash.PurgeImpact(c, c.Param("type"), c.Param("id"))
+		respondImpact(c, impact, err)
+		return
+	}
+	entity, id := c.Param("type"), c.Param("id")
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		// Only rows in the trash can be purged; Purge says so if this one
+		// is not, so the snapshot's own not-found is left to it.
+		before, err := store.Snapshot(c, tx, entity, id)
+		if err != nil && err != store.ErrNotFound {
+			return nil, err
+		}
+		err = cascade(c, tx, entity, id, store.AuditPurge, func() error {
+			return store.New(tx).Trash.Purge(c, entity, id)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return store.Change(entity, id, store.AuditPurge, before, nil), nil
+	})
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": true})
+}
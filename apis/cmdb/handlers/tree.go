@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// treeLevel is one tier of the BIA hierarchy.
+type treeLevel struct {
+	typ    string // node type, as used by the admin UI
+	table  string
+	pk     string
+	parent string // foreign key to the level above; empty for portfolios
+}
+
+var treeLevels = []treeLevel{
+	{"portfolio", "portfolios", "portfolio_id", ""},
+	{"asset", "assets", "asset_id", "portfolio_id"},
+	{"app_grouping", "app_groupings", "app_grouping_id", "asset_id"},
+	{"application", "applications", "application_id", "app_grouping_id"},
+	{"component", "components", "component_id", "application_id"},
+}
+
+type treeNode struct {
+	Type          string      `json:"type"`
+	ID            string      `json:"id"`
+	Name          *string     `json:"name"`
+	ChildrenCount int         `json:"children_count"`
+	WorkloadCount int         `json:"workload_count"`
+	Children      []*treeNode `json:"children,omitempty"`
+}
+
+// workloadCountQuery counts the distinct workloads linked to components
+// under each node of level i, joining down through the levels below it.
+func workloadCountQuery(i int) string {
+	l := treeLevels[i]
+	q := fmt.Sprintf("SELECT t%d.%s AS id, COUNT(DISTINCT cw.workload_id) AS n FROM %s t%d", i, l.pk, l.table, i)
+	for j := i + 1; j < len(treeLevels); j++ {
+		q += fmt.Sprintf(" JOIN %s t%d ON t%d.%s = t%d.%s",
+			treeLevels[j].table, j, j, treeLevels[j].parent, j-1, treeLevels[j-1].pk)
+	}
+	last := len(treeLevels) - 1
+	q += fmt.Sprintf(" JOIN component_workloads cw ON cw.component_id = t%d.component_id WHERE t%d.deleted_at IS NULL GROUP BY t%d.%s",
+		last, last, i, l.pk)
+	return q
+}
+
+// treeScope restricts level i to the subtree under the root node at level
+// root, nesting one IN subquery per level in between.
+func treeScope(i, root int) string {
+	if i == root {
+		return treeLevels[i].pk + " = ?"
+	}
+	above := treeLevels[i-1]
+	return fmt.Sprintf("%s IN (SELECT %s FROM %s WHERE %s)", treeLevels[i].parent, above.pk, above.table, treeScope(i-1, root))
+}
+
+// treeQuery selects every node of level i in scope, with its child count
+// and workload count.
+func treeQuery(i, root int, rooted bool) string {
+	l := treeLevels[i]
+	children := "0"
+	if i+1 < len(treeLevels) {
+		child := treeLevels[i+1]
+		children = fmt.Sprintf("(SELECT count(*) FROM %s ch WHERE ch.%s = n.%s AND ch.deleted_at IS NULL)", child.table, child.parent, l.pk)
+	}
+	parent := "NULL"
+	if l.parent != "" {
+		parent = "n." + l.parent
+	}
+	where := " WHERE n.deleted_at IS NULL"
+	if rooted {
+		where += " AND n." + treeScope(i, root)
+	}
+	return fmt.Sprintf(
+		`SELECT n.%s, n.name, %s, %s, IFNULL(wc.n, 0)
+		 FROM %s n LEFT JOIN (%s) wc ON wc.id = n.%s%s
+		 ORDER BY n.name, n.%s`,
+		l.pk, parent, children, l.table, workloadCountQuery(i), l.pk, where, l.pk)
+}
+
+// GetTree returns the Portfolio → Asset → App Grouping → Application →
+// Component hierarchy in one response. The root can be narrowed with one
+// of ?portfolio_id, ?asset_id, ?app_grouping_id, ?application_id or
+// ?component_id, and ?depth limits how many levels below the roots are
+// included (children_count still reports what was left out). Every node
+// carries the number of distinct workloads linked anywhere beneath it.
+func GetTree(c *gin.Context) {
+	root, rootID := 0, ""
+	for i, l := range treeLevels {
+		if id := c.Query(l.pk); id != "" {
+			if rootID != "" {
+				badRequest(c, "only one root id may be given")
+				return
+			}
+			root, rootID = i, id
+		}
+	}
+
+	last := len(treeLevels) - 1
+	if d := c.Query("depth"); d != "" {
+		depth, err := strconv.Atoi(d)
+		if err != nil || depth < 0 {
+			badRequest(c, "depth must be a non-negative integer")
+			return
+		}
+		last = min(last, root+depth)
+	}
+
+	var roots []*treeNode
+	byID := map[string]*treeNode{}
+	for i := root; i <= last; i++ {
+		var args []any
+		if rootID != "" {
+			args = append(args, rootID)
+		}
+		rows, err := getDB().QueryContext(c, treeQuery(i, root, rootID != ""), args...)
+		if err != nil {
+			storeError(c, err)
+			return
+		}
+
+		level := map[string]*treeNode{}
+		for rows.Next() {
+			n := &treeNode{Type: treeLevels[i].typ}
+			var parentID sql.NullString
+			if err := rows.Scan(&n.ID, &n.Name, &parentID, &n.ChildrenCount, &n.WorkloadCount); err != nil {
+				rows.Close()
+				storeError(c, err)
+				return
+			}
+			level[n.ID] = n
+			if i == root {
+				roots = append(roots, n)
+			} else if p := byID[parentID.String]; p != nil {
+				p.Children = append(p.Children, n)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			storeError(c, err)
+			return
+		}
+		byID = level
+	}
+
+	if rootID != "" && len(roots) == 0 {
+		c.JSON(http.StatusNotFound, apiError{Error: strings.ReplaceAll(treeLevels[root].typ, "_", " ") + " not found", Code: codeNotFound})
+		return
+	}
+	if roots == nil {
+		roots = []*treeNode{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": roots, "count": len(roots)})
+}
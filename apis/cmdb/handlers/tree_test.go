@@ -0,0 +1,108 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jihaia/aperture/apis/cmdb/db"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+type tree struct {
+	Type          string
+	ID            string
+	ChildrenCount int `json:"children_count"`
+	WorkloadCount int `json:"workload_count"`
+	Children      []tree
+}
+
+// shape writes a tree as "type children/workloads [children...]".
+func shape(nodes []tree) string {
+	var out []string
+	for _, n := range nodes {
+		s := fmt.Sprintf("%s %d/%d", n.Type, n.ChildrenCount, n.WorkloadCount)
+		if len(n.Children) > 0 {
+			s += " " + shape(n.Children)
+		}
+		out = append(out, s)
+	}
+	return "[" + strings.Join(out, " ") + "]"
+}
+
+func TestTree(t *testing.T) {
+	b := seedBranch(t)
+	linkWorkload(t, b.Component, &store.Workload{Hostname: t.Name() + "-1"})
+	linkWorkload(t, b.Component, &store.Workload{Hostname: t.Name() + "-2"})
+	ctx := context.Background()
+	s := store.New(db.DB())
+	must(t, s.Assets.Create(ctx, &store.Asset{Name: t.Name() + " asset 2", PortfolioID: b.Portfolio}))
+	trashed := &store.Asset{Name: t.Name() + " asset 3", PortfolioID: b.Portfolio}
+	must(t, s.Assets.Create(ctx, trashed))
+	must(t, s.Assets.Delete(ctx, trashed.AssetID, 0))
+
+	const chain = "[asset 1/2 [app_grouping 1/2 [application 1/2 [component 0/2]]] asset 0/0]"
+	tests := []struct {
+		name  string
+		query string
+		tree  string
+	}{
+		{"whole branch", "portfolio_id=" + b.Portfolio, "[portfolio 2/2 " + chain + "]"},
+		{"depth 0", "portfolio_id=" + b.Portfolio + "&depth=0", "[portfolio 2/2]"},
+		{"depth 1", "portfolio_id=" + b.Portfolio + "&depth=1", "[portfolio 2/2 [asset 1/2 asset 0/0]]"},
+		{"past the last level", "portfolio_id=" + b.Portfolio + "&depth=9", "[portfolio 2/2 " + chain + "]"},
+		{"asset root", "asset_id=" + b.Asset + "&depth=1", "[asset 1/2 [app_grouping 1/2]]"},
+		{"component root", "component_id=" + b.Component, "[component 0/2]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct {
+				Data  []tree
+				Count int
+			}
+			if code := call(t, "GET", "/tree?"+tt.query, nil, &resp); code != http.StatusOK {
+				t.Fatalf("status %d", code)
+			}
+			if got := shape(resp.Data); got != tt.tree || resp.Count != 1 {
+				t.Errorf("tree = %s (count %d), want %s", got, resp.Count, tt.tree)
+			}
+		})
+	}
+
+	t.Run("unrooted", func(t *testing.T) {
+		var resp struct{ Data []tree }
+		call(t, "GET", "/tree?depth=0", nil, &resp)
+		found := false
+		for _, n := range resp.Data {
+			if n.Type != "portfolio" || len(n.Children) > 0 {
+				t.Errorf("node %+v, want portfolios only", n)
+			}
+			found = found || n.ID == b.Portfolio
+		}
+		if !found {
+			t.Error("portfolio missing from the unrooted tree")
+		}
+	})
+
+	bad := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"missing root", "asset_id=missing", http.StatusNotFound},
+		{"trashed root", "asset_id=" + trashed.AssetID, http.StatusNotFound},
+		{"two roots", "portfolio_id=" + b.Portfolio + "&asset_id=" + b.Asset, http.StatusBadRequest},
+		{"negative depth", "depth=-1", http.StatusBadRequest},
+		{"bad depth", "depth=deep", http.StatusBadRequest},
+	}
+	for _, tt := range bad {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct{ Error, Code string }
+			if code := call(t, "GET", "/tree?"+tt.query, nil, &resp); code != tt.status || resp.Code == "" {
+				t.Errorf("%d %+v, want %d with a code", code, resp, tt.status)
+			}
+		})
+	}
+}
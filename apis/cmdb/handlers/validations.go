@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+var validationStatuses = map[string]bool{
+	"pending":   true,
+	"validated": true,
+	"edited":    true,
+	"no_match":  true,
+}
+
+type validationInput struct {
+	Status        *string         `json:"status"`
+	ApprovedData  json.RawMessage `json:"approved_data"`
+	EditsMade     *bool           `json:"edits_made"`
+	NoMatchAction *string         `json:"no_match_action"`
+	ValidatedBy   *string         `json:"validated_by"`
+}
+
+// approvedData returns the payload as JSON text, or nil when absent or null.
+func (in *validationInput) approvedData() *string {
+	if len(in.ApprovedData) == 0 || string(in.ApprovedData) == "null" {
+		return nil
+	}
+	s := string(in.ApprovedData)
+	return &s
+}
+
+// ListWorkloadValidations returns a workload's validation history, newest first.
+func ListWorkloadValidations(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	rows, err := getDB().QueryContext(c,
+		"SELECT * FROM workload_validations WHERE workload_id = ? ORDER BY validated_at DESC, rowid DESC", id)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	results, err := scanRows(rows)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	if results == nil {
+		results = []map[string]any{}
+	}
+	jsonColumns(results, "approved_data")
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  results,
+		"count": len(results),
+	})
+}
+
+// CreateWorkloadValidation records a validation decision. Without an explicit
+// status it follows the sidepanel: no_match when no_match_action is set,
+// otherwise edited or validated depending on edits_made.
+func CreateWorkloadValidation(c *gin.Context) {
+	workloadID, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	var input validationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	edits := input.EditsMade != nil && *input.EditsMade
+	status := "validated"
+	switch {
+	case input.Status != nil:
+		status = *input.Status
+	case input.NoMatchAction != nil:
+		status = "no_match"
+	case edits:
+		status = "edited"
+	}
+	if !validationStatuses[status] {
+		badRequest(c, "invalid status: "+status)
+		return
+	}
+
+	approved := input.approvedData()
+	action := input.NoMatchAction
+	if status == "no_match" {
+		approved = nil
+		if action == nil {
+			orphan := "orphan"
+			action = &orphan
+		}
+	}
+
+	_, err := scanRow(getDB(), c, "SELECT workload_id FROM workloads WHERE workload_id = ?", workloadID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, apiError{Error: "workload not found", Code: codeNotFound})
+		return
+	}
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+
+	id := newUUID()
+	var row map[string]any
+	err = transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		_, err := tx.ExecContext(c,
+			`INSERT INTO workload_validations (validation_id, workload_id, status, approved_data, edits_made, no_match_action, validated_by)
+			 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			id, workloadID, status, approved, edits, action, input.ValidatedBy)
+		if err != nil {
+			return nil, err
+		}
+		if row, err = getValidation(c, tx, workloadID, id); err != nil {
+			return nil, err
+		}
+		return store.Change("workload_validation", id, store.AuditCreate, nil, row).Relate("workload", workloadID), nil
+	})
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, row)
+}
+
+func GetWorkloadValidation(c *gin.Context) {
+	row, err := getValidation(c, getDB(), c.Param("id"), c.Param("validation_id"))
+	if err == sql.ErrNoRows {
+		storeError(c, store.ErrNotFound)
+		return
+	}
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, row)
+}
+
+func UpdateWorkloadValidation(c *gin.Context) {
+	workloadID, validationID := c.Param("id"), c.Param("validation_id")
+
+	var input validationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	if input.Status != nil && !validationStatuses[*input.Status] {
+		badRequest(c, "invalid status: "+*input.Status)
+		return
+	}
+
+	var row map[string]any
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		before, err := getValidation(c, tx, workloadID, validationID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(c,
+			`UPDATE workload_validations SET
+				status=COALESCE(?,status), approved_data=COALESCE(?,approved_data),
+				edits_made=COALESCE(?,edits_made), no_match_action=COALESCE(?,no_match_action),
+				validated_by=COALESCE(?,validated_by), validated_at=datetime('now'), updated_at=datetime('now')
+			 WHERE validation_id=? AND workload_id=?`,
+			input.Status, input.approvedData(), input.EditsMade, input.NoMatchAction, input.ValidatedBy,
+			validationID, workloadID)
+		if err != nil {
+			return nil, err
+		}
+		if row, err = getValidation(c, tx, workloadID, validationID); err != nil {
+			return nil, err
+		}
+		return store.Change("workload_validation", validationID, store.AuditUpdate, before, row).Relate("workload", workloadID), nil
+	})
+	if err == sql.ErrNoRows {
+		storeError(c, store.ErrNotFound)
+		return
+	}
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, row)
+}
+
+func DeleteWorkloadValidation(c *gin.Context) {
+	workloadID, validationID := c.Param("id"), c.Param("validation_id")
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		before, err := getValidation(c, tx, workloadID, validationID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(c,
+			"DELETE FROM workload_validations WHERE validation_id = ? AND workload_id = ?", validationID, workloadID)
+		if err != nil {
+			return nil, err
+		}
+		return store.Change("workload_validation", validationID, store.AuditDelete, before, nil).Relate("workload", workloadID), nil
+	})
+	if err == sql.ErrNoRows {
+		storeError(c, store.ErrNotFound)
+		return
+	}
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+func getValidation(c *gin.Context, db store.DBTX, workloadID, validationID string) (map[string]any, error) {
+	row, err := scanRow(db, c,
+		"SELECT * FROM workload_validations WHERE validation_id = ? AND workload_id = ?", validationID, workloadID)
+	if err != nil {
+		return nil, err
+	}
+	jsonColumns([]map[string]any{row}, "approved_data")
+	return row, nil
+}
+
+// latestValidation returns the most recent validation for a workload, or nil.
+func latestValidation(c *gin.Context, workloadID any) (map[string]any, error) {
+	row, err := scanRow(getDB(), c,
+		`SELECT * FROM workload_validations WHERE workload_id = ?
+		 ORDER BY validated_at DESC, rowid DESC LIMIT 1`, workloadID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	jsonColumns([]map[string]any{row}, "approved_data")
+	return row, nil
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+// ListVocabularies returns every managed vocabulary with its terms.
+func ListVocabularies(c *gin.Context) {
+	out := make([]gin.H, 0, len(store.Vocabularies))
+	for _, v := range store.Vocabularies {
+		terms, err := getStore().Vocabularies.List(c, v)
+		if err != nil {
+			storeError(c, err)
+			return
+		}
+		out = append(out, gin.H{"vocabulary": v, "terms": terms})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out, "count": len(out)})
+}
+
+// ListVocabularyTerms returns one vocabulary's terms in sort order.
+func ListVocabularyTerms(c *gin.Context) {
+	terms, err := getStore().Vocabularies.List(c, c.Param("vocabulary"))
+	respondAll(c, terms, err)
+}
+
+func GetVocabularyTerm(c *gin.Context) {
+	t, err := getStore().Vocabularies.Get(c, c.Param("vocabulary"), c.Param("value"))
+	respondOne(c, t, err)
+}
+
+func CreateVocabularyTerm(c *gin.Context) {
+	var input struct {
+		Value     string   `json:"value" binding:"required"`
+		Label     *string  `json:"label"`
+		Color     *string  `json:"color"`
+		SortOrder int      `json:"sort_order"`
+		Aliases   []string `json:"aliases"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	t := &store.Term{
+		Vocabulary: c.Param("vocabulary"),
+		Value:      input.Value,
+		Label:      input.Label,
+		Color:      input.Color,
+		SortOrder:  input.SortOrder,
+		Aliases:    input.Aliases,
+	}
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		if err := store.New(tx).Vocabularies.Create(c, t); err != nil {
+			return nil, err
+		}
+		return store.Change("vocabulary_term", termID(t.Vocabulary, t.Value), store.AuditCreate, nil, t), nil
+	})
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	respondCreated(c, t)
+}
+
+func UpdateVocabularyTerm(c *gin.Context) {
+	var input store.TermUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	vocabulary, value := c.Param("vocabulary"), c.Param("value")
+	var t *store.Term
+	err := transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		s := store.New(tx)
+		before, err := s.Vocabularies.Get(c, vocabulary, value)
+		if err != nil {
+			return nil, err
+		}
+		if t, err = s.Vocabularies.Update(c, vocabulary, value, input); err != nil {
+			return nil, err
+		}
+		return store.Change("vocabulary_term", termID(vocabulary, value), store.AuditUpdate, before, t), nil
+	})
+	respondOne(c, t, err)
+}
+
+func DeleteVocabularyTerm(c *gin.Context) {
+	vocabulary, value := c.Param("vocabulary"), c.Param("value")
+	respondDeleted(c, transact(c, func(tx store.DBTX) (*store.AuditEntry, error) {
+		s := store.New(tx)
+		before, err := s.Vocabularies.Get(c, vocabulary, value)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Vocabularies.Delete(c, vocabulary, value); err != nil {
+			return nil, err
+		}
+		return store.Change("vocabulary_term", termID(vocabulary, value), store.AuditDelete, before, nil), nil
+	}))
+}
+
+// termID is a term's id in the audit log.
+func termID(vocabulary, value string) string {
+	return vocabulary + "/" + value
+}
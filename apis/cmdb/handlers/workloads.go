@@ -1,225 +1,215 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jihaia/aperture/apis/cmdb/store"
 )
 
-var ListWorkloads = listHandler("workloads", "hostname", func(c *gin.Context, qb *queryBuilder) {
-	if q := c.Query("q"); q != "" {
-		qb.addLike("hostname", q)
-	}
-	if hostname := c.Query("hostname"); hostname != "" {
-		qb.addFilter("hostname = ?", hostname)
-	}
-	if ip := c.Query("ip"); ip != "" {
-		qb.addFilter("ip_address = ?", ip)
+func ListWorkloads(c *gin.Context) {
+	list, err := getStore().Workloads.List(c, store.WorkloadFilter{
+		Q:         c.Query("q"),
+		IPAddress: c.Query("ip"),
+		Page:      listPage(c),
+		Filter:    listFilter(c),
+	})
+	respondList(c, list, err)
+}
+
+// workloadsUnder lists the workloads linked beneath one node of the
+// hierarchy, with the components that link them. Workload filters, sort
+// and paging work as on /workloads.
+func workloadsUnder(scope store.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := idParam(c)
+		if !ok {
+			return
+		}
+		list, err := getStore().Workloads.ListUnder(c, scope, id, store.WorkloadFilter{
+			Q:         c.Query("q"),
+			IPAddress: c.Query("ip"),
+			Page:      listPage(c),
+			Filter:    listFilter(c),
+		})
+		respondList(c, list, err)
 	}
-})
+}
 
-var GetWorkload = getByPK("workloads", "workload_id")
-var DeleteWorkload = deleteByPK("workloads", "workload_id")
+var (
+	ListPortfolioWorkloads   = workloadsUnder(store.ScopePortfolio)
+	ListAssetWorkloads       = workloadsUnder(store.ScopeAsset)
+	ListAppGroupingWorkloads = workloadsUnder(store.ScopeAppGrouping)
+	ListApplicationWorkloads = workloadsUnder(store.ScopeApplication)
+)
 
-func CreateWorkload(c *gin.Context) {
-	var input struct {
-		Hostname    string  `json:"hostname" binding:"required"`
-		SnowSysId   *string `json:"snow_sys_id"`
-		IPAddress   *string `json:"ip_address"`
-		FQDN        *string `json:"fqdn"`
-		OS          *string `json:"os"`
-		Environment *string `json:"environment"`
-		Location    *string `json:"location"`
-		ClassType   *string `json:"class_type"`
-		IsVirtual   *int    `json:"is_virtual"`
-		Description *string `json:"description"`
-	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func GetWorkload(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
 		return
 	}
+	w, err := getStore().Workloads.Get(c, id)
+	respondOne(c, w, err)
+}
 
-	id := newUUID()
-	_, err := getDB().ExecContext(c,
-		`INSERT INTO workloads (workload_id, hostname, snow_sys_id, ip_address, fqdn, os, environment, location, class_type, is_virtual, description)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		id, input.Hostname, input.SnowSysId, input.IPAddress, input.FQDN, input.OS,
-		input.Environment, input.Location, input.ClassType, input.IsVirtual, input.Description)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func DeleteWorkload(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
 		return
 	}
-
-	row, err := scanRow(getDB(), c, "SELECT * FROM workloads WHERE workload_id = ?", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusCreated, row)
+	if c.Query("dry_run") == "true" {
+		impact, err := getStore().Workloads.DeleteImpact(c, id, version)
+		respondImpact(c, impact, err)
+		return
+	}
+	respondDeleted(c, audited(c, "workload", store.AuditDelete, id, func(s *store.Store) (string, error) {
+		return id, s.Workloads.Delete(c, id, version)
+	}))
 }
 
-func BulkUpsertWorkloads(c *gin.Context) {
+func CreateWorkload(c *gin.Context) {
 	var input struct {
-		Workloads []struct {
-			Hostname    string  `json:"hostname"`
-			IPAddress   *string `json:"ip_address"`
-			FQDN        *string `json:"fqdn"`
-			OS          *string `json:"os"`
-			Environment *string `json:"environment"`
-			Location    *string `json:"location"`
-			ClassType   *string `json:"class_type"`
-			IsVirtual   *int    `json:"is_virtual"`
-			Description *string `json:"description"`
-		} `json:"workloads" binding:"required"`
+		Hostname    string            `json:"hostname" binding:"required"`
+		SnowSysId   *string           `json:"snow_sys_id"`
+		IPAddress   *string           `json:"ip_address"`
+		FQDN        *string           `json:"fqdn"`
+		OS          *string           `json:"os"`
+		Environment *string           `json:"environment"`
+		Location    *string           `json:"location"`
+		ClassType   *string           `json:"class_type"`
+		IsVirtual   *store.Bool       `json:"is_virtual"`
+		Description *string           `json:"description"`
+		Interfaces  []store.Interface `json:"interfaces"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
 
-	tx, err := getDB().BeginTx(c, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	w := &store.Workload{
+		Hostname:    input.Hostname,
+		SnowSysID:   input.SnowSysId,
+		IPAddress:   input.IPAddress,
+		FQDN:        input.FQDN,
+		OS:          input.OS,
+		Environment: input.Environment,
+		Location:    input.Location,
+		ClassType:   input.ClassType,
+		IsVirtual:   input.IsVirtual != nil && bool(*input.IsVirtual),
+		Description: input.Description,
+		Interfaces:  input.Interfaces,
 	}
-	defer tx.Rollback()
-
-	stmt, err := tx.PrepareContext(c,
-		`INSERT INTO workloads (workload_id, hostname, ip_address, fqdn, os, environment, location, class_type, is_virtual, description)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		 ON CONFLICT(hostname) DO UPDATE SET
-		   ip_address=COALESCE(excluded.ip_address, ip_address),
-		   fqdn=COALESCE(excluded.fqdn, fqdn),
-		   os=COALESCE(excluded.os, os),
-		   environment=COALESCE(excluded.environment, environment),
-		   location=COALESCE(excluded.location, location),
-		   class_type=COALESCE(excluded.class_type, class_type),
-		   is_virtual=COALESCE(excluded.is_virtual, is_virtual),
-		   description=COALESCE(excluded.description, description),
-		   updated_at=datetime('now')`)
+	err := audited(c, "workload", store.AuditCreate, "", func(s *store.Store) (string, error) {
+		err := s.Workloads.Create(c, w)
+		return w.WorkloadID, err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
-	defer stmt.Close()
+	respondCreated(c, w)
+}
 
-	// Pre-load existing hostnames for created vs updated tracking
-	existingHostnames := map[string]bool{}
-	rows, err := tx.QueryContext(c, "SELECT hostname FROM workloads")
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var h string
-			rows.Scan(&h)
-			existingHostnames[h] = true
-		}
+// BulkUpsertWorkloads inserts or updates workloads by hostname.
+func BulkUpsertWorkloads(c *gin.Context) {
+	var input struct {
+		Workloads []store.WorkloadUpdate `json:"workloads" binding:"required"`
 	}
-
-	created, updated, errors := 0, 0, 0
-	for _, w := range input.Workloads {
-		if w.Hostname == "" {
-			errors++
-			continue
-		}
-
-		_, err := stmt.ExecContext(c,
-			newUUID(), w.Hostname, w.IPAddress, w.FQDN, w.OS,
-			w.Environment, w.Location, w.ClassType, w.IsVirtual, w.Description)
-		if err != nil {
-			errors++
-			continue
-		}
-		if existingHostnames[w.Hostname] {
-			updated++
-		} else {
-			created++
-			existingHostnames[w.Hostname] = true
-		}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
+		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	result, err := importWorkloads(c, "bulk", input.Workloads)
+	if err != nil {
+		storeError(c, err)
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"created": created,
-		"updated": updated,
-		"errors":  errors,
-		"total":   len(input.Workloads),
-	})
+	c.JSON(http.StatusOK, result)
 }
 
+// hierarchyQuery selects each component linking a workload with its full
+// hierarchy; callers add the WHERE and ORDER BY.
+const hierarchyQuery = `SELECT c.component_id, c.name AS component_name, c.description AS component_description,
+	        ct.label AS component_type, ct.color AS component_color,
+	        a.application_id, a.name AS application_name,
+	        ag.app_grouping_id, ag.name AS app_grouping_name,
+	        ast.asset_id, ast.name AS asset_name, ast.criticality, ast.environment AS asset_environment,
+	        p.portfolio_id, p.name AS portfolio_name
+	 FROM component_workloads cw
+	 JOIN components c ON c.component_id = cw.component_id AND c.deleted_at IS NULL
+	 LEFT JOIN component_types ct ON ct.component_type_id = c.component_type_id
+	 JOIN applications a ON a.application_id = c.application_id
+	 JOIN app_groupings ag ON ag.app_grouping_id = a.app_grouping_id
+	 JOIN assets ast ON ast.asset_id = ag.asset_id
+	 JOIN portfolios p ON p.portfolio_id = ast.portfolio_id`
+
 // LookupWorkload finds a workload by hostname or IP and returns it with
-// its full hierarchy: components → applications → app_groupings → assets → portfolios.
+// its full hierarchy: components → applications → app_groupings → assets → portfolios,
+// plus its latest validation (or null).
 func LookupWorkload(c *gin.Context) {
 	hostname := c.Query("hostname")
 	ip := c.Query("ip")
 
 	if hostname == "" && ip == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "hostname or ip required"})
+		badRequest(c, "hostname or ip required")
 		return
 	}
 
 	// Find the workload
-	var query string
-	var arg string
+	var workload *store.Workload
+	var err error
 	if hostname != "" {
-		query = "SELECT * FROM workloads WHERE hostname = ?"
-		arg = hostname
+		workload, err = getStore().Workloads.GetByHostname(c, hostname)
 	} else {
-		query = "SELECT * FROM workloads WHERE ip_address = ?"
-		arg = ip
+		workload, err = getStore().Workloads.GetByIP(c, ip)
 	}
-
-	workload, err := scanRow(getDB(), c, query, arg)
-	if err == sql.ErrNoRows {
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusOK, gin.H{"workload": nil})
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
 
-	workloadID := workload["workload_id"]
+	workloadID := workload.WorkloadID
 
 	// Fetch linked components via junction table, with full hierarchy
 	rows, err := getDB().QueryContext(c,
-		`SELECT c.component_id, c.name AS component_name, c.description AS component_description,
-		        ct.label AS component_type, ct.color AS component_color,
-		        a.application_id, a.name AS application_name,
-		        ag.app_grouping_id, ag.name AS app_grouping_name,
-		        ast.asset_id, ast.name AS asset_name, ast.criticality, ast.environment AS asset_environment,
-		        p.portfolio_id, p.name AS portfolio_name
-		 FROM component_workloads cw
-		 JOIN components c ON c.component_id = cw.component_id
-		 LEFT JOIN component_types ct ON ct.component_type_id = c.component_type_id
-		 JOIN applications a ON a.application_id = c.application_id
-		 JOIN app_groupings ag ON ag.app_grouping_id = a.app_grouping_id
-		 JOIN assets ast ON ast.asset_id = ag.asset_id
-		 JOIN portfolios p ON p.portfolio_id = ast.portfolio_id
-		 WHERE cw.workload_id = ?
-		 ORDER BY p.name, ast.name, a.name, c.name`, workloadID)
+		hierarchyQuery+" WHERE cw.workload_id = ? ORDER BY p.name, ast.name, a.name, c.name", workloadID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
 	defer rows.Close()
 
 	hierarchy, err := scanRows(rows)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		storeError(c, err)
 		return
 	}
 	if hierarchy == nil {
 		hierarchy = []map[string]any{}
 	}
 
+	validation, err := latestValidation(c, workloadID)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"workload":  workload,
-		"hierarchy": hierarchy,
+		"workload":   workload,
+		"hierarchy":  hierarchy,
+		"validation": validation,
 	})
 }
 
@@ -228,44 +218,102 @@ func UpdateWorkload(c *gin.Context) {
 	if !ok {
 		return
 	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	var input store.WorkloadUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	var w *store.Workload
+	err := audited(c, "workload", store.AuditUpdate, id, func(s *store.Store) (string, error) {
+		var err error
+		w, err = s.Workloads.Update(c, id, version, input)
+		return id, err
+	})
+	respondOne(c, w, err)
+}
+
+// PatchWorkload applies a JSON Merge Patch to one workload.
+var PatchWorkload = mergePatch("workload", func(s *store.Store) patchFunc[store.Workload] { return s.Workloads.Patch })
 
+// maxLookupIdentifiers bounds one batch lookup request.
+const maxLookupIdentifiers = 5000
+
+// BatchLookupWorkloads resolves many hostnames, FQDNs, IPs and CIDR ranges
+// at once, returning each match with its workload and hierarchy, the
+// identifiers that matched nothing or are malformed, and those with more
+// matches than store.MaxLookupMatches, which are cut to that many.
+func BatchLookupWorkloads(c *gin.Context) {
 	var input struct {
-		Hostname    *string `json:"hostname"`
-		SnowSysId   *string `json:"snow_sys_id"`
-		IPAddress   *string `json:"ip_address"`
-		FQDN        *string `json:"fqdn"`
-		OS          *string `json:"os"`
-		Environment *string `json:"environment"`
-		Location    *string `json:"location"`
-		ClassType   *string `json:"class_type"`
-		IsVirtual   *int    `json:"is_virtual"`
-		Description *string `json:"description"`
+		Identifiers []string `json:"identifiers" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
 		return
 	}
-
-	_, err := getDB().ExecContext(c,
-		`UPDATE workloads SET
-			hostname=COALESCE(?,hostname), snow_sys_id=COALESCE(?,snow_sys_id),
-			ip_address=COALESCE(?,ip_address), fqdn=COALESCE(?,fqdn),
-			os=COALESCE(?,os), environment=COALESCE(?,environment),
-			location=COALESCE(?,location),
-			class_type=COALESCE(?,class_type), is_virtual=COALESCE(?,is_virtual),
-			description=COALESCE(?,description), updated_at=datetime('now')
-		 WHERE workload_id=?`,
-		input.Hostname, input.SnowSysId, input.IPAddress, input.FQDN,
-		input.OS, input.Environment, input.Location, input.ClassType, input.IsVirtual, input.Description, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if len(input.Identifiers) > maxLookupIdentifiers {
+		badRequest(c, fmt.Sprintf("at most %d identifiers per request", maxLookupIdentifiers))
 		return
 	}
 
-	row, err := scanRow(getDB(), c, "SELECT * FROM workloads WHERE workload_id = ?", id)
+	lookup, err := getStore().Workloads.Lookup(c, input.Identifiers)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		storeError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, row)
+	matches := lookup.Matches
+
+	// Load the hierarchy of every matched workload in one query.
+	hierarchies := map[string][]map[string]any{}
+	var ids []any
+	for _, m := range matches {
+		if _, ok := hierarchies[m.Workload.WorkloadID]; !ok {
+			hierarchies[m.Workload.WorkloadID] = []map[string]any{}
+			ids = append(ids, m.Workload.WorkloadID)
+		}
+	}
+	if len(ids) > 0 {
+		rows, err := getDB().QueryContext(c,
+			strings.Replace(hierarchyQuery, "SELECT ", "SELECT cw.workload_id, ", 1)+
+				" WHERE cw.workload_id IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")+")"+
+				" ORDER BY p.name, ast.name, a.name, c.name", ids...)
+		if err != nil {
+			storeError(c, err)
+			return
+		}
+		defer rows.Close()
+
+		results, err := scanRows(rows)
+		if err != nil {
+			storeError(c, err)
+			return
+		}
+		for _, r := range results {
+			id, _ := r["workload_id"].(string)
+			delete(r, "workload_id")
+			hierarchies[id] = append(hierarchies[id], r)
+		}
+	}
+
+	type result struct {
+		store.Match
+		Hierarchy []map[string]any `json:"hierarchy"`
+	}
+	data := make([]result, len(matches))
+	for i, m := range matches {
+		data[i] = result{Match: m, Hierarchy: hierarchies[m.Workload.WorkloadID]}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      data,
+		"count":     len(data),
+		"not_found": lookup.NotFound,
+		"invalid":   lookup.Invalid,
+		"truncated": lookup.Truncated,
+	})
 }
@@ -0,0 +1,188 @@
+// Package illumio is a minimal client for the Illumio PCE REST API (v2).
+// It mirrors the extension's illumio-client.ts so that syncs can run
+// server-side instead of inside a Chrome service worker.
+package illumio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned by FromEnv when the PCE settings are missing.
+var ErrNotConfigured = errors.New("illumio: PCE not configured (set ILLUMIO_PCE_URL, ILLUMIO_API_KEY_ID, ILLUMIO_API_KEY_SECRET)")
+
+// Client talks to a single PCE org.
+type Client struct {
+	BaseURL   string
+	OrgID     int
+	apiKeyID  string
+	apiSecret string
+
+	HTTP *http.Client
+
+	// MaxRetries is how many times a request is retried on 429, 5xx
+	// or transport errors. Backoff doubles after each attempt.
+	MaxRetries int
+	Backoff    time.Duration
+
+	// PollInterval and PollTimeout control async job polling.
+	// JobBackoff is the first wait before asking again for an export
+	// while another is still running; it doubles after each attempt.
+	PollInterval time.Duration
+	PollTimeout  time.Duration
+	JobBackoff   time.Duration
+}
+
+// APIError is a non-2xx response from the PCE.
+type APIError struct {
+	Method string
+	Path   string
+	Status int
+	Body   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("illumio API error: %s %s: %d %s", e.Method, e.Path, e.Status, e.Body)
+}
+
+// NewClient creates a client for the given PCE URL and API key.
+func NewClient(pceURL, apiKeyID, apiKeySecret string, orgID int) *Client {
+	if orgID == 0 {
+		orgID = 1
+	}
+	return &Client{
+		BaseURL:      strings.TrimRight(pceURL, "/"),
+		OrgID:        orgID,
+		apiKeyID:     apiKeyID,
+		apiSecret:    apiKeySecret,
+		HTTP:         &http.Client{Timeout: 60 * time.Second},
+		MaxRetries:   4,
+		Backoff:      time.Second,
+		PollInterval: 2 * time.Second,
+		PollTimeout:  10 * time.Minute,
+		JobBackoff:   5 * time.Second,
+	}
+}
+
+// FromEnv builds a client from ILLUMIO_PCE_URL, ILLUMIO_API_KEY_ID,
+// ILLUMIO_API_KEY_SECRET and ILLUMIO_ORG_ID (default 1).
+func FromEnv() (*Client, error) {
+	url := os.Getenv("ILLUMIO_PCE_URL")
+	id := os.Getenv("ILLUMIO_API_KEY_ID")
+	secret := os.Getenv("ILLUMIO_API_KEY_SECRET")
+	if url == "" || id == "" || secret == "" {
+		return nil, ErrNotConfigured
+	}
+	org := 1
+	if s := os.Getenv("ILLUMIO_ORG_ID"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("illumio: invalid ILLUMIO_ORG_ID %q", s)
+		}
+		org = v
+	}
+	return NewClient(url, id, secret, org), nil
+}
+
+// orgPath prefixes an org-scoped path, e.g. orgPath("/workloads").
+func (c *Client) orgPath(p string) string {
+	return fmt.Sprintf("/orgs/%d%s", c.OrgID, p)
+}
+
+// do sends a request to /api/v2{path} with retry/backoff and returns the
+// response. The caller must close the body. Extra headers may be supplied.
+func (c *Client) do(ctx context.Context, method, path string, body any, header http.Header) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = b
+	}
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+"/api/v2"+path, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(c.apiKeyID, c.apiSecret)
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		resp, err := c.HTTP.Do(req)
+		retry := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retry || attempt >= c.MaxRetries {
+			return resp, err
+		}
+
+		wait := backoff
+		if resp != nil {
+			if s := resp.Header.Get("Retry-After"); s != "" {
+				if secs, err := strconv.Atoi(s); err == nil {
+					wait = time.Duration(secs) * time.Second
+				}
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// request performs a JSON request and decodes the response into out (if non-nil).
+func (c *Client) request(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.do(ctx, method, path, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return apiError(method, path, resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// TestConnection verifies the credentials with a cheap read.
+func (c *Client) TestConnection(ctx context.Context) error {
+	var lists []IPList
+	return c.request(ctx, http.MethodGet, c.orgPath("/sec_policy/draft/ip_lists?max_results=1"), nil, &lists)
+}
+
+func apiError(method, path string, resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &APIError{Method: method, Path: path, Status: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
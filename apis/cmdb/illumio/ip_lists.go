@@ -0,0 +1,143 @@
+package illumio
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// IPListPrefix marks IP lists that Aperture manages on the PCE.
+const IPListPrefix = "Aperture:"
+
+// IPRange is one entry of an IP list.
+type IPRange struct {
+	FromIP      string `json:"from_ip"`
+	ToIP        string `json:"to_ip,omitempty"`
+	Description string `json:"description,omitempty"`
+	Exclusion   bool   `json:"exclusion,omitempty"`
+}
+
+// IPList is a draft-policy IP list.
+type IPList struct {
+	Href        string    `json:"href"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	IPRanges    []IPRange `json:"ip_ranges"`
+	CreatedAt   string    `json:"created_at,omitempty"`
+	UpdatedAt   string    `json:"updated_at,omitempty"`
+}
+
+// ListIPLists returns every draft IP list.
+func (c *Client) ListIPLists(ctx context.Context) ([]IPList, error) {
+	var lists []IPList
+	err := c.request(ctx, http.MethodGet, c.orgPath("/sec_policy/draft/ip_lists"), nil, &lists)
+	return lists, err
+}
+
+// ApertureIPLists returns the draft IP lists whose names start with IPListPrefix.
+func (c *Client) ApertureIPLists(ctx context.Context) ([]IPList, error) {
+	lists, err := c.ListIPLists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []IPList
+	for _, l := range lists {
+		if strings.HasPrefix(l.Name, IPListPrefix) {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+// GetIPListByName returns the draft IP list with the exact name, or nil.
+// The PCE name filter is a partial match, so results are checked here.
+func (c *Client) GetIPListByName(ctx context.Context, name string) (*IPList, error) {
+	var lists []IPList
+	err := c.request(ctx, http.MethodGet, c.orgPath("/sec_policy/draft/ip_lists?name="+url.QueryEscape(name)), nil, &lists)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lists {
+		if lists[i].Name == name {
+			return &lists[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateIPList creates a draft IP list.
+func (c *Client) CreateIPList(ctx context.Context, name, description string, ranges []IPRange) (*IPList, error) {
+	if description == "" {
+		description = "Created by Aperture"
+	}
+	var out IPList
+	err := c.request(ctx, http.MethodPost, c.orgPath("/sec_policy/draft/ip_lists"), map[string]any{
+		"name":        name,
+		"description": description,
+		"ip_ranges":   ranges,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateIPList replaces the ranges of an existing draft IP list.
+func (c *Client) UpdateIPList(ctx context.Context, href string, ranges []IPRange) error {
+	return c.request(ctx, http.MethodPut, href, map[string]any{"ip_ranges": ranges}, nil)
+}
+
+// DeleteIPList deletes a draft IP list.
+func (c *Client) DeleteIPList(ctx context.Context, href string) error {
+	return c.request(ctx, http.MethodDelete, href, nil, nil)
+}
+
+// DiffRanges compares two sets of ranges by from_ip/to_ip/exclusion and
+// returns the ranges only in want (added), only in have (removed), and in
+// both but with another description (changed, as in want). Addresses are
+// compared in canonical form, however the PCE writes them.
+func DiffRanges(have, want []IPRange) (added, removed, changed []IPRange) {
+	key := func(r IPRange) string {
+		return canonicalIP(r.FromIP) + "-" + canonicalIP(r.ToIP) + "-" + strconv.FormatBool(r.Exclusion)
+	}
+	inHave := map[string]IPRange{}
+	for _, r := range have {
+		inHave[key(r)] = r
+	}
+	inWant := map[string]bool{}
+	for _, r := range want {
+		inWant[key(r)] = true
+		h, ok := inHave[key(r)]
+		switch {
+		case !ok:
+			added = append(added, r)
+		case h.Description != r.Description:
+			changed = append(changed, r)
+		}
+	}
+	for _, r := range have {
+		if !inWant[key(r)] {
+			removed = append(removed, r)
+		}
+	}
+	return added, removed, changed
+}
+
+// canonicalIP returns an address in net/netip's form, or s unchanged if
+// it is not one.
+func canonicalIP(s string) string {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap().String()
+	}
+	return s
+}
+
+// ExplorerURL returns the PCE Explorer deep link for an IP list.
+func (c *Client) ExplorerURL(ipListHref string) string {
+	id := ipListHref[strings.LastIndex(ipListHref, "/")+1:]
+	return fmt.Sprintf("%s/app/#/orgs/%d/explorer?ip_list=%s", c.BaseURL, c.OrgID, id)
+}
@@ -0,0 +1,43 @@
+package illumio
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiffRanges(t *testing.T) {
+	have := []IPRange{
+		{FromIP: "10.0.0.1", Description: "web01"},
+		{FromIP: "10.0.0.2", Description: "web02"},
+		{FromIP: "2001:DB8::1", Description: "v6"},
+		{FromIP: "10.0.1.0", ToIP: "10.0.1.255", Description: "range"},
+		{FromIP: "10.0.0.9", Exclusion: true},
+	}
+	tests := []struct {
+		name                    string
+		want                    []IPRange
+		added, removed, changed string // from_ip of each
+	}{
+		{"same", have, "[]", "[]", "[]"},
+		{"redescribed", []IPRange{{FromIP: "10.0.0.1", Description: "web01 | ledger"}}, "[]", "[10.0.0.2 2001:DB8::1 10.0.1.0 10.0.0.9]", "[10.0.0.1]"},
+		{"canonical v6", []IPRange{{FromIP: "2001:db8:0::1", Description: "v6"}}, "[]", "[10.0.0.1 10.0.0.2 10.0.1.0 10.0.0.9]", "[]"},
+		{"range end differs", []IPRange{{FromIP: "10.0.1.0", ToIP: "10.0.1.127", Description: "range"}}, "[10.0.1.0]", "[10.0.0.1 10.0.0.2 2001:DB8::1 10.0.1.0 10.0.0.9]", "[]"},
+		{"exclusion differs", []IPRange{{FromIP: "10.0.0.9"}}, "[10.0.0.9]", "[10.0.0.1 10.0.0.2 2001:DB8::1 10.0.1.0 10.0.0.9]", "[]"},
+	}
+	from := func(rs []IPRange) string {
+		out := []string{}
+		for _, r := range rs {
+			out = append(out, r.FromIP)
+		}
+		return fmt.Sprint(out)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed, changed := DiffRanges(have, tt.want)
+			if from(added) != tt.added || from(removed) != tt.removed || from(changed) != tt.changed {
+				t.Errorf("added %s removed %s changed %s, want %s %s %s",
+					from(added), from(removed), from(changed), tt.added, tt.removed, tt.changed)
+			}
+		})
+	}
+}
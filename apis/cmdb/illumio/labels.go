@@ -0,0 +1,47 @@
+package illumio
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// LabelRef is a label as embedded in a workload.
+type LabelRef struct {
+	Href  string `json:"href"`
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// Label is a label object from /orgs/:org/labels.
+type Label struct {
+	Href      string `json:"href"`
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// ListLabels returns all labels, optionally restricted to one key (app, env, loc, role).
+func (c *Client) ListLabels(ctx context.Context, key string) ([]Label, error) {
+	path := c.orgPath("/labels")
+	if key != "" {
+		path += "?key=" + url.QueryEscape(key)
+	}
+	var labels []Label
+	err := c.request(ctx, http.MethodGet, path, nil, &labels)
+	return labels, err
+}
+
+// CreateLabel creates a label with the given key and value.
+func (c *Client) CreateLabel(ctx context.Context, key, value string) (*Label, error) {
+	var out Label
+	err := c.request(ctx, http.MethodPost, c.orgPath("/labels"), map[string]string{
+		"key":   key,
+		"value": value,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
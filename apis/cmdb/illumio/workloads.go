@@ -0,0 +1,184 @@
+package illumio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Interface is one network interface reported by a workload.
+type Interface struct {
+	Name      string `json:"name,omitempty"`
+	Address   string `json:"address"`
+	CIDRBlock *int   `json:"cidr_block,omitempty"`
+}
+
+// Workload is the subset of the PCE workload representation Aperture uses.
+type Workload struct {
+	Href        string      `json:"href"`
+	Name        string      `json:"name"`
+	Hostname    string      `json:"hostname"`
+	Description *string     `json:"description"`
+	OSID        string      `json:"os_id"`
+	OSDetail    string      `json:"os_detail"`
+	PublicIP    string      `json:"public_ip,omitempty"`
+	Online      bool        `json:"online,omitempty"`
+	Managed     bool        `json:"managed,omitempty"`
+	Interfaces  []Interface `json:"interfaces"`
+	Labels      []LabelRef  `json:"labels"`
+}
+
+// DisplayHostname returns the hostname, falling back to the workload name.
+func (w Workload) DisplayHostname() string {
+	if w.Hostname != "" {
+		return w.Hostname
+	}
+	return w.Name
+}
+
+// OS returns os_detail, falling back to os_id.
+func (w Workload) OS() string {
+	if w.OSDetail != "" {
+		return w.OSDetail
+	}
+	return w.OSID
+}
+
+// FirstIPv4 returns the address of the first IPv4 interface, or "".
+func (w Workload) FirstIPv4() string {
+	for _, i := range w.Interfaces {
+		if i.Address != "" && !strings.Contains(i.Address, ":") {
+			return i.Address
+		}
+	}
+	return ""
+}
+
+// Label returns the value of the label with the given key (env, loc, app, role), or "".
+func (w Workload) Label(key string) string {
+	for _, l := range w.Labels {
+		if l.Key == key {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// GetWorkloads returns up to limit workloads using the synchronous
+// collection API. The PCE caps this at 500; use GetAllWorkloads for more.
+func (c *Client) GetWorkloads(ctx context.Context, limit int) ([]Workload, error) {
+	var list []Workload
+	err := c.request(ctx, http.MethodGet, c.orgPath(fmt.Sprintf("/workloads?max_results=%d", limit)), nil, &list)
+	return list, err
+}
+
+// GetWorkloadByIP returns the first workload with the given IP, or nil.
+func (c *Client) GetWorkloadByIP(ctx context.Context, ip string) (*Workload, error) {
+	var list []Workload
+	err := c.request(ctx, http.MethodGet, c.orgPath("/workloads?ip_address="+url.QueryEscape(ip)), nil, &list)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+type asyncJob struct {
+	Status string `json:"status"`
+	Result struct {
+		Href string `json:"href"`
+	} `json:"result"`
+}
+
+// GetAllWorkloads fetches every workload using the async collection API:
+// request the export with Prefer: respond-async (retrying while a previous
+// job is still running), poll the job until it is done, then download the
+// result datafile.
+func (c *Client) GetAllWorkloads(ctx context.Context) ([]Workload, error) {
+	path := c.orgPath("/workloads")
+
+	// Step 1: kick off the job (409 means another export is still running)
+	var jobLocation string
+	backoff := c.JobBackoff
+	for attempt := 0; attempt < 5 && jobLocation == ""; attempt++ {
+		resp, err := c.do(ctx, http.MethodGet, path, nil, http.Header{"Prefer": {"respond-async"}})
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusAccepted:
+			jobLocation = resp.Header.Get("Location")
+			if jobLocation == "" {
+				return nil, errors.New("illumio async job: missing Location header")
+			}
+		case http.StatusConflict:
+			if err := sleep(ctx, backoff); err != nil {
+				return nil, err
+			}
+			backoff *= 2
+		default:
+			return nil, fmt.Errorf("illumio async job failed to start: %d", resp.StatusCode)
+		}
+	}
+	if jobLocation == "" {
+		return nil, errors.New("illumio async job: could not start after retries")
+	}
+
+	// Step 2: poll until done
+	var resultHref string
+	deadline := time.Now().Add(c.PollTimeout)
+	for resultHref == "" {
+		if time.Now().After(deadline) {
+			return nil, errors.New("illumio async job timed out")
+		}
+		if err := sleep(ctx, c.PollInterval); err != nil {
+			return nil, err
+		}
+
+		var job asyncJob
+		if err := c.request(ctx, http.MethodGet, jobLocation, nil, &job); err != nil {
+			return nil, fmt.Errorf("poll async job: %w", err)
+		}
+		switch job.Status {
+		case "done":
+			if job.Result.Href == "" {
+				return nil, errors.New("illumio async job: done without result href")
+			}
+			resultHref = job.Result.Href
+		case "failed":
+			return nil, errors.New("illumio async job failed")
+		}
+	}
+
+	// Step 3: download the datafile
+	resp, err := c.do(ctx, http.MethodGet, resultHref, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("illumio datafile download failed: %d", resp.StatusCode)
+	}
+
+	var list []Workload
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("decode datafile: %w", err)
+	}
+	return list, nil
+}
+
+// SetWorkloadLabels replaces the full label set of a workload.
+// Only the href of each label is sent.
+func (c *Client) SetWorkloadLabels(ctx context.Context, href string, labels []LabelRef) error {
+	refs := make([]map[string]string, len(labels))
+	for i, l := range labels {
+		refs[i] = map[string]string{"href": l.Href}
+	}
+	return c.request(ctx, http.MethodPut, href, map[string]any{"labels": refs}, nil)
+}
@@ -0,0 +1,127 @@
+package illumio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeExport is a PCE that serves the async workload export.
+type fakeExport struct {
+	conflicts int      // 409s before the job starts
+	start     int      // status once it starts, 202 if zero
+	location  string   // job href, sent as Location; none if empty
+	statuses  []string // job status per poll; the last repeats
+	result    string   // datafile href the done job points at
+	datafile  int      // datafile status, 200 if zero
+
+	polls int
+}
+
+func (f *fakeExport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch path := strings.TrimPrefix(r.URL.Path, "/api/v2"); {
+	case path == "/orgs/1/workloads":
+		if r.Header.Get("Prefer") != "respond-async" {
+			http.Error(w, "not async", http.StatusBadRequest)
+			return
+		}
+		if f.conflicts > 0 {
+			f.conflicts--
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if f.start != 0 {
+			w.WriteHeader(f.start)
+			return
+		}
+		w.Header().Set("Location", f.location)
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasPrefix(path, "/orgs/1/jobs/"):
+		status := f.statuses[min(f.polls, len(f.statuses)-1)]
+		f.polls++
+		job := map[string]any{"status": status}
+		if status == "done" {
+			job["result"] = map[string]string{"href": f.result}
+		}
+		json.NewEncoder(w).Encode(job)
+	case path == f.result:
+		if f.datafile != 0 {
+			w.WriteHeader(f.datafile)
+			return
+		}
+		json.NewEncoder(w).Encode([]Workload{
+			{Href: "/orgs/1/workloads/a", Hostname: "web01"},
+			{Href: "/orgs/1/workloads/b", Name: "db01"},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestGetAllWorkloads(t *testing.T) {
+	const job, data = "/orgs/1/jobs/1", "/orgs/1/datafiles/1"
+	tests := []struct {
+		name string
+		pce  fakeExport
+		err  string
+	}{
+		{name: "done", pce: fakeExport{location: job, statuses: []string{"done"}, result: data}},
+		{name: "polls until done", pce: fakeExport{location: job, statuses: []string{"pending", "running", "done"}, result: data}},
+		{name: "retries while another export runs", pce: fakeExport{conflicts: 2, location: job, statuses: []string{"done"}, result: data}},
+		{name: "still conflicting", pce: fakeExport{conflicts: 5}, err: "could not start after retries"},
+		{name: "rejected", pce: fakeExport{start: http.StatusForbidden}, err: "failed to start: 403"},
+		{name: "no location", pce: fakeExport{}, err: "missing Location header"},
+		{name: "job failed", pce: fakeExport{location: job, statuses: []string{"running", "failed"}}, err: "async job failed"},
+		{name: "done without result", pce: fakeExport{location: job, statuses: []string{"done"}}, err: "done without result href"},
+		{name: "never done", pce: fakeExport{location: job, statuses: []string{"running"}}, err: "timed out"},
+		{name: "datafile missing", pce: fakeExport{location: job, statuses: []string{"done"}, result: data, datafile: http.StatusNotFound}, err: "download failed: 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(&tt.pce)
+			defer srv.Close()
+			c := NewClient(srv.URL, "key", "secret", 0)
+			c.MaxRetries = 0
+			c.PollInterval = time.Millisecond
+			c.PollTimeout = 50 * time.Millisecond
+			c.JobBackoff = time.Millisecond
+
+			list, err := c.GetAllWorkloads(context.Background())
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, w := range list {
+				names = append(names, w.DisplayHostname())
+			}
+			if fmt.Sprint(names) != "[web01 db01]" {
+				t.Errorf("workloads = %v", names)
+			}
+		})
+	}
+}
+
+func TestGetAllWorkloadsCanceled(t *testing.T) {
+	srv := httptest.NewServer(&fakeExport{location: "/orgs/1/jobs/1", statuses: []string{"running"}})
+	defer srv.Close()
+	c := NewClient(srv.URL, "key", "secret", 0)
+	c.PollInterval = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetAllWorkloads(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's", err)
+	}
+}
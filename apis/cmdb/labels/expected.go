@@ -0,0 +1,115 @@
+package labels
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Expected is the label set a workload should carry on the PCE.
+type Expected struct {
+	WorkloadID string            `json:"workload_id"`
+	Hostname   string            `json:"hostname"`
+	Labels     map[string]string `json:"labels"`
+	// Conflicts lists keys whose source yields different values through
+	// different components; those keys are left out of Labels.
+	Conflicts map[string][]string `json:"conflicts,omitempty"`
+}
+
+// expectedQuery selects one row per workload × linked component with every
+// mapping source as a column. Unlinked workloads yield a single row.
+func expectedQuery(mappings []Mapping, where string) string {
+	cols := []string{"w.workload_id", "w.hostname"}
+	for i, m := range mappings {
+		cols = append(cols, fmt.Sprintf("%s AS m%d", Sources[m.Source], i))
+	}
+	return `SELECT ` + strings.Join(cols, ", ") + `
+		FROM workloads w
+		LEFT JOIN (component_workloads cw
+			JOIN components c ON c.component_id = cw.component_id AND c.deleted_at IS NULL) ON cw.workload_id = w.workload_id
+		LEFT JOIN component_types ct ON ct.component_type_id = c.component_type_id
+		LEFT JOIN component_classes cc ON cc.component_class_id = c.component_class_id
+		LEFT JOIN component_classes tcc ON tcc.component_class_id = ct.component_class_id
+		LEFT JOIN applications a ON a.application_id = c.application_id
+		LEFT JOIN app_groupings ag ON ag.app_grouping_id = a.app_grouping_id
+		LEFT JOIN assets ast ON ast.asset_id = ag.asset_id
+		LEFT JOIN portfolios p ON p.portfolio_id = ast.portfolio_id` + where + `
+		ORDER BY w.hostname`
+}
+
+// Compute returns the expected labels for the given workloads (all when
+// workloadIDs is empty), in hostname order.
+func Compute(ctx context.Context, db *sql.DB, mappings []Mapping, workloadIDs []string) ([]*Expected, error) {
+	var where string
+	var args []any
+	if len(workloadIDs) > 0 {
+		where = " WHERE w.workload_id IN (?" + strings.Repeat(", ?", len(workloadIDs)-1) + ")"
+		for _, id := range workloadIDs {
+			args = append(args, id)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, expectedQuery(mappings, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query hierarchy: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*Expected
+	byID := map[string]*Expected{}
+	values := map[string]map[string]map[string]bool{} // workload → key → set of values
+
+	for rows.Next() {
+		var id, hostname string
+		vals := make([]sql.NullString, len(mappings))
+		dest := []any{&id, &hostname}
+		for i := range vals {
+			dest = append(dest, &vals[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		e, ok := byID[id]
+		if !ok {
+			e = &Expected{WorkloadID: id, Hostname: hostname, Labels: map[string]string{}}
+			byID[id] = e
+			list = append(list, e)
+			values[id] = map[string]map[string]bool{}
+		}
+		for i, m := range mappings {
+			v := strings.TrimSpace(vals[i].String)
+			if v == "" {
+				continue
+			}
+			if values[id][m.Key] == nil {
+				values[id][m.Key] = map[string]bool{}
+			}
+			values[id][m.Key][v] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, e := range list {
+		for key, set := range values[e.WorkloadID] {
+			if len(set) == 1 {
+				for v := range set {
+					e.Labels[key] = v
+				}
+				continue
+			}
+			if e.Conflicts == nil {
+				e.Conflicts = map[string][]string{}
+			}
+			for v := range set {
+				e.Conflicts[key] = append(e.Conflicts[key], v)
+			}
+			sort.Strings(e.Conflicts[key])
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,74 @@
+// Package labels derives the expected Illumio app/env/loc/role labels for
+// each workload from its BIA hierarchy and pushes them to the PCE.
+package labels
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// Sources maps each supported mapping source to the SQL expression that
+// yields it in the hierarchy query (see expectedQuery).
+var Sources = map[string]string{
+	"portfolio.name":        "p.name",
+	"asset.name":            "ast.name",
+	"asset.full_name":       "ast.full_name",
+	"asset.environment":     "ast.environment",
+	"asset.criticality":     "ast.criticality",
+	"asset.category":        "ast.category",
+	"app_grouping.name":     "ag.name",
+	"application.name":      "a.name",
+	"component.name":        "c.name",
+	"component_class.name":  "COALESCE(cc.name, tcc.name)",
+	"component_class.label": "COALESCE(cc.label, tcc.label)",
+	"component_type.label":  "ct.label",
+	"workload.environment":  "w.environment",
+	"workload.location":     "w.location",
+	"workload.class_type":   "w.class_type",
+}
+
+// Mapping is one PCE label key and the CMDB source it is derived from.
+type Mapping struct {
+	Key    string `json:"label_key"`
+	Source string `json:"source"`
+}
+
+// ValidSource reports whether s is a known mapping source.
+func ValidSource(s string) bool {
+	_, ok := Sources[s]
+	return ok
+}
+
+// SourceNames returns the supported sources, sorted.
+func SourceNames() []string {
+	names := make([]string, 0, len(Sources))
+	for s := range Sources {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadMappings reads the configured mappings from label_mappings.
+func LoadMappings(ctx context.Context, db *sql.DB) ([]Mapping, error) {
+	rows, err := db.QueryContext(ctx, "SELECT label_key, source FROM label_mappings ORDER BY label_key")
+	if err != nil {
+		return nil, fmt.Errorf("query label_mappings: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Mapping
+	for rows.Next() {
+		var m Mapping
+		if err := rows.Scan(&m.Key, &m.Source); err != nil {
+			return nil, err
+		}
+		if !ValidSource(m.Source) {
+			return nil, fmt.Errorf("label mapping %q: unknown source %q", m.Key, m.Source)
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
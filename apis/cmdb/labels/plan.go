@@ -0,0 +1,144 @@
+package labels
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jihaia/aperture/apis/cmdb/illumio"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+// Change is one label key that differs on the PCE.
+type Change struct {
+	Key  string `json:"key"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// WorkloadPlan is the set of label changes planned for one PCE workload.
+// Workloads with only conflicting keys are listed with no changes.
+type WorkloadPlan struct {
+	WorkloadID  string              `json:"workload_id"`
+	Hostname    string              `json:"hostname"`
+	IllumioHref string              `json:"illumio_href"`
+	Changes     []Change            `json:"changes"`
+	Conflicts   map[string][]string `json:"conflicts,omitempty"`
+
+	current []illumio.LabelRef
+}
+
+// Plan is the outcome of comparing expected labels against the PCE.
+type Plan struct {
+	Updates        []*WorkloadPlan `json:"updates"`
+	InSync         int             `json:"in_sync"`
+	NotInPCE       []string        `json:"not_in_pce"`
+	LabelsToCreate []Change        `json:"labels_to_create"`
+}
+
+// BuildPlan matches expected label sets to PCE workloads by hostname, as
+// store.HostIndex does, and lists the keys whose PCE value differs.
+// existing is the PCE's current label catalogue, used to report which
+// label objects would have to be created.
+func BuildPlan(expected []*Expected, pce []illumio.Workload, existing []illumio.Label) *Plan {
+	hosts := store.NewHostIndex[*illumio.Workload]()
+	for i := range pce {
+		hosts.Add(&pce[i], pce[i].DisplayHostname())
+	}
+
+	have := map[string]bool{}
+	for _, l := range existing {
+		have[l.Key+"\x00"+l.Value] = true
+	}
+
+	plan := &Plan{Updates: []*WorkloadPlan{}, NotInPCE: []string{}, LabelsToCreate: []Change{}}
+	for _, e := range expected {
+		if len(e.Labels) == 0 && len(e.Conflicts) == 0 {
+			continue
+		}
+		w, ok := hosts.First(e.Hostname)
+		if !ok {
+			plan.NotInPCE = append(plan.NotInPCE, e.Hostname)
+			continue
+		}
+
+		wp := &WorkloadPlan{
+			WorkloadID:  e.WorkloadID,
+			Hostname:    e.Hostname,
+			IllumioHref: w.Href,
+			Conflicts:   e.Conflicts,
+			Changes:     []Change{},
+			current:     w.Labels,
+		}
+		keys := make([]string, 0, len(e.Labels))
+		for key := range e.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			want := e.Labels[key]
+			if got := w.Label(key); got != want {
+				wp.Changes = append(wp.Changes, Change{Key: key, From: got, To: want})
+				if !have[key+"\x00"+want] {
+					have[key+"\x00"+want] = true
+					plan.LabelsToCreate = append(plan.LabelsToCreate, Change{Key: key, To: want})
+				}
+			}
+		}
+		if len(wp.Changes) == 0 && len(wp.Conflicts) == 0 {
+			plan.InSync++
+			continue
+		}
+		plan.Updates = append(plan.Updates, wp)
+	}
+	return plan
+}
+
+// ApplyResult reports what Apply did.
+type ApplyResult struct {
+	LabelsCreated int      `json:"labels_created"`
+	Updated       int      `json:"updated"`
+	Errors        []string `json:"errors"`
+}
+
+// Apply creates any missing labels and replaces the managed keys on each
+// planned workload, keeping labels of keys that are not being changed.
+func Apply(ctx context.Context, client *illumio.Client, plan *Plan, existing []illumio.Label) (*ApplyResult, error) {
+	res := &ApplyResult{Errors: []string{}}
+
+	hrefs := map[string]string{}
+	for _, l := range existing {
+		hrefs[l.Key+"\x00"+l.Value] = l.Href
+	}
+	for _, l := range plan.LabelsToCreate {
+		created, err := client.CreateLabel(ctx, l.Key, l.To)
+		if err != nil {
+			return res, fmt.Errorf("create label %s=%s: %w", l.Key, l.To, err)
+		}
+		hrefs[l.Key+"\x00"+l.To] = created.Href
+		res.LabelsCreated++
+	}
+
+	for _, wp := range plan.Updates {
+		if len(wp.Changes) == 0 {
+			continue
+		}
+		changed := map[string]bool{}
+		var set []illumio.LabelRef
+		for _, ch := range wp.Changes {
+			changed[ch.Key] = true
+			set = append(set, illumio.LabelRef{Href: hrefs[ch.Key+"\x00"+ch.To], Key: ch.Key, Value: ch.To})
+		}
+		for _, l := range wp.current {
+			if !changed[l.Key] {
+				set = append(set, l)
+			}
+		}
+		if err := client.SetWorkloadLabels(ctx, wp.IllumioHref, set); err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", wp.Hostname, err))
+			continue
+		}
+		res.Updated++
+	}
+	return res, nil
+}
@@ -0,0 +1,146 @@
+package labels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jihaia/aperture/apis/cmdb/illumio"
+)
+
+func pceWorkload(href, hostname string, labels ...string) illumio.Workload {
+	w := illumio.Workload{Href: href, Hostname: hostname}
+	for _, kv := range labels {
+		k, v, _ := strings.Cut(kv, "=")
+		w.Labels = append(w.Labels, illumio.LabelRef{Href: "/orgs/1/labels/" + kv, Key: k, Value: v})
+	}
+	return w
+}
+
+func TestBuildPlan(t *testing.T) {
+	pce := []illumio.Workload{
+		pceWorkload("/w/1", "web01.corp.example.com", "env=prod", "app=ledger"),
+		pceWorkload("/w/2", "db01", "env=dev"),
+		pceWorkload("/w/3", "app01"),
+	}
+	existing := []illumio.Label{{Href: "/l/1", Key: "env", Value: "prod"}, {Href: "/l/2", Key: "app", Value: "ledger"}}
+
+	tests := []struct {
+		name     string
+		expected []*Expected
+		updates  string // hostname: key from->to of each update
+		inSync   int
+		notInPCE string
+		create   string // key=value of each label to create
+	}{
+		{
+			name:     "in sync by short name",
+			expected: []*Expected{{Hostname: "WEB01", Labels: map[string]string{"env": "prod", "app": "ledger"}}},
+			inSync:   1, updates: "[]", notInPCE: "[]", create: "[]",
+		},
+		{
+			name:     "changes use existing labels",
+			expected: []*Expected{{Hostname: "db01", Labels: map[string]string{"env": "prod", "app": "ledger"}}},
+			updates:  "[db01: app ->ledger env dev->prod]", notInPCE: "[]", create: "[]",
+		},
+		{
+			name: "missing labels created once",
+			expected: []*Expected{
+				{Hostname: "db01", Labels: map[string]string{"loc": "dc1"}},
+				{Hostname: "app01", Labels: map[string]string{"loc": "dc1", "env": "qa"}},
+			},
+			updates: "[db01: loc ->dc1 app01: env ->qa loc ->dc1]", notInPCE: "[]", create: "[loc=dc1 env=qa]",
+		},
+		{
+			name:     "not in pce",
+			expected: []*Expected{{Hostname: "gone01", Labels: map[string]string{"env": "prod"}}},
+			updates:  "[]", notInPCE: "[gone01]", create: "[]",
+		},
+		{
+			name:     "nothing expected is skipped",
+			expected: []*Expected{{Hostname: "gone01"}, {Hostname: "db01", Labels: map[string]string{}}},
+			updates:  "[]", notInPCE: "[]", create: "[]",
+		},
+		{
+			name: "conflicts listed without changes",
+			expected: []*Expected{{Hostname: "web01.corp.example.com", Labels: map[string]string{"env": "prod"},
+				Conflicts: map[string][]string{"app": {"ledger", "payments"}}}},
+			updates: "[web01.corp.example.com:]", notInPCE: "[]", create: "[]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := BuildPlan(tt.expected, pce, existing)
+			var updates, create []string
+			for _, u := range plan.Updates {
+				s := u.Hostname + ":"
+				for _, c := range u.Changes {
+					s += " " + c.Key + " " + c.From + "->" + c.To
+				}
+				updates = append(updates, s)
+			}
+			for _, c := range plan.LabelsToCreate {
+				create = append(create, c.Key+"="+c.To)
+			}
+			if fmt.Sprint(updates) != tt.updates || plan.InSync != tt.inSync ||
+				fmt.Sprint(plan.NotInPCE) != tt.notInPCE || fmt.Sprint(create) != tt.create {
+				t.Errorf("updates %v, in sync %d, not in PCE %v, create %v; want %s, %d, %s, %s",
+					updates, plan.InSync, plan.NotInPCE, create, tt.updates, tt.inSync, tt.notInPCE, tt.create)
+			}
+		})
+	}
+}
+
+// TestApply applies a plan to a fake PCE and checks the label sets sent
+// keep the keys the plan does not manage.
+func TestApply(t *testing.T) {
+	pce := []illumio.Workload{
+		pceWorkload("/orgs/1/workloads/1", "db01", "env=dev", "role=db"),
+		pceWorkload("/orgs/1/workloads/2", "app01"),
+	}
+	existing := []illumio.Label{{Href: "/orgs/1/labels/env=prod", Key: "env", Value: "prod"}}
+	plan := BuildPlan([]*Expected{
+		{Hostname: "db01", Labels: map[string]string{"env": "prod"}},
+		{Hostname: "app01", Labels: map[string]string{"loc": "dc1"}},
+	}, pce, existing)
+
+	put := map[string][]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v2/orgs/1/labels":
+			var l illumio.Label
+			json.NewDecoder(r.Body).Decode(&l)
+			l.Href = "/orgs/1/labels/new"
+			json.NewEncoder(w).Encode(l)
+		case r.Method == http.MethodPut && r.URL.Path == "/api/v2/orgs/1/workloads/2":
+			http.Error(w, "locked", http.StatusForbidden)
+		case r.Method == http.MethodPut:
+			var body struct{ Labels []illumio.LabelRef }
+			json.NewDecoder(r.Body).Decode(&body)
+			for _, l := range body.Labels {
+				put[r.URL.Path] = append(put[r.URL.Path], l.Href)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	client := illumio.NewClient(srv.URL, "key", "secret", 0)
+	client.MaxRetries = 0
+
+	res, err := Apply(context.Background(), client, plan, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.LabelsCreated != 1 || res.Updated != 1 || len(res.Errors) != 1 || !strings.HasPrefix(res.Errors[0], "app01:") {
+		t.Errorf("result = %+v, want one label created, one updated and app01 failed", res)
+	}
+	if got := fmt.Sprint(put["/api/v2/orgs/1/workloads/1"]); got != "[/orgs/1/labels/env=prod /orgs/1/labels/role=db]" {
+		t.Errorf("db01 labels = %s", got)
+	}
+}
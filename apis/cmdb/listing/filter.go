@@ -0,0 +1,210 @@
+package listing
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilterError is returned for a filter or sort the table can't serve.
+type FilterError struct{ msg string }
+
+func (e *FilterError) Error() string { return e.msg }
+
+func badFilter(format string, args ...any) error {
+	return &FilterError{fmt.Sprintf(format, args...)}
+}
+
+// Operators maps each filter operator to its SQL comparison.
+var Operators = map[string]string{
+	"eq":   "=",
+	"ne":   "!=",
+	"gt":   ">",
+	"gte":  ">=",
+	"lt":   "<",
+	"lte":  "<=",
+	"like": "LIKE",
+	"in":   "IN",
+	"null": "IS NULL",
+}
+
+// Term is one filter: column[op]=value, or column=value for eq.
+type Term struct {
+	Column string
+	Op     string
+	Value  string
+	// Explicit is set when the operator was spelled out. Plain column=value
+	// params that don't name a column are ignored, since they may be other
+	// query parameters; explicit ones are rejected.
+	Explicit bool
+}
+
+// Filter is the generic filter and sort parsed from a request's query
+// string. It is checked against the table's columns when the query is
+// built, so one parser serves every list endpoint.
+type Filter struct {
+	Terms []Term
+	Sort  []Order
+}
+
+// reserved query parameters are never read as filters.
+var reserved = map[string]bool{
+	"limit": true, "offset": true, "cursor": true, "sort": true, "q": true, "expand": true,
+}
+
+// ParseFilter reads filters and ?sort from query params:
+//
+//	environment=prod             equality
+//	os[like]=windows             substring match (or a LIKE pattern with %)
+//	updated_at[gte]=2026-01-01   gt, gte, lt, lte, ne
+//	environment[in]=prod,dev     comma-separated set
+//	fqdn[null]=true              IS NULL (false for IS NOT NULL)
+//	sort=environment,-hostname   ascending, or descending with "-"
+func ParseFilter(params url.Values) Filter {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if !reserved[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var f Filter
+	for _, key := range keys {
+		vals := params[key]
+		column, op := key, "eq"
+		explicit := false
+		if i := strings.IndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+			column, op, explicit = key[:i], key[i+1:len(key)-1], true
+		}
+		for _, v := range vals {
+			f.Terms = append(f.Terms, Term{Column: column, Op: op, Value: v, Explicit: explicit})
+		}
+	}
+	for _, s := range strings.Split(params.Get("sort"), ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if strings.HasPrefix(s, "-") {
+			f.Sort = append(f.Sort, Order{Column: s[1:], Desc: true})
+		} else {
+			f.Sort = append(f.Sort, Order{Column: strings.TrimPrefix(s, "+")})
+		}
+	}
+	return f
+}
+
+// Apply narrows q by the filter and replaces its order with the requested
+// sort, after checking both against the table's schema.
+func (f Filter) Apply(q Query, schema Schema) (Query, error) {
+	where, args, err := f.build(schema)
+	if err != nil {
+		return q, err
+	}
+	order, err := f.order(schema, q.Order)
+	if err != nil {
+		return q, err
+	}
+	q.Where = append(append([]string{}, q.Where...), where...)
+	q.Args = append(append([]any{}, q.Args...), args...)
+	q.Order = order
+	return q, nil
+}
+
+// build turns the filter into WHERE clauses and args, rejecting columns
+// and operators the schema doesn't allow.
+func (f Filter) build(schema Schema) ([]string, []any, error) {
+	var clauses []string
+	var args []any
+	for _, t := range f.Terms {
+		typ, ok := schema[t.Column]
+		if !ok {
+			if t.Explicit {
+				return nil, nil, badFilter("unknown filter column %q", t.Column)
+			}
+			continue
+		}
+		op, ok := Operators[t.Op]
+		if !ok {
+			return nil, nil, badFilter("unknown filter operator %q", t.Op)
+		}
+
+		switch t.Op {
+		case "null":
+			isNull, err := strconv.ParseBool(t.Value)
+			if err != nil {
+				return nil, nil, badFilter("%s[null] must be true or false", t.Column)
+			}
+			if isNull {
+				clauses = append(clauses, t.Column+" IS NULL")
+			} else {
+				clauses = append(clauses, t.Column+" IS NOT NULL")
+			}
+		case "in":
+			vals := strings.Split(t.Value, ",")
+			marks := strings.TrimSuffix(strings.Repeat("?, ", len(vals)), ", ")
+			clauses = append(clauses, fmt.Sprintf("%s IN (%s)", t.Column, marks))
+			for _, v := range vals {
+				args = append(args, typ.value(t.Column, strings.TrimSpace(v)))
+			}
+		case "like":
+			pattern := t.Value
+			if !strings.Contains(pattern, "%") {
+				pattern = "%" + pattern + "%"
+			}
+			clauses = append(clauses, t.Column+" LIKE ?")
+			args = append(args, pattern)
+		default:
+			clauses = append(clauses, fmt.Sprintf("%s %s ?", t.Column, op))
+			args = append(args, typ.value(t.Column, t.Value))
+		}
+	}
+	return clauses, args, nil
+}
+
+// order returns the requested sort, checked against the schema, or def
+// when none was given.
+func (f Filter) order(schema Schema, def []Order) ([]Order, error) {
+	if len(f.Sort) == 0 {
+		return def, nil
+	}
+	for _, o := range f.Sort {
+		if _, ok := schema[o.Column]; !ok {
+			return nil, badFilter("unknown sort column %q", o.Column)
+		}
+	}
+	return f.Sort, nil
+}
+
+// value converts a filter value to the column's storage form: booleans
+// and numbers for INTEGER columns, and datetime('now') text for *_at
+// timestamps so they compare correctly.
+func (t ColumnType) value(column, v string) any {
+	switch {
+	case t == "INTEGER":
+		switch v {
+		case "true":
+			return 1
+		case "false":
+			return 0
+		}
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case t == "REAL":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case strings.HasSuffix(column, "_at"):
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+			if ts, err := time.Parse(layout, v); err == nil {
+				return ts.UTC().Format(time.DateTime)
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,231 @@
+// Package listing pages through list queries with opaque keyset cursors.
+// It is shared by the typed store and the generic map-based list handlers.
+package listing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrBadCursor is returned when a cursor cannot be decoded or does not
+// match the list's ordering.
+var ErrBadCursor = errors.New("invalid cursor")
+
+// Page selects one page of a list. When Cursor is set, Offset is ignored.
+// A zero Limit means no limit.
+type Page struct {
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+// Order is one ORDER BY term.
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+// Result is one page of a list plus what's needed to fetch the next one.
+type Result[T any] struct {
+	Items []T
+	// Total counts every row matching the filters, across all pages.
+	Total int
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// Query describes a filtered, ordered list.
+type Query struct {
+	Columns string
+	From    string
+	Where   []string
+	Args    []any
+	// Order is the list's ORDER BY. The primary key is appended as a
+	// tiebreaker so every row has a unique position for the cursor.
+	Order []Order
+	PK    string
+}
+
+// Keys returns the ORDER BY terms including the primary key tiebreaker.
+func (q Query) Keys() []Order {
+	for _, o := range q.Order {
+		if o.Column == q.PK {
+			return q.Order
+		}
+	}
+	return append(append([]Order{}, q.Order...), Order{Column: q.PK})
+}
+
+// Count returns the statement counting every matching row.
+func (q Query) Count() (string, []any) {
+	return "SELECT count(*) FROM " + q.From + whereClause(q.Where), q.Args
+}
+
+// Select returns the statement for page p. It fetches one row more than
+// the limit so Trim can tell whether another page follows.
+func (q Query) Select(p Page) (string, []any, error) {
+	keys := q.Keys()
+	where, args := q.Where, q.Args
+	if p.Cursor != "" {
+		vals, err := Decode(p.Cursor, len(keys))
+		if err != nil {
+			return "", nil, err
+		}
+		cond, condArgs := after(keys, vals)
+		where = append(append([]string{}, where...), cond)
+		args = append(append([]any{}, args...), condArgs...)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s", q.Columns, q.From, whereClause(where), orderBy(keys))
+	if p.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, p.Limit+1)
+		if p.Cursor == "" && p.Offset > 0 {
+			query += " OFFSET ?"
+			args = append(args, p.Offset)
+		}
+	}
+	return query, args, nil
+}
+
+// Trim drops the extra row fetched by Select and returns the cursor for
+// the page after it, using value to read each key column from a row.
+func Trim[T any](q Query, p Page, rows []T, value func(row T, column string) any) ([]T, string) {
+	if p.Limit <= 0 || len(rows) <= p.Limit {
+		return rows, ""
+	}
+	rows = rows[:p.Limit]
+	last := rows[len(rows)-1]
+	keys := q.Keys()
+	vals := make([]any, len(keys))
+	for i, k := range keys {
+		vals[i] = value(last, k.Column)
+	}
+	return rows, Encode(vals)
+}
+
+// after returns the condition selecting rows strictly after the key
+// values, honouring each term's direction and SQLite's NULLS FIRST
+// ordering for ascending columns.
+func after(keys []Order, vals []any) (string, []any) {
+	var terms []string
+	var args []any
+	for i := range keys {
+		var parts []string
+		var partArgs []any
+		for j := 0; j < i; j++ {
+			cond, arg := equal(keys[j].Column, vals[j])
+			parts = append(parts, cond)
+			partArgs = append(partArgs, arg...)
+		}
+		cond, arg := beyond(keys[i], vals[i])
+		if cond == "" {
+			continue
+		}
+		parts = append(parts, cond)
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+		args = append(append(args, partArgs...), arg...)
+	}
+	if len(terms) == 0 {
+		return "0", nil
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
+
+func equal(column string, v any) (string, []any) {
+	if v == nil {
+		return column + " IS NULL", nil
+	}
+	return column + " = ?", []any{v}
+}
+
+// beyond is the condition for rows strictly past v in o's direction; it
+// is empty when nothing can follow (a NULL in a descending column).
+func beyond(o Order, v any) (string, []any) {
+	switch {
+	case v == nil && o.Desc:
+		return "", nil
+	case v == nil:
+		return o.Column + " IS NOT NULL", nil
+	case o.Desc:
+		return "(" + o.Column + " < ? OR " + o.Column + " IS NULL)", []any{v}
+	default:
+		return o.Column + " > ?", []any{v}
+	}
+}
+
+func orderBy(keys []Order) string {
+	terms := make([]string, len(keys))
+	for i, k := range keys {
+		terms[i] = k.Column
+		if k.Desc {
+			terms[i] += " DESC"
+		}
+	}
+	return strings.Join(terms, ", ")
+}
+
+func whereClause(clauses []string) string {
+	if len(clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(clauses, " AND ")
+}
+
+// ─── Cursors ────────────────────────────────────────────────
+
+// Encode packs key values into an opaque cursor. Times are stored in
+// SQLite's datetime('now') format so they compare against the column text.
+func Encode(vals []any) string {
+	norm := make([]any, len(vals))
+	for i, v := range vals {
+		switch x := v.(type) {
+		case time.Time:
+			norm[i] = x.UTC().Format(time.DateTime)
+		case []byte:
+			norm[i] = string(x)
+		case bool:
+			if x {
+				norm[i] = 1
+			} else {
+				norm[i] = 0
+			}
+		default:
+			norm[i] = v
+		}
+	}
+	b, _ := json.Marshal(norm)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode unpacks a cursor made by Encode, which must hold n values.
+func Decode(cursor string, n int) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	var vals []any
+	if err := dec.Decode(&vals); err != nil || len(vals) != n {
+		return nil, ErrBadCursor
+	}
+	for i, v := range vals {
+		switch x := v.(type) {
+		case json.Number:
+			if n, err := x.Int64(); err == nil {
+				vals[i] = n
+			} else if f, err := x.Float64(); err == nil {
+				vals[i] = f
+			}
+		case string, nil:
+		default:
+			return nil, ErrBadCursor
+		}
+	}
+	return vals, nil
+}
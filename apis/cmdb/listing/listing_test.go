@@ -0,0 +1,157 @@
+package listing
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	tests := []struct {
+		name string
+		in   []any
+		want string
+	}{
+		{"strings and ints", []any{"web01", 42}, "[web01 42]"},
+		{"null key", []any{nil, "id"}, "[<nil> id]"},
+		{"time in UTC text", []any{at}, "[2026-03-01 17:30:00]"},
+		{"bool as integer", []any{true, false}, "[1 0]"},
+		{"bytes as text", []any{[]byte("abc")}, "[abc]"},
+		{"float", []any{1.5}, "[1.5]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Encode(tt.in)
+			if strings.ContainsAny(c, "+/=") {
+				t.Errorf("cursor %q is not URL-safe", c)
+			}
+			got, err := Decode(c, len(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("decoded %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+		n      int
+	}{
+		{"not base64", "!!!", 1},
+		{"not json", "eA", 1}, // "x"
+		{"wrong key count", Encode([]any{"a", "b"}), 3},
+		{"object value", "W3siYSI6MX1d", 1}, // [{"a":1}]
+	}
+	for _, tt := range tests {
+		if _, err := Decode(tt.cursor, tt.n); !errors.Is(err, ErrBadCursor) {
+			t.Errorf("%s: err = %v, want ErrBadCursor", tt.name, err)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	q, _ := url.ParseQuery("environment=prod&os[like]=win&updated_at[gte]=2026-01-01&limit=5&expand=x&sort=-hostname,+os,")
+	f := ParseFilter(q)
+	got := fmt.Sprint(f.Terms)
+	want := "[{environment eq prod false} {os like win true} {updated_at gte 2026-01-01 true}]"
+	if got != want {
+		t.Errorf("terms = %s, want %s", got, want)
+	}
+	if fmt.Sprint(f.Sort) != "[{hostname true} {os false}]" {
+		t.Errorf("sort = %v", f.Sort)
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	schema := Schema{"hostname": "TEXT", "is_virtual": "INTEGER", "updated_at": "TEXT", "score": "REAL"}
+	base := Query{Columns: "*", From: "workloads", Where: []string{"deleted_at IS NULL"}, Order: []Order{{Column: "hostname"}}, PK: "workload_id"}
+
+	tests := []struct {
+		query string
+		where string
+		args  string
+		err   string
+	}{
+		{"hostname=web01", "[deleted_at IS NULL hostname = ?]", "[web01]", ""},
+		// Plain params naming no column may be other query parameters.
+		{"dry_run=true", "[deleted_at IS NULL]", "[]", ""},
+		{"nope[eq]=1", "", "", `unknown filter column "nope"`},
+		{"hostname[regex]=x", "", "", `unknown filter operator "regex"`},
+		{"is_virtual=true", "[deleted_at IS NULL is_virtual = ?]", "[1]", ""},
+		{"score[gt]=1.5", "[deleted_at IS NULL score > ?]", "[1.5]", ""},
+		{"updated_at[lt]=2026-01-02", "[deleted_at IS NULL updated_at < ?]", "[2026-01-02 00:00:00]", ""},
+		{"hostname[in]=a, b", "[deleted_at IS NULL hostname IN (?, ?)]", "[a b]", ""},
+		{"hostname[like]=web", "[deleted_at IS NULL hostname LIKE ?]", "[%web%]", ""},
+		{"hostname[null]=false", "[deleted_at IS NULL hostname IS NOT NULL]", "[]", ""},
+		{"hostname[null]=maybe", "", "", "hostname[null] must be true or false"},
+		{"sort=password", "", "", `unknown sort column "password"`},
+		// A column name is never interpolated unless the schema has it.
+		{"hostname%3D1%20OR%201[eq]=1", "", "", "unknown filter column"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q, err := ParseFilter(params).Apply(base, schema)
+			if tt.err != "" {
+				var fe *FilterError
+				if !errors.As(err, &fe) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want FilterError %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(q.Where) != tt.where || fmt.Sprint(q.Args) != tt.args {
+				t.Errorf("where %v args %v, want %s %s", q.Where, q.Args, tt.where, tt.args)
+			}
+		})
+	}
+	if len(base.Where) != 1 {
+		t.Errorf("Apply changed the base query: %v", base.Where)
+	}
+}
+
+func TestSelectAfterCursor(t *testing.T) {
+	q := Query{Columns: "*", From: "t", Order: []Order{{Column: "env"}, {Column: "name", Desc: true}}, PK: "id"}
+	tests := []struct {
+		name string
+		vals []any
+		want string
+	}{
+		{"values", []any{"prod", "web", "7"},
+			"((env > ?) OR (env = ? AND (name < ? OR name IS NULL)) OR (env = ? AND name = ? AND id > ?))"},
+		// A NULL env sorts first, so every non-NULL env follows it.
+		{"null ascending", []any{nil, "web", "7"},
+			"((env IS NOT NULL) OR (env IS NULL AND (name < ? OR name IS NULL)) OR (env IS NULL AND name = ? AND id > ?))"},
+		// Nothing follows a NULL in a descending column but the tiebreaker.
+		{"null descending", []any{"prod", nil, "7"},
+			"((env > ?) OR (env = ? AND name IS NULL AND id > ?))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := q.Select(Page{Limit: 10, Cursor: Encode(tt.vals)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(query, "WHERE "+tt.want+" ORDER BY env, name DESC, id LIMIT ?") {
+				t.Errorf("query = %s\nwant WHERE %s", query, tt.want)
+			}
+		})
+	}
+	if _, _, err := q.Select(Page{Cursor: Encode([]any{"prod"})}); !errors.Is(err, ErrBadCursor) {
+		t.Errorf("cursor for another ordering: err = %v, want ErrBadCursor", err)
+	}
+}
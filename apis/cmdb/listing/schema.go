@@ -0,0 +1,51 @@
+package listing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+// ColumnType is a column's declared SQLite type, upper-cased.
+type ColumnType string
+
+// Schema maps each column of a table to its declared type. It is the
+// whitelist for filters and sorts.
+type Schema map[string]ColumnType
+
+// Querier is satisfied by *sql.DB and *sql.Tx.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+var schemas sync.Map // table name → Schema
+
+// Describe returns the columns of table from PRAGMA table_info. Results
+// are cached for the life of the process; migrations run before the API
+// starts serving, so the schema doesn't change underneath it.
+func Describe(ctx context.Context, db Querier, table string) (Schema, error) {
+	if s, ok := schemas.Load(table); ok {
+		return s.(Schema), nil
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT name, type FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s := Schema{}
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, err
+		}
+		s[name] = ColumnType(strings.ToUpper(typ))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	schemas.Store(table, s)
+	return s, nil
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/jihaia/aperture/apis/cmdb/db"
 	"github.com/jihaia/aperture/apis/cmdb/routes"
+	migrations "github.com/jihaia/aperture/packages/migrations"
 )
 
 var (
@@ -21,15 +24,44 @@ var (
 )
 
 func init() {
-	_, err := db.Setup()
+	conn, err := db.Setup()
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	if err := migrate(conn); err != nil {
+		log.Fatalf("%v", err)
+	}
 
 	router = routes.NewRouter()
 	ginAdapter = ginadapter.NewV2(router)
 }
 
+// migrate brings the schema up to date. With APERTURE_MIGRATIONS=strict
+// nothing is applied and the API refuses to start if anything is pending,
+// for deployments where migrations are run separately.
+func migrate(conn *sql.DB) error {
+	mode := os.Getenv("APERTURE_MIGRATIONS")
+	switch mode {
+	case "", "auto":
+		if err := migrations.Run(conn); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		return nil
+	case "strict":
+		pending, err := migrations.Pending(conn)
+		if err != nil {
+			return fmt.Errorf("check migrations: %w", err)
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("database schema is behind (%d pending: %s); run the migrations CLI or unset APERTURE_MIGRATIONS=strict",
+				len(pending), strings.Join(pending, ", "))
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid APERTURE_MIGRATIONS %q (want auto or strict)", mode)
+	}
+}
+
 func handler(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	return ginAdapter.ProxyWithContext(ctx, req)
 }
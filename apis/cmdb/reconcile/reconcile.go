@@ -0,0 +1,185 @@
+// Package reconcile compares Illumio workloads against the CMDB workloads
+// table and produces a report of what is missing on either side and which
+// matched workloads have drifted attributes.
+package reconcile
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jihaia/aperture/apis/cmdb/illumio"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+// Report buckets.
+const (
+	BucketOnlyInIllumio = "only_in_illumio"
+	BucketOnlyInCMDB    = "only_in_cmdb"
+	BucketDrift         = "drift"
+)
+
+// Workload is a workload flattened to the attributes that are compared.
+// It is used for both the Illumio and the CMDB side of a reconciliation.
+type Workload struct {
+	WorkloadID  string
+	Href        string
+	Hostname    string
+	IPAddress   string
+	OS          string
+	Environment string
+	Location    string
+}
+
+// FromIllumio maps a PCE workload the same way the extension's
+// mapIllumioWorkload does: first IPv4 interface, env/loc labels.
+func FromIllumio(w illumio.Workload) Workload {
+	return Workload{
+		Href:        w.Href,
+		Hostname:    w.DisplayHostname(),
+		IPAddress:   w.FirstIPv4(),
+		OS:          w.OS(),
+		Environment: w.Label("env"),
+		Location:    w.Label("loc"),
+	}
+}
+
+// Drift describes one attribute that differs between Illumio and the CMDB.
+type Drift struct {
+	Field   string `json:"field"`
+	Illumio string `json:"illumio"`
+	CMDB    string `json:"cmdb"`
+}
+
+// Item is one out-of-sync workload in a report.
+type Item struct {
+	Bucket      string
+	Hostname    string
+	WorkloadID  string
+	IllumioHref string
+	IPAddress   string
+	Drift       []Drift
+}
+
+// Report is the result of comparing an Illumio snapshot against the CMDB.
+type Report struct {
+	IllumioCount int
+	CMDBCount    int
+	Matched      int
+	Items        []Item
+}
+
+// Count returns the number of items in the given bucket.
+func (r *Report) Count(bucket string) int {
+	n := 0
+	for _, it := range r.Items {
+		if it.Bucket == bucket {
+			n++
+		}
+	}
+	return n
+}
+
+// Compare matches Illumio workloads to CMDB workloads by hostname, as
+// store.HostIndex does, and reports the differences. Duplicate Illumio
+// registrations of the same host are collapsed to the first one seen.
+// A short name is only a match when it is unambiguous: if it finds more
+// than one CMDB workload, or both names are qualified and differ, as
+// web01.dc1 and web01.dc2 do, the hosts are reported unmatched.
+func Compare(pce, cmdb []Workload) *Report {
+	hosts := store.NewHostIndex[int]()
+	for i, w := range cmdb {
+		hosts.Add(i, w.Hostname)
+	}
+
+	report := &Report{CMDBCount: len(cmdb)}
+	seen := map[string]bool{}
+	matched := make([]bool, len(cmdb))
+
+	for _, iw := range pce {
+		key := store.HostKey(iw.Hostname)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		report.IllumioCount++
+
+		idx, ok := match(hosts, iw.Hostname, cmdb)
+		if !ok || matched[idx] {
+			report.Items = append(report.Items, Item{
+				Bucket:      BucketOnlyInIllumio,
+				Hostname:    iw.Hostname,
+				IllumioHref: iw.Href,
+				IPAddress:   iw.IPAddress,
+			})
+			continue
+		}
+
+		matched[idx] = true
+		report.Matched++
+		cw := cmdb[idx]
+		if drift := diff(iw, cw); len(drift) > 0 {
+			report.Items = append(report.Items, Item{
+				Bucket:      BucketDrift,
+				Hostname:    cw.Hostname,
+				WorkloadID:  cw.WorkloadID,
+				IllumioHref: iw.Href,
+				IPAddress:   cw.IPAddress,
+				Drift:       drift,
+			})
+		}
+	}
+
+	for i, cw := range cmdb {
+		if matched[i] {
+			continue
+		}
+		report.Items = append(report.Items, Item{
+			Bucket:     BucketOnlyInCMDB,
+			Hostname:   cw.Hostname,
+			WorkloadID: cw.WorkloadID,
+			IPAddress:  cw.IPAddress,
+		})
+	}
+
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		if report.Items[i].Bucket != report.Items[j].Bucket {
+			return report.Items[i].Bucket < report.Items[j].Bucket
+		}
+		return store.HostKey(report.Items[i].Hostname) < store.HostKey(report.Items[j].Hostname)
+	})
+	return report
+}
+
+// match finds the CMDB workload an Illumio hostname refers to.
+func match(hosts *store.HostIndex[int], hostname string, cmdb []Workload) (int, bool) {
+	found, by := hosts.Find(hostname)
+	if len(found) == 0 {
+		return 0, false
+	}
+	if by == "short_name" {
+		if len(found) > 1 || qualified(hostname) && qualified(cmdb[found[0]].Hostname) {
+			return 0, false
+		}
+	}
+	return found[0], true
+}
+
+// qualified reports whether a hostname has a domain.
+func qualified(host string) bool {
+	return strings.Contains(store.HostKey(host), ".")
+}
+
+// diff compares the reconciled attributes of a matched pair.
+func diff(pce, cmdb Workload) []Drift {
+	var out []Drift
+	check := func(field, a, b string) {
+		if !strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b)) {
+			out = append(out, Drift{Field: field, Illumio: a, CMDB: b})
+		}
+	}
+	check("ip_address", pce.IPAddress, cmdb.IPAddress)
+	check("os", pce.OS, cmdb.OS)
+	check("environment", pce.Environment, cmdb.Environment)
+	check("location", pce.Location, cmdb.Location)
+	return out
+}
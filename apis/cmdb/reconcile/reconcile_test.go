@@ -0,0 +1,124 @@
+package reconcile
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name    string
+		pce     []Workload
+		cmdb    []Workload
+		matched int
+		items   string // bucket:hostname:workload_id of each item
+	}{
+		{
+			name:    "exact",
+			pce:     []Workload{{Href: "/w/1", Hostname: "WEB01.", IPAddress: "10.0.0.1"}},
+			cmdb:    []Workload{{WorkloadID: "a", Hostname: "web01", IPAddress: "10.0.0.1"}},
+			matched: 1,
+			items:   "[]",
+		},
+		{
+			name:    "short name to fqdn",
+			pce:     []Workload{{Href: "/w/1", Hostname: "web01"}},
+			cmdb:    []Workload{{WorkloadID: "a", Hostname: "web01.corp.example.com"}},
+			matched: 1,
+			items:   "[]",
+		},
+		{
+			name:    "fqdn to short name",
+			pce:     []Workload{{Href: "/w/1", Hostname: "web01.corp.example.com"}},
+			cmdb:    []Workload{{WorkloadID: "a", Hostname: "web01"}},
+			matched: 1,
+			items:   "[]",
+		},
+		{
+			name:  "differing fqdns",
+			pce:   []Workload{{Href: "/w/1", Hostname: "web01.dc1.example.com"}},
+			cmdb:  []Workload{{WorkloadID: "a", Hostname: "web01.dc2.example.com"}},
+			items: "[only_in_cmdb:web01.dc2.example.com:a only_in_illumio:web01.dc1.example.com:]",
+		},
+		{
+			name: "ambiguous short name",
+			pce:  []Workload{{Href: "/w/1", Hostname: "web01"}},
+			cmdb: []Workload{
+				{WorkloadID: "a", Hostname: "web01.dc1.example.com"},
+				{WorkloadID: "b", Hostname: "web01.dc2.example.com"},
+			},
+			items: "[only_in_cmdb:web01.dc1.example.com:a only_in_cmdb:web01.dc2.example.com:b only_in_illumio:web01:]",
+		},
+		{
+			name: "exact beats short name",
+			pce:  []Workload{{Href: "/w/1", Hostname: "web01.dc1.example.com"}},
+			cmdb: []Workload{
+				{WorkloadID: "a", Hostname: "web01.dc2.example.com"},
+				{WorkloadID: "b", Hostname: "web01.dc1.example.com"},
+			},
+			matched: 1,
+			items:   "[only_in_cmdb:web01.dc2.example.com:a]",
+		},
+		{
+			name: "duplicate cmdb hostname matches the first",
+			pce:  []Workload{{Href: "/w/1", Hostname: "db01"}},
+			cmdb: []Workload{
+				{WorkloadID: "a", Hostname: "db01"},
+				{WorkloadID: "b", Hostname: "DB01"},
+			},
+			matched: 1,
+			items:   "[only_in_cmdb:DB01:b]",
+		},
+		{
+			name: "duplicate illumio registrations collapse",
+			pce: []Workload{
+				{Href: "/w/1", Hostname: "db01", OS: "linux"},
+				{Href: "/w/2", Hostname: "DB01", OS: "windows"},
+			},
+			cmdb:    []Workload{{WorkloadID: "a", Hostname: "db01", OS: "linux"}},
+			matched: 1,
+			items:   "[]",
+		},
+		{
+			name:    "drift",
+			pce:     []Workload{{Href: "/w/1", Hostname: "app01", IPAddress: "10.0.0.2", Environment: "Prod"}},
+			cmdb:    []Workload{{WorkloadID: "a", Hostname: "app01", IPAddress: "10.0.0.1", Environment: "prod"}},
+			matched: 1,
+			items:   "[drift:app01:a]",
+		},
+		{
+			name:  "blank hostname skipped",
+			pce:   []Workload{{Href: "/w/1", Hostname: " "}},
+			items: "[]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Compare(tt.pce, tt.cmdb)
+			items := []string{}
+			for _, it := range r.Items {
+				items = append(items, it.Bucket+":"+it.Hostname+":"+it.WorkloadID)
+			}
+			if r.Matched != tt.matched || fmt.Sprint(items) != tt.items {
+				t.Errorf("matched %d %v, want %d %s", r.Matched, items, tt.matched, tt.items)
+			}
+		})
+	}
+}
+
+func TestCompareDrift(t *testing.T) {
+	r := Compare(
+		[]Workload{{Href: "/w/1", Hostname: "app01", IPAddress: "10.0.0.2", OS: "Linux", Location: "dc1"}},
+		[]Workload{{WorkloadID: "a", Hostname: "app01", IPAddress: "10.0.0.1", OS: " linux ", Location: "dc2"}},
+	)
+	if len(r.Items) != 1 {
+		t.Fatalf("items = %+v", r.Items)
+	}
+	got := fmt.Sprint(r.Items[0].Drift)
+	if want := "[{ip_address 10.0.0.2 10.0.0.1} {location dc1 dc2}]"; got != want {
+		t.Errorf("drift = %s, want %s", got, want)
+	}
+	if r.IllumioCount != 1 || r.CMDBCount != 1 || r.Count(BucketDrift) != 1 {
+		t.Errorf("counts %d %d %d", r.IllumioCount, r.CMDBCount, r.Count(BucketDrift))
+	}
+}
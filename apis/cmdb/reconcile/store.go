@@ -0,0 +1,111 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jihaia/aperture/apis/cmdb/illumio"
+)
+
+// LoadCMDB reads every workload from the CMDB in the shape Compare expects.
+func LoadCMDB(ctx context.Context, db *sql.DB) ([]Workload, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT workload_id, hostname, COALESCE(ip_address, ''), COALESCE(os, ''),
+		        COALESCE(environment, ''), COALESCE(location, '')
+		 FROM workloads ORDER BY hostname`)
+	if err != nil {
+		return nil, fmt.Errorf("query workloads: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Workload
+	for rows.Next() {
+		var w Workload
+		if err := rows.Scan(&w.WorkloadID, &w.Hostname, &w.IPAddress, &w.OS, &w.Environment, &w.Location); err != nil {
+			return nil, err
+		}
+		list = append(list, w)
+	}
+	return list, rows.Err()
+}
+
+// Save persists a report and its items in a single transaction and
+// returns the new reconciliation id.
+func Save(ctx context.Context, db *sql.DB, source string, r *Report) (string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	id := uuid.New().String()
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO reconciliations (reconciliation_id, source, illumio_count, cmdb_count, matched_count,
+		   only_in_illumio_count, only_in_cmdb_count, drift_count)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, source, r.IllumioCount, r.CMDBCount, r.Matched,
+		r.Count(BucketOnlyInIllumio), r.Count(BucketOnlyInCMDB), r.Count(BucketDrift))
+	if err != nil {
+		return "", fmt.Errorf("insert reconciliation: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO reconciliation_items (item_id, reconciliation_id, bucket, hostname, workload_id, illumio_href, ip_address, drift)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return "", err
+	}
+	defer stmt.Close()
+
+	for _, it := range r.Items {
+		var drift *string
+		if len(it.Drift) > 0 {
+			b, err := json.Marshal(it.Drift)
+			if err != nil {
+				return "", err
+			}
+			s := string(b)
+			drift = &s
+		}
+		if _, err := stmt.ExecContext(ctx,
+			uuid.New().String(), id, it.Bucket, it.Hostname,
+			nullIfEmpty(it.WorkloadID), nullIfEmpty(it.IllumioHref), nullIfEmpty(it.IPAddress), drift); err != nil {
+			return "", fmt.Errorf("insert item %s: %w", it.Hostname, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Run compares the given Illumio workloads against the CMDB and stores the report.
+func Run(ctx context.Context, db *sql.DB, workloads []illumio.Workload) (string, *Report, error) {
+	flat := make([]Workload, len(workloads))
+	for i, w := range workloads {
+		flat[i] = FromIllumio(w)
+	}
+
+	cmdb, err := LoadCMDB(ctx, db)
+	if err != nil {
+		return "", nil, err
+	}
+
+	report := Compare(flat, cmdb)
+	id, err := Save(ctx, db, "illumio", report)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, report, nil
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
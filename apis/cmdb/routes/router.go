@@ -10,12 +10,14 @@ func NewRouter() *gin.Engine {
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 	r.SetTrustedProxies(nil)
+	r.Use(handlers.Identify)
 
 	// CORS for local extension development
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, X-Actor, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "Link, ETag, X-Request-ID")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -28,11 +30,15 @@ func NewRouter() *gin.Engine {
 		v1.GET("/health", handlers.Health)
 		v1.GET("/schema", handlers.Schema)
 
+		// Hierarchy tree
+		v1.GET("/tree", handlers.GetTree)
+
 		// Portfolios
 		v1.GET("/portfolios", handlers.ListPortfolios)
 		v1.POST("/portfolios", handlers.CreatePortfolio)
 		v1.GET("/portfolios/:id", handlers.GetPortfolio)
 		v1.PUT("/portfolios/:id", handlers.UpdatePortfolio)
+		v1.PATCH("/portfolios/:id", handlers.PatchPortfolio)
 		v1.DELETE("/portfolios/:id", handlers.DeletePortfolio)
 
 		// Assets
@@ -40,6 +46,7 @@ func NewRouter() *gin.Engine {
 		v1.POST("/assets", handlers.CreateAsset)
 		v1.GET("/assets/:id", handlers.GetAsset)
 		v1.PUT("/assets/:id", handlers.UpdateAsset)
+		v1.PATCH("/assets/:id", handlers.PatchAsset)
 		v1.DELETE("/assets/:id", handlers.DeleteAsset)
 
 		// App Groupings
@@ -47,6 +54,7 @@ func NewRouter() *gin.Engine {
 		v1.POST("/app-groupings", handlers.CreateAppGrouping)
 		v1.GET("/app-groupings/:id", handlers.GetAppGrouping)
 		v1.PUT("/app-groupings/:id", handlers.UpdateAppGrouping)
+		v1.PATCH("/app-groupings/:id", handlers.PatchAppGrouping)
 		v1.DELETE("/app-groupings/:id", handlers.DeleteAppGrouping)
 
 		// Applications
@@ -54,6 +62,7 @@ func NewRouter() *gin.Engine {
 		v1.POST("/applications", handlers.CreateApplication)
 		v1.GET("/applications/:id", handlers.GetApplication)
 		v1.PUT("/applications/:id", handlers.UpdateApplication)
+		v1.PATCH("/applications/:id", handlers.PatchApplication)
 		v1.DELETE("/applications/:id", handlers.DeleteApplication)
 
 		// Components
@@ -61,8 +70,23 @@ func NewRouter() *gin.Engine {
 		v1.POST("/components", handlers.CreateComponent)
 		v1.GET("/components/:id", handlers.GetComponent)
 		v1.PUT("/components/:id", handlers.UpdateComponent)
+		v1.PATCH("/components/:id", handlers.PatchComponent)
 		v1.DELETE("/components/:id", handlers.DeleteComponent)
 
+		// Trash (soft-deleted hierarchy rows)
+		v1.GET("/trash", handlers.ListTrash)
+		v1.POST("/trash/:type/:id/restore", handlers.RestoreTrash)
+		v1.DELETE("/trash/:type/:id", handlers.PurgeTrash)
+
+		// Audit log and per-entity history
+		v1.GET("/audit", handlers.ListAudit)
+		v1.GET("/portfolios/:id/history", handlers.PortfolioHistory)
+		v1.GET("/assets/:id/history", handlers.AssetHistory)
+		v1.GET("/app-groupings/:id/history", handlers.AppGroupingHistory)
+		v1.GET("/applications/:id/history", handlers.ApplicationHistory)
+		v1.GET("/components/:id/history", handlers.ComponentHistory)
+		v1.GET("/workloads/:id/history", handlers.WorkloadHistory)
+
 		// Component Classes (read-only)
 		v1.GET("/component-classes", handlers.ListComponentClasses)
 		v1.GET("/component-classes/:id", handlers.GetComponentClass)
@@ -76,14 +100,61 @@ func NewRouter() *gin.Engine {
 		v1.POST("/components/:id/workloads", handlers.LinkWorkload)
 		v1.DELETE("/components/:id/workloads/:workload_id", handlers.UnlinkWorkload)
 
+		// Workload rollups under a hierarchy node
+		v1.GET("/portfolios/:id/workloads", handlers.ListPortfolioWorkloads)
+		v1.GET("/assets/:id/workloads", handlers.ListAssetWorkloads)
+		v1.GET("/app-groupings/:id/workloads", handlers.ListAppGroupingWorkloads)
+		v1.GET("/applications/:id/workloads", handlers.ListApplicationWorkloads)
+
 		// Workloads
 		v1.GET("/workloads", handlers.ListWorkloads)
 		v1.GET("/workloads/lookup", handlers.LookupWorkload)
+		v1.POST("/workloads/lookup", handlers.BatchLookupWorkloads)
 		v1.POST("/workloads", handlers.CreateWorkload)
 		v1.POST("/workloads/bulk", handlers.BulkUpsertWorkloads)
 		v1.GET("/workloads/:id", handlers.GetWorkload)
 		v1.PUT("/workloads/:id", handlers.UpdateWorkload)
+		v1.PATCH("/workloads/:id", handlers.PatchWorkload)
 		v1.DELETE("/workloads/:id", handlers.DeleteWorkload)
+
+		// Controlled vocabularies (criticality, environment, location)
+		v1.GET("/vocabularies", handlers.ListVocabularies)
+		v1.GET("/vocabularies/:vocabulary", handlers.ListVocabularyTerms)
+		v1.POST("/vocabularies/:vocabulary", handlers.CreateVocabularyTerm)
+		v1.GET("/vocabularies/:vocabulary/:value", handlers.GetVocabularyTerm)
+		v1.PUT("/vocabularies/:vocabulary/:value", handlers.UpdateVocabularyTerm)
+		v1.DELETE("/vocabularies/:vocabulary/:value", handlers.DeleteVocabularyTerm)
+
+		// Workload Validations
+		v1.GET("/workloads/:id/validations", handlers.ListWorkloadValidations)
+		v1.POST("/workloads/:id/validations", handlers.CreateWorkloadValidation)
+		v1.GET("/workloads/:id/validations/:validation_id", handlers.GetWorkloadValidation)
+		v1.PUT("/workloads/:id/validations/:validation_id", handlers.UpdateWorkloadValidation)
+		v1.DELETE("/workloads/:id/validations/:validation_id", handlers.DeleteWorkloadValidation)
+
+		// Team-wide validation progress
+		v1.GET("/progress", handlers.Progress)
+
+		// Reconciliations (Illumio ↔ CMDB)
+		v1.GET("/reconciliations", handlers.ListReconciliations)
+		v1.POST("/reconciliations", handlers.CreateReconciliation)
+		v1.GET("/reconciliations/:id", handlers.GetReconciliation)
+		v1.GET("/reconciliations/:id/items", handlers.ListReconciliationItems)
+
+		// Illumio label mapping
+		v1.GET("/label-mappings", handlers.ListLabelMappings)
+		v1.PUT("/label-mappings/:key", handlers.PutLabelMapping)
+		v1.DELETE("/label-mappings/:key", handlers.DeleteLabelMapping)
+		v1.GET("/workloads/:id/labels", handlers.GetWorkloadLabels)
+		v1.POST("/labels/sync", handlers.SyncLabels)
+
+		// Portfolio IP lists
+		v1.GET("/portfolios/:id/ip-list", handlers.GetPortfolioIPList)
+		v1.POST("/portfolios/:id/ip-list/publish", handlers.PublishPortfolioIPList)
+
+		// Server-side sync
+		v1.POST("/sync/illumio", handlers.SyncIllumio)
+		v1.POST("/sync/servicenow", handlers.SyncServiceNow)
 	}
 
 	return r
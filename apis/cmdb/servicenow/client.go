@@ -0,0 +1,153 @@
+// Package servicenow is a minimal ServiceNow Table API client plus an
+// importer that loads the BIA hierarchy into the CMDB.
+package servicenow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned by FromEnv when the instance settings are missing.
+var ErrNotConfigured = errors.New("servicenow: instance not configured (set SERVICENOW_INSTANCE, SERVICENOW_USERNAME, SERVICENOW_PASSWORD)")
+
+// Client queries the Table API of one ServiceNow instance.
+type Client struct {
+	BaseURL  string
+	username string
+	password string
+
+	HTTP *http.Client
+
+	// PageSize is the sysparm_limit used when paging through a table.
+	PageSize int
+
+	// MaxRetries is how many times a request is retried on 429 or 5xx.
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+// Record is one Table API row. Reference fields are returned as plain
+// sys_id strings (sysparm_exclude_reference_link=true).
+type Record map[string]any
+
+// Get returns the field as a string, or "" if missing.
+func (r Record) Get(field string) string {
+	switch v := r[field].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case map[string]any:
+		// reference field returned with a link
+		s, _ := v["value"].(string)
+		return s
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// NewClient creates a client for an instance such as "acme.service-now.com".
+func NewClient(instance, username, password string) *Client {
+	base := strings.TrimRight(instance, "/")
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	return &Client{
+		BaseURL:    base,
+		username:   username,
+		password:   password,
+		HTTP:       &http.Client{Timeout: 60 * time.Second},
+		PageSize:   1000,
+		MaxRetries: 3,
+		Backoff:    time.Second,
+	}
+}
+
+// FromEnv builds a client from SERVICENOW_INSTANCE, SERVICENOW_USERNAME
+// and SERVICENOW_PASSWORD.
+func FromEnv() (*Client, error) {
+	instance := os.Getenv("SERVICENOW_INSTANCE")
+	user := os.Getenv("SERVICENOW_USERNAME")
+	pass := os.Getenv("SERVICENOW_PASSWORD")
+	if instance == "" || user == "" || pass == "" {
+		return nil, ErrNotConfigured
+	}
+	return NewClient(instance, user, pass), nil
+}
+
+// Query returns every row of table matching the encoded query, paging with
+// sysparm_offset. fields restricts the returned columns (dot-walking allowed).
+func (c *Client) Query(ctx context.Context, table, query string, fields ...string) ([]Record, error) {
+	var all []Record
+	for offset := 0; ; offset += c.PageSize {
+		page, err := c.page(ctx, table, query, fields, c.PageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < c.PageSize {
+			return all, nil
+		}
+	}
+}
+
+func (c *Client) page(ctx context.Context, table, query string, fields []string, limit, offset int) ([]Record, error) {
+	params := url.Values{}
+	params.Set("sysparm_limit", strconv.Itoa(limit))
+	params.Set("sysparm_offset", strconv.Itoa(offset))
+	params.Set("sysparm_exclude_reference_link", "true")
+	if query != "" {
+		params.Set("sysparm_query", query)
+	}
+	if len(fields) > 0 {
+		params.Set("sysparm_fields", strings.Join(fields, ","))
+	}
+	u := fmt.Sprintf("%s/api/now/table/%s?%s", c.BaseURL, table, params.Encode())
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(c.username, c.password)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500) && attempt < c.MaxRetries {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			continue
+		}
+
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("servicenow: HTTP %d for %s", resp.StatusCode, table)
+		}
+		var body struct {
+			Result []Record `json:"result"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("servicenow: decode %s: %w", table, err)
+		}
+		return body.Result, nil
+	}
+}
@@ -0,0 +1,498 @@
+package servicenow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jihaia/aperture/apis/cmdb/store"
+)
+
+// Tier names, in import order.
+const (
+	TierPortfolios   = "portfolios"
+	TierAssets       = "assets"
+	TierAppGroupings = "app_groupings"
+	TierApplications = "applications"
+	TierComponents   = "components"
+)
+
+// AllTiers lists every tier in parent-before-child order.
+var AllTiers = []string{TierPortfolios, TierAssets, TierAppGroupings, TierApplications, TierComponents}
+
+// Mapping controls which ServiceNow classes feed the middle tiers.
+// Portfolios always come from pm_portfolio and assets from cmdb_ci_service;
+// the lower tiers are discovered through cmdb_rel_ci parent → child links.
+type Mapping struct {
+	AppGroupingClass string
+	ApplicationClass string
+}
+
+// DefaultMapping maps application services to app groupings and
+// application CIs to applications.
+var DefaultMapping = Mapping{
+	AppGroupingClass: "cmdb_ci_service_discovered",
+	ApplicationClass: "cmdb_ci_appl",
+}
+
+// TierResult counts what happened to the rows of one tier.
+type TierResult struct {
+	Fetched   int `json:"fetched"`
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Skipped   int `json:"skipped"`
+	Errors    int `json:"errors"`
+	// Failures says why each of the Errors rows was not written.
+	Failures []RowFailure `json:"failures"`
+	// Trashed lists the sys_ids skipped because the row or its parent is
+	// in the trash. They sync again once restored.
+	Trashed []string `json:"trashed"`
+	// Changes holds an audit entry for each row created or updated.
+	Changes []*store.AuditEntry `json:"-"`
+}
+
+// RowFailure is one ServiceNow record that could not be written.
+type RowFailure struct {
+	SysID string `json:"sys_id"`
+	Error string `json:"error"`
+}
+
+func (res *TierResult) fail(sysID string, err error) {
+	res.Errors++
+	res.Failures = append(res.Failures, RowFailure{SysID: sysID, Error: err.Error()})
+}
+
+// Importer loads the BIA hierarchy from ServiceNow into the CMDB,
+// upserting every tier by snow_sys_id.
+type Importer struct {
+	Client  *Client
+	DB      *sql.DB
+	Mapping Mapping
+	// Audit, if set, is called with the results before the run commits,
+	// to record their Changes in the same transaction.
+	Audit func(tx store.DBTX, results map[string]*TierResult) error
+}
+
+// tier describes how one CMDB table is upserted.
+type tier struct {
+	table     string
+	pk        string
+	parentCol string   // FK to the parent tier, "" for portfolios
+	fields    []string // updatable columns, name first
+	entity    string   // audit entity name
+}
+
+var tiers = map[string]tier{
+	TierPortfolios:   {"portfolios", "portfolio_id", "", []string{"name", "state"}, "portfolio"},
+	TierAssets:       {"assets", "asset_id", "portfolio_id", []string{"name", "full_name", "description", "criticality", "environment", "category", "infrastructure"}, "asset"},
+	TierAppGroupings: {"app_groupings", "app_grouping_id", "asset_id", []string{"name", "description"}, "app_grouping"},
+	TierApplications: {"applications", "application_id", "app_grouping_id", []string{"name", "description"}, "application"},
+	TierComponents:   {"components", "component_id", "application_id", []string{"name", "component_type_id", "component_class_id", "description"}, "component"},
+}
+
+// parentTier maps each tier to the tier its parentCol points at.
+var parentTier = map[string]string{
+	TierAssets:       TierPortfolios,
+	TierAppGroupings: TierAssets,
+	TierApplications: TierAppGroupings,
+	TierComponents:   TierApplications,
+}
+
+// row is one ServiceNow record mapped onto a tier's columns.
+type row struct {
+	sysID  string
+	parent string // parent's snow_sys_id
+	values []*string
+}
+
+// Run imports the requested tiers (all when empty) in one transaction.
+func (im *Importer) Run(ctx context.Context, only []string) (map[string]*TierResult, error) {
+	if len(only) == 0 {
+		only = AllTiers
+	}
+	want := map[string]bool{}
+	for _, t := range only {
+		if _, ok := tiers[t]; !ok {
+			return nil, fmt.Errorf("unknown tier %q", t)
+		}
+		want[t] = true
+	}
+
+	tx, err := im.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	canon := store.NewCanonicalizer(tx)
+	results := map[string]*TierResult{}
+	for _, name := range AllTiers {
+		if !want[name] {
+			continue
+		}
+		rows, err := im.fetch(ctx, tx, name)
+		if err != nil {
+			return nil, fmt.Errorf("fetch %s: %w", name, err)
+		}
+		res, err := upsertTier(ctx, tx, canon, name, rows)
+		if err != nil {
+			return nil, fmt.Errorf("upsert %s: %w", name, err)
+		}
+		results[name] = res
+	}
+
+	if im.Audit != nil {
+		if err := im.Audit(tx, results); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// fetch pulls one tier from ServiceNow and maps it onto CMDB columns.
+func (im *Importer) fetch(ctx context.Context, tx *sql.Tx, name string) ([]row, error) {
+	m := im.Mapping
+	switch name {
+	case TierPortfolios:
+		recs, err := im.Client.Query(ctx, "pm_portfolio", "active=true", "sys_id", "name", "state")
+		if err != nil {
+			return nil, err
+		}
+		out := make([]row, 0, len(recs))
+		for _, r := range recs {
+			out = append(out, row{sysID: r.Get("sys_id"), values: strs(r.Get("name"), r.Get("state"))})
+		}
+		return out, nil
+
+	case TierAssets:
+		recs, err := im.Client.Query(ctx, "cmdb_ci_service", "operational_status=1",
+			"sys_id", "name", "u_business_service_fullname", "short_description", "busines_criticality",
+			"used_for", "category", "u_infrastructure", "u_product_portfolio")
+		if err != nil {
+			return nil, err
+		}
+		out := make([]row, 0, len(recs))
+		for _, r := range recs {
+			out = append(out, row{
+				sysID:  r.Get("sys_id"),
+				parent: r.Get("u_product_portfolio"),
+				values: strs(r.Get("name"), r.Get("u_business_service_fullname"), r.Get("short_description"),
+					r.Get("busines_criticality"), r.Get("used_for"), r.Get("category"), r.Get("u_infrastructure")),
+			})
+		}
+		return out, nil
+
+	case TierAppGroupings:
+		return im.fetchChildren(ctx, "cmdb_ci_service", "child.sys_class_name="+m.AppGroupingClass, nil)
+
+	case TierApplications:
+		return im.fetchChildren(ctx, m.AppGroupingClass, "child.sys_class_name="+m.ApplicationClass, nil)
+
+	case TierComponents:
+		types, err := componentTypes(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		classes := make([]string, 0, len(types))
+		for cls := range types {
+			classes = append(classes, cls)
+		}
+		if len(classes) == 0 {
+			return nil, nil
+		}
+		return im.fetchChildren(ctx, m.ApplicationClass, "child.sys_class_nameIN"+strings.Join(classes, ","), types)
+	}
+	return nil, fmt.Errorf("unknown tier %q", name)
+}
+
+// fetchChildren reads cmdb_rel_ci links from parents of parentClass to
+// children matching childFilter. When types is non-nil the child's class is
+// resolved to component_type_id/component_class_id (component tier).
+// A child linked to several parents is attached to the first one.
+func (im *Importer) fetchChildren(ctx context.Context, parentClass, childFilter string, types map[string][2]*string) ([]row, error) {
+	recs, err := im.Client.Query(ctx, "cmdb_rel_ci",
+		"parent.sys_class_name="+parentClass+"^"+childFilter,
+		"parent", "child", "child.name", "child.short_description", "child.sys_class_name")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var out []row
+	for _, r := range recs {
+		child := r.Get("child")
+		if child == "" || seen[child] {
+			continue
+		}
+		seen[child] = true
+
+		var values []*string
+		if types != nil {
+			t := types[r.Get("child.sys_class_name")]
+			values = []*string{str(r.Get("child.name")), t[0], t[1], str(r.Get("child.short_description"))}
+		} else {
+			values = strs(r.Get("child.name"), r.Get("child.short_description"))
+		}
+		out = append(out, row{sysID: child, parent: r.Get("parent"), values: values})
+	}
+	return out, nil
+}
+
+// componentTypes maps ServiceNow sys_class_name → [component_type_id, component_class_id].
+func componentTypes(ctx context.Context, tx *sql.Tx) (map[string][2]*string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT class_name, component_type_id, component_class_id FROM component_types")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string][2]*string{}
+	for rows.Next() {
+		var cls, typeID string
+		var classID sql.NullString
+		if err := rows.Scan(&cls, &typeID, &classID); err != nil {
+			return nil, err
+		}
+		entry := [2]*string{&typeID, nil}
+		if classID.Valid {
+			entry[1] = &classID.String
+		}
+		out[cls] = entry
+	}
+	return out, rows.Err()
+}
+
+// upsertTier writes rows into one tier keyed by snow_sys_id. A row with no
+// sys_id match adopts an existing unlinked row with the same name under the
+// same parent, so hand-entered data is linked rather than duplicated.
+// Vocabulary columns are normalized as API writes are; a row with a value
+// the vocabulary does not know fails. Rows in the trash are left alone, as
+// are rows whose parent is: the import neither revives nor re-creates them.
+func upsertTier(ctx context.Context, tx *sql.Tx, canon *store.Canonicalizer, name string, rows []row) (*TierResult, error) {
+	t := tiers[name]
+	res := &TierResult{Fetched: len(rows), Failures: []RowFailure{}, Trashed: []string{}}
+
+	var parents map[string]ref
+	if t.parentCol != "" {
+		p := tiers[parentTier[name]]
+		var err error
+		if parents, err = sysIDMap(ctx, tx, p.table, p.pk); err != nil {
+			return nil, err
+		}
+	}
+
+	cols := t.fields
+	if t.parentCol != "" {
+		cols = append([]string{t.parentCol}, t.fields...)
+	}
+	selectCols := t.pk + ", " + strings.Join(cols, ", ")
+
+	for _, r := range rows {
+		if r.sysID == "" || r.values[0] == nil {
+			res.Skipped++
+			continue
+		}
+		values, err := canonical(ctx, canon, t, r.values)
+		var ve *store.VocabularyError
+		if errors.As(err, &ve) {
+			res.fail(r.sysID, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t.parentCol != "" {
+			parent, ok := parents[r.parent]
+			if !ok {
+				res.Skipped++
+				continue
+			}
+			if parent.trashed {
+				res.Skipped++
+				res.Trashed = append(res.Trashed, r.sysID)
+				continue
+			}
+			values = append([]*string{&parent.id}, values...)
+		}
+
+		id, current, trashed, err := findExisting(ctx, tx, t, selectCols, len(cols), r.sysID, values)
+		if err != nil {
+			return nil, err
+		}
+		if trashed {
+			res.Skipped++
+			res.Trashed = append(res.Trashed, r.sysID)
+			continue
+		}
+
+		if id == "" {
+			id = uuid.New().String()
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(cols)+2), ", ")
+			args := []any{id, r.sysID}
+			for _, v := range values {
+				args = append(args, v)
+			}
+			_, err := tx.ExecContext(ctx,
+				fmt.Sprintf("INSERT INTO %s (%s, snow_sys_id, %s) VALUES (%s)", t.table, t.pk, strings.Join(cols, ", "), placeholders),
+				args...)
+			if err != nil {
+				res.fail(r.sysID, store.Classify(err))
+				continue
+			}
+			if err := res.change(ctx, tx, t, id, store.AuditCreate, nil); err != nil {
+				return nil, err
+			}
+			res.Created++
+			continue
+		}
+
+		if current != nil && equal(current, values) {
+			res.Unchanged++
+			continue
+		}
+
+		before, err := store.Snapshot(ctx, tx, t.entity, id)
+		if err != nil {
+			return nil, err
+		}
+		sets := make([]string, len(cols))
+		args := make([]any, 0, len(cols)+2)
+		for i, col := range cols {
+			sets[i] = col + " = ?"
+			args = append(args, values[i])
+		}
+		args = append(args, r.sysID, id)
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("UPDATE %s SET %s, snow_sys_id = ?, updated_at = datetime('now') WHERE %s = ?", t.table, strings.Join(sets, ", "), t.pk),
+			args...)
+		if err != nil {
+			res.fail(r.sysID, store.Classify(err))
+			continue
+		}
+		if err := res.change(ctx, tx, t, id, store.AuditUpdate, before); err != nil {
+			return nil, err
+		}
+		res.Updated++
+	}
+	return res, nil
+}
+
+// change adds the audit entry for a row just written.
+func (res *TierResult) change(ctx context.Context, tx *sql.Tx, t tier, id, action string, before any) error {
+	after, err := store.Snapshot(ctx, tx, t.entity, id)
+	if err != nil {
+		return err
+	}
+	res.Changes = append(res.Changes, store.Change(t.entity, id, action, before, after))
+	return nil
+}
+
+// canonical normalizes the vocabulary columns among a row's field values.
+func canonical(ctx context.Context, canon *store.Canonicalizer, t tier, values []*string) ([]*string, error) {
+	out := make([]*string, len(values))
+	for i, v := range values {
+		var err error
+		if out[i], err = canon.Canonical(ctx, t.table, t.fields[i], v); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// findExisting looks a row up by snow_sys_id, then by name (+ parent) among
+// rows without a sys_id. current is nil when the row was adopted by name so
+// that it is always updated to record its sys_id. trashed reports that the
+// row found is in the trash, where it still holds its sys_id and name.
+func findExisting(ctx context.Context, tx *sql.Tx, t tier, selectCols string, n int, sysID string, values []*string) (id string, current []sql.NullString, trashed bool, err error) {
+	scan := func(query string, args ...any) (string, []sql.NullString, bool, error) {
+		var id string
+		var trashed bool
+		current := make([]sql.NullString, n)
+		dest := []any{&trashed, &id}
+		for i := range current {
+			dest = append(dest, &current[i])
+		}
+		err := tx.QueryRowContext(ctx, query, args...).Scan(dest...)
+		if err == sql.ErrNoRows {
+			return "", nil, false, nil
+		}
+		return id, current, trashed, err
+	}
+	selectCols = "deleted_at IS NOT NULL, " + selectCols
+
+	id, current, trashed, err = scan(fmt.Sprintf("SELECT %s FROM %s WHERE snow_sys_id = ?", selectCols, t.table), sysID)
+	if err != nil || id != "" {
+		return id, current, trashed, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE snow_sys_id IS NULL AND name = ?", selectCols, t.table)
+	args := []any{values[len(values)-len(t.fields)]}
+	if t.parentCol != "" {
+		query += " AND " + t.parentCol + " = ?"
+		args = append(args, values[0])
+	}
+	id, _, trashed, err = scan(query+" ORDER BY deleted_at IS NOT NULL LIMIT 1", args...)
+	return id, nil, trashed, err
+}
+
+// ref is a row found by snow_sys_id.
+type ref struct {
+	id      string
+	trashed bool
+}
+
+// sysIDMap maps snow_sys_id → row for a table, trashed rows included.
+func sysIDMap(ctx context.Context, tx *sql.Tx, table, pk string) (map[string]ref, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT snow_sys_id, %s, deleted_at IS NOT NULL FROM %s WHERE snow_sys_id IS NOT NULL", pk, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]ref{}
+	for rows.Next() {
+		var sysID string
+		var r ref
+		if err := rows.Scan(&sysID, &r.id, &r.trashed); err != nil {
+			return nil, err
+		}
+		out[sysID] = r
+	}
+	return out, rows.Err()
+}
+
+func equal(current []sql.NullString, values []*string) bool {
+	for i, v := range values {
+		if (v == nil) != !current[i].Valid {
+			return false
+		}
+		if v != nil && *v != current[i].String {
+			return false
+		}
+	}
+	return true
+}
+
+// str returns nil for "" so empty ServiceNow fields are stored as NULL.
+func str(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func strs(vals ...string) []*string {
+	out := make([]*string, len(vals))
+	for i, v := range vals {
+		out[i] = str(v)
+	}
+	return out
+}
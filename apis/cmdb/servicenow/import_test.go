@@ -0,0 +1,154 @@
+package servicenow
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jihaia/aperture/apis/cmdb/store"
+	migrations "github.com/jihaia/aperture/packages/migrations"
+	_ "modernc.org/sqlite"
+)
+
+var testDBs atomic.Int64
+
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:snow%d?mode=memory&_pragma=foreign_keys(ON)", testDBs.Add(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrations.Output = io.Discard
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// upsert runs one tier's rows in a committed transaction.
+func upsert(t *testing.T, db *sql.DB, name string, rows []row) *TierResult {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	res, err := upsertTier(ctx, tx, store.NewCanonicalizer(tx), name, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func asset(sysID, name, criticality, environment string) row {
+	return row{sysID: sysID, parent: "p1", values: strs(name, "", "", criticality, environment, "", "")}
+}
+
+func TestUpsertTierCanonicalizes(t *testing.T) {
+	db := testDB(t)
+	upsert(t, db, TierPortfolios, []row{{sysID: "p1", values: strs("Payments", "")}})
+
+	res := upsert(t, db, TierAssets, []row{
+		asset("a1", "Ledger", "1 - most critical", "Prod"),
+		asset("a2", "Cards", "low", "DR"),
+		asset("a3", "Loans", "severe", "prod"),
+	})
+	if res.Created != 2 || res.Errors != 1 {
+		t.Fatalf("created %d, errors %d; want 2, 1", res.Created, res.Errors)
+	}
+	if len(res.Failures) != 1 || res.Failures[0].SysID != "a3" {
+		t.Errorf("failures = %+v, want a3's unknown criticality", res.Failures)
+	}
+	if len(res.Changes) != 2 || res.Changes[0].Entity != "asset" || res.Changes[0].Action != store.AuditCreate {
+		t.Errorf("changes = %+v, want an asset create per row written", res.Changes)
+	}
+
+	tests := []struct{ sysID, criticality, environment string }{
+		{"a1", "critical", "production"},
+		{"a2", "low", "disaster recovery"},
+	}
+	for _, tt := range tests {
+		var crit, env string
+		err := db.QueryRow("SELECT criticality, environment FROM assets WHERE snow_sys_id = ?", tt.sysID).Scan(&crit, &env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if crit != tt.criticality || env != tt.environment {
+			t.Errorf("%s: %s/%s, want %s/%s", tt.sysID, crit, env, tt.criticality, tt.environment)
+		}
+	}
+
+	// A rerun with the raw spellings changes nothing.
+	res = upsert(t, db, TierAssets, []row{asset("a1", "Ledger", "1 - most critical", "Prod")})
+	if res.Unchanged != 1 || len(res.Changes) != 0 {
+		t.Errorf("rerun: %+v, want unchanged", res)
+	}
+
+	res = upsert(t, db, TierAssets, []row{asset("a1", "Ledger", "low", "Prod")})
+	if len(res.Changes) != 1 || res.Changes[0].Action != store.AuditUpdate || res.Changes[0].Before == nil {
+		t.Errorf("changes = %+v, want an update with the row before it", res.Changes)
+	}
+}
+
+func TestUpsertTierLeavesTrashAlone(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	upsert(t, db, TierPortfolios, []row{
+		{sysID: "p1", values: strs("Payments", "")},
+		{sysID: "p2", values: strs("Lending", "")},
+	})
+	upsert(t, db, TierAssets, []row{asset("a1", "Ledger", "high", "prod")})
+
+	var assetID, portfolioID string
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(db.QueryRow("SELECT asset_id FROM assets WHERE snow_sys_id = 'a1'").Scan(&assetID))
+	must(db.QueryRow("SELECT portfolio_id FROM portfolios WHERE snow_sys_id = 'p2'").Scan(&portfolioID))
+	s := store.New(db)
+	must(s.Assets.Delete(ctx, assetID, 0))
+	must(s.Portfolios.Delete(ctx, portfolioID, 0))
+	// A hand-entered row in the trash still holds its name, so a namesake
+	// is neither adopted nor created beside it.
+	_, err := db.Exec(`INSERT INTO assets (asset_id, name, portfolio_id, deleted_at, deletion_id)
+		SELECT 'manual', 'Cards', portfolio_id, datetime('now'), 'd' FROM portfolios WHERE snow_sys_id = 'p1'`)
+	must(err)
+
+	b := asset("a3", "Loans", "low", "prod")
+	b.parent = "p2"
+	res := upsert(t, db, TierAssets, []row{
+		asset("a1", "Ledger renamed", "low", "prod"), // trashed itself
+		asset("a2", "Cards", "low", "prod"),          // trashed namesake
+		b,                                            // trashed parent
+	})
+	if res.Skipped != 3 || res.Created+res.Updated+res.Errors != 0 {
+		t.Fatalf("result %+v, want all 3 skipped", res)
+	}
+	if fmt.Sprint(res.Trashed) != "[a1 a2 a3]" {
+		t.Errorf("trashed = %v, want [a1 a2 a3]", res.Trashed)
+	}
+
+	var name string
+	must(db.QueryRow("SELECT name FROM assets WHERE asset_id = ?", assetID).Scan(&name))
+	if name != "Ledger" {
+		t.Errorf("trashed row was updated to %q", name)
+	}
+	var manual sql.NullString
+	must(db.QueryRow("SELECT snow_sys_id FROM assets WHERE asset_id = 'manual'").Scan(&manual))
+	if manual.Valid {
+		t.Errorf("trashed row adopted sys_id %s", manual.String)
+	}
+}
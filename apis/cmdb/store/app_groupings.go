@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// AppGrouping is tier 3 of the BIA hierarchy.
+type AppGrouping struct {
+	AppGroupingID string    `json:"app_grouping_id"`
+	Name          string    `json:"name"`
+	AssetID       string    `json:"asset_id"`
+	SnowSysID     *string   `json:"snow_sys_id"`
+	Description   *string   `json:"description"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Version       int       `json:"version"`
+}
+
+// AppGroupingUpdate holds the fields to change; nil fields are left as is.
+type AppGroupingUpdate struct {
+	Name        *string `json:"name"`
+	AssetID     *string `json:"asset_id"`
+	SnowSysID   *string `json:"snow_sys_id"`
+	Description *string `json:"description"`
+}
+
+type AppGroupingFilter struct {
+	Q string
+	listing.Page
+	listing.Filter
+}
+
+type AppGroupingRepository interface {
+	List(ctx context.Context, f AppGroupingFilter) (*listing.Result[AppGrouping], error)
+	Get(ctx context.Context, id string) (*AppGrouping, error)
+	Create(ctx context.Context, g *AppGrouping) error
+	Update(ctx context.Context, id string, version int, u AppGroupingUpdate) (*AppGrouping, error)
+	Patch(ctx context.Context, id string, version int, p Patch) (*AppGrouping, error)
+	Delete(ctx context.Context, id string, version int) error
+	DeleteImpact(ctx context.Context, id string, version int) (*Impact, error)
+}
+
+var appGroupings = table[AppGrouping]{
+	name:    "app_groupings",
+	pk:      "app_grouping_id",
+	columns: "app_grouping_id, name, asset_id, snow_sys_id, description, created_at, updated_at, version",
+	order:   []listing.Order{{Column: "name"}},
+	scan: func(s scanner) (*AppGrouping, error) {
+		var g AppGrouping
+		err := s.Scan(&g.AppGroupingID, &g.Name, &g.AssetID, &g.SnowSysID, &g.Description,
+			ts(&g.CreatedAt), ts(&g.UpdatedAt), &g.Version)
+		return &g, err
+	},
+}
+
+type appGroupingRepo struct{ db DBTX }
+
+func (r *appGroupingRepo) List(ctx context.Context, f AppGroupingFilter) (*listing.Result[AppGrouping], error) {
+	var w where
+	w.like("name", f.Q)
+	return appGroupings.list(ctx, r.db, w, f.Page, f.Filter)
+}
+
+func (r *appGroupingRepo) Get(ctx context.Context, id string) (*AppGrouping, error) {
+	return appGroupings.get(ctx, r.db, id)
+}
+
+func (r *appGroupingRepo) Create(ctx context.Context, g *AppGrouping) error {
+	if g.AppGroupingID == "" {
+		g.AppGroupingID = newID()
+	}
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO app_groupings (app_grouping_id, name, asset_id, snow_sys_id, description) VALUES (?, ?, ?, ?, ?)",
+		g.AppGroupingID, g.Name, g.AssetID, g.SnowSysID, g.Description)
+	if err != nil {
+		return appGroupings.explain(ctx, r.db, err, "", fields(g))
+	}
+	saved, err := r.Get(ctx, g.AppGroupingID)
+	if err != nil {
+		return err
+	}
+	*g = *saved
+	return nil
+}
+
+func (r *appGroupingRepo) Update(ctx context.Context, id string, version int, u AppGroupingUpdate) (*AppGrouping, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE app_groupings SET
+			name=COALESCE(?,name), asset_id=COALESCE(?,asset_id),
+			snow_sys_id=COALESCE(?,snow_sys_id), description=COALESCE(?,description),
+			updated_at=datetime('now')
+		 WHERE app_grouping_id=? AND (?=0 OR version=?) AND deleted_at IS NULL`,
+		u.Name, u.AssetID, u.SnowSysID, u.Description, id, version, version)
+	if err != nil {
+		return nil, appGroupings.explain(ctx, r.db, err, id, fields(u))
+	}
+	if err := appGroupings.matched(ctx, r.db, res, id); err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+// Patch applies a JSON Merge Patch; null clears a nullable column.
+func (r *appGroupingRepo) Patch(ctx context.Context, id string, version int, p Patch) (*AppGrouping, error) {
+	sets, args, err := assignments[AppGrouping, AppGroupingUpdate](p)
+	if err != nil {
+		return nil, err
+	}
+	return appGroupings.patch(ctx, r.db, id, version, sets, args)
+}
+
+func (r *appGroupingRepo) Delete(ctx context.Context, id string, version int) error {
+	return appGroupings.delete(ctx, r.db, id, version)
+}
+
+func (r *appGroupingRepo) DeleteImpact(ctx context.Context, id string, version int) (*Impact, error) {
+	return appGroupings.deleteImpact(ctx, r.db, id, version)
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// Application is tier 4 of the BIA hierarchy.
+type Application struct {
+	ApplicationID string    `json:"application_id"`
+	Name          string    `json:"name"`
+	AppGroupingID string    `json:"app_grouping_id"`
+	SnowSysID     *string   `json:"snow_sys_id"`
+	Description   *string   `json:"description"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Version       int       `json:"version"`
+}
+
+// ApplicationUpdate holds the fields to change; nil fields are left as is.
+type ApplicationUpdate struct {
+	Name          *string `json:"name"`
+	AppGroupingID *string `json:"app_grouping_id"`
+	SnowSysID     *string `json:"snow_sys_id"`
+	Description   *string `json:"description"`
+}
+
+type ApplicationFilter struct {
+	Q string
+	listing.Page
+	listing.Filter
+}
+
+type ApplicationRepository interface {
+	List(ctx context.Context, f ApplicationFilter) (*listing.Result[Application], error)
+	Get(ctx context.Context, id string) (*Application, error)
+	Create(ctx context.Context, a *Application) error
+	Update(ctx context.Context, id string, version int, u ApplicationUpdate) (*Application, error)
+	Patch(ctx context.Context, id string, version int, p Patch) (*Application, error)
+	Delete(ctx context.Context, id string, version int) error
+	DeleteImpact(ctx context.Context, id string, version int) (*Impact, error)
+}
+
+var applications = table[Application]{
+	name:    "applications",
+	pk:      "application_id",
+	columns: "application_id, name, app_grouping_id, snow_sys_id, description, created_at, updated_at, version",
+	order:   []listing.Order{{Column: "name"}},
+	scan: func(s scanner) (*Application, error) {
+		var a Application
+		err := s.Scan(&a.ApplicationID, &a.Name, &a.AppGroupingID, &a.SnowSysID, &a.Description,
+			ts(&a.CreatedAt), ts(&a.UpdatedAt), &a.Version)
+		return &a, err
+	},
+}
+
+type applicationRepo struct{ db DBTX }
+
+func (r *applicationRepo) List(ctx context.Context, f ApplicationFilter) (*listing.Result[Application], error) {
+	var w where
+	w.like("name", f.Q)
+	return applications.list(ctx, r.db, w, f.Page, f.Filter)
+}
+
+func (r *applicationRepo) Get(ctx context.Context, id string) (*Application, error) {
+	return applications.get(ctx, r.db, id)
+}
+
+func (r *applicationRepo) Create(ctx context.Context, a *Application) error {
+	if a.ApplicationID == "" {
+		a.ApplicationID = newID()
+	}
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO applications (application_id, name, app_grouping_id, snow_sys_id, description) VALUES (?, ?, ?, ?, ?)",
+		a.ApplicationID, a.Name, a.AppGroupingID, a.SnowSysID, a.Description)
+	if err != nil {
+		return applications.explain(ctx, r.db, err, "", fields(a))
+	}
+	saved, err := r.Get(ctx, a.ApplicationID)
+	if err != nil {
+		return err
+	}
+	*a = *saved
+	return nil
+}
+
+func (r *applicationRepo) Update(ctx context.Context, id string, version int, u ApplicationUpdate) (*Application, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE applications SET
+			name=COALESCE(?,name), app_grouping_id=COALESCE(?,app_grouping_id),
+			snow_sys_id=COALESCE(?,snow_sys_id), description=COALESCE(?,description),
+			updated_at=datetime('now')
+		 WHERE application_id=? AND (?=0 OR version=?) AND deleted_at IS NULL`,
+		u.Name, u.AppGroupingID, u.SnowSysID, u.Description, id, version, version)
+	if err != nil {
+		return nil, applications.explain(ctx, r.db, err, id, fields(u))
+	}
+	if err := applications.matched(ctx, r.db, res, id); err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+// Patch applies a JSON Merge Patch; null clears a nullable column.
+func (r *applicationRepo) Patch(ctx context.Context, id string, version int, p Patch) (*Application, error) {
+	sets, args, err := assignments[Application, ApplicationUpdate](p)
+	if err != nil {
+		return nil, err
+	}
+	return applications.patch(ctx, r.db, id, version, sets, args)
+}
+
+func (r *applicationRepo) Delete(ctx context.Context, id string, version int) error {
+	return applications.delete(ctx, r.db, id, version)
+}
+
+func (r *applicationRepo) DeleteImpact(ctx context.Context, id string, version int) (*Impact, error) {
+	return applications.deleteImpact(ctx, r.db, id, version)
+}
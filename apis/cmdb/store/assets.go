@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// Asset is tier 2 of the BIA hierarchy (a ServiceNow business service).
+type Asset struct {
+	AssetID        string    `json:"asset_id"`
+	Name           string    `json:"name"`
+	PortfolioID    string    `json:"portfolio_id"`
+	SnowSysID      *string   `json:"snow_sys_id"`
+	FullName       *string   `json:"full_name"`
+	Description    *string   `json:"description"`
+	Criticality    *string   `json:"criticality"`
+	Environment    *string   `json:"environment"`
+	Category       *string   `json:"category"`
+	Infrastructure *string   `json:"infrastructure"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Version        int       `json:"version"`
+}
+
+// AssetUpdate holds the fields to change; nil fields are left as is.
+type AssetUpdate struct {
+	Name           *string `json:"name"`
+	PortfolioID    *string `json:"portfolio_id"`
+	SnowSysID      *string `json:"snow_sys_id"`
+	FullName       *string `json:"full_name"`
+	Description    *string `json:"description"`
+	Criticality    *string `json:"criticality"`
+	Environment    *string `json:"environment"`
+	Category       *string `json:"category"`
+	Infrastructure *string `json:"infrastructure"`
+}
+
+type AssetFilter struct {
+	Q string
+	listing.Page
+	listing.Filter
+}
+
+type AssetRepository interface {
+	List(ctx context.Context, f AssetFilter) (*listing.Result[Asset], error)
+	Get(ctx context.Context, id string) (*Asset, error)
+	Create(ctx context.Context, a *Asset) error
+	Update(ctx context.Context, id string, version int, u AssetUpdate) (*Asset, error)
+	Patch(ctx context.Context, id string, version int, p Patch) (*Asset, error)
+	Delete(ctx context.Context, id string, version int) error
+	DeleteImpact(ctx context.Context, id string, version int) (*Impact, error)
+}
+
+var assets = table[Asset]{
+	name: "assets",
+	pk:   "asset_id",
+	columns: "asset_id, name, portfolio_id, snow_sys_id, full_name, description, criticality, " +
+		"environment, category, infrastructure, created_at, updated_at, version",
+	order: []listing.Order{{Column: "name"}},
+	scan: func(s scanner) (*Asset, error) {
+		var a Asset
+		err := s.Scan(&a.AssetID, &a.Name, &a.PortfolioID, &a.SnowSysID, &a.FullName, &a.Description,
+			&a.Criticality, &a.Environment, &a.Category, &a.Infrastructure,
+			ts(&a.CreatedAt), ts(&a.UpdatedAt), &a.Version)
+		return &a, err
+	},
+	vocab: map[string]string{"criticality": VocabCriticality, "environment": VocabEnvironment},
+}
+
+type assetRepo struct{ db DBTX }
+
+func (r *assetRepo) List(ctx context.Context, f AssetFilter) (*listing.Result[Asset], error) {
+	var w where
+	w.like("name", f.Q)
+	return assets.list(ctx, r.db, w, f.Page, f.Filter)
+}
+
+func (r *assetRepo) Get(ctx context.Context, id string) (*Asset, error) {
+	return assets.get(ctx, r.db, id)
+}
+
+func (r *assetRepo) Create(ctx context.Context, a *Asset) error {
+	if a.AssetID == "" {
+		a.AssetID = newID()
+	}
+	if err := assets.canonicalize(ctx, r.db, &vocabCache{}, a); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO assets (asset_id, name, portfolio_id, snow_sys_id, full_name, description, criticality, environment, category, infrastructure)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.AssetID, a.Name, a.PortfolioID, a.SnowSysID, a.FullName, a.Description,
+		a.Criticality, a.Environment, a.Category, a.Infrastructure)
+	if err != nil {
+		return assets.explain(ctx, r.db, err, "", fields(a))
+	}
+	saved, err := r.Get(ctx, a.AssetID)
+	if err != nil {
+		return err
+	}
+	*a = *saved
+	return nil
+}
+
+func (r *assetRepo) Update(ctx context.Context, id string, version int, u AssetUpdate) (*Asset, error) {
+	if err := assets.canonicalize(ctx, r.db, &vocabCache{}, &u); err != nil {
+		return nil, err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE assets SET
+			name=COALESCE(?,name), portfolio_id=COALESCE(?,portfolio_id),
+			snow_sys_id=COALESCE(?,snow_sys_id), full_name=COALESCE(?,full_name),
+			description=COALESCE(?,description), criticality=COALESCE(?,criticality),
+			environment=COALESCE(?,environment), category=COALESCE(?,category),
+			infrastructure=COALESCE(?,infrastructure), updated_at=datetime('now')
+		 WHERE asset_id=? AND (?=0 OR version=?) AND deleted_at IS NULL`,
+		u.Name, u.PortfolioID, u.SnowSysID, u.FullName,
+		u.Description, u.Criticality, u.Environment, u.Category,
+		u.Infrastructure, id, version, version)
+	if err != nil {
+		return nil, assets.explain(ctx, r.db, err, id, fields(u))
+	}
+	if err := assets.matched(ctx, r.db, res, id); err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+// Patch applies a JSON Merge Patch; null clears a nullable column.
+func (r *assetRepo) Patch(ctx context.Context, id string, version int, p Patch) (*Asset, error) {
+	sets, args, err := assignments[Asset, AssetUpdate](p)
+	if err != nil {
+		return nil, err
+	}
+	return assets.patch(ctx, r.db, id, version, sets, args)
+}
+
+func (r *assetRepo) Delete(ctx context.Context, id string, version int) error {
+	return assets.delete(ctx, r.db, id, version)
+}
+
+func (r *assetRepo) DeleteImpact(ctx context.Context, id string, version int) (*Impact, error) {
+	return assets.deleteImpact(ctx, r.db, id, version)
+}
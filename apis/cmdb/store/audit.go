@@ -0,0 +1,351 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// Audit actions.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditLink    = "link"
+	AuditUnlink  = "unlink"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+	AuditImport  = "import"
+)
+
+// AuditEntry is one recorded change. Before and After hold the row as it
+// was and as it became; on read they are the stored JSON.
+type AuditEntry struct {
+	AuditID  int64   `json:"audit_id"`
+	Entity   string  `json:"entity"`
+	EntityID *string `json:"entity_id"`
+	Action   string  `json:"action"`
+	// RelatedEntity and RelatedID name a second row the change concerns,
+	// such as the workload of a link, so it shows in that row's history.
+	RelatedEntity *string   `json:"related_entity"`
+	RelatedID     *string   `json:"related_id"`
+	Before        any       `json:"before"`
+	After         any       `json:"after"`
+	Actor         *string   `json:"actor"`
+	RequestID     *string   `json:"request_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Change returns an entry for action on one row.
+func Change(entity, id, action string, before, after any) *AuditEntry {
+	e := &AuditEntry{Entity: entity, Action: action, Before: before, After: after}
+	if id != "" {
+		e.EntityID = &id
+	}
+	return e
+}
+
+// Relate names the second row the change concerns.
+func (e *AuditEntry) Relate(entity, id string) *AuditEntry {
+	e.RelatedEntity, e.RelatedID = &entity, &id
+	return e
+}
+
+type AuditFilter struct {
+	listing.Page
+	listing.Filter
+}
+
+type AuditRepository interface {
+	// List returns entries newest first; the generic filters narrow them
+	// by entity, action, actor, request_id, created_at and so on.
+	List(ctx context.Context, f AuditFilter) (*listing.Result[AuditEntry], error)
+	// History returns the entries for one row, including those naming it
+	// as the related row, newest first.
+	History(ctx context.Context, entity, id string, f AuditFilter) (*listing.Result[AuditEntry], error)
+	Record(ctx context.Context, e *AuditEntry) error
+}
+
+var auditLog = table[AuditEntry]{
+	name: "audit_log",
+	pk:   "audit_id",
+	columns: "audit_id, entity, entity_id, action, related_entity, related_id, before_data, after_data, " +
+		"actor, request_id, created_at",
+	order: []listing.Order{{Column: "audit_id", Desc: true}},
+	scan: func(s scanner) (*AuditEntry, error) {
+		var e AuditEntry
+		var before, after sql.NullString
+		err := s.Scan(&e.AuditID, &e.Entity, &e.EntityID, &e.Action, &e.RelatedEntity, &e.RelatedID,
+			&before, &after, &e.Actor, &e.RequestID, ts(&e.CreatedAt))
+		if before.Valid {
+			e.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			e.After = json.RawMessage(after.String)
+		}
+		return &e, err
+	},
+}
+
+type auditRepo struct{ db DBTX }
+
+func (r *auditRepo) List(ctx context.Context, f AuditFilter) (*listing.Result[AuditEntry], error) {
+	return auditLog.list(ctx, r.db, where{}, f.Page, f.Filter)
+}
+
+func (r *auditRepo) History(ctx context.Context, entity, id string, f AuditFilter) (*listing.Result[AuditEntry], error) {
+	var w where
+	w.add("((entity = ? AND entity_id = ?) OR (related_entity = ? AND related_id = ?))", entity, id, entity, id)
+	return auditLog.list(ctx, r.db, w, f.Page, f.Filter)
+}
+
+// Record appends e to the log. Run it in the transaction making the
+// change so both are committed or neither is.
+func (r *auditRepo) Record(ctx context.Context, e *AuditEntry) error {
+	before, err := auditJSON(e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := auditJSON(e.After)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO audit_log (entity, entity_id, action, related_entity, related_id, before_data, after_data, actor, request_id)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Entity, e.EntityID, e.Action, e.RelatedEntity, e.RelatedID, before, after, e.Actor, e.RequestID)
+	if err != nil {
+		return err
+	}
+	e.AuditID, err = res.LastInsertId()
+	return err
+}
+
+// Snapshot reads one row of a hierarchy entity or a workload, in the trash
+// or not, as it is recorded in the audit log.
+func Snapshot(ctx context.Context, db DBTX, entity, id string) (any, error) {
+	table := "workloads"
+	if entity != "workload" {
+		i, err := tierOf(entity)
+		if err != nil {
+			return nil, err
+		}
+		table = tiers[i].table
+	}
+	m := tablesByName[table]
+	row, err := m.scan(db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", m.columns, m.name, m.pk), id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if w, ok := row.(*Workload); ok {
+		list := []Workload{*w}
+		if err := loadInterfaces(ctx, db, list); err != nil {
+			return nil, err
+		}
+		return &list[0], nil
+	}
+	return row, nil
+}
+
+// AuditedEntity returns the entity the audit log records rows of table
+// as: the hierarchy tiers and workloads.
+func AuditedEntity(table string) (string, bool) {
+	if table == "workloads" {
+		return "workload", true
+	}
+	if i := tierIndex(table); i >= 0 {
+		return tiers[i].typ, true
+	}
+	return "", false
+}
+
+// Written returns the entry for a row written outside the store, given its
+// Snapshot from before the write or nil for a new row. It is nil when the
+// write changed nothing but bookkeeping.
+func Written(ctx context.Context, db DBTX, entity, id string, before any) (*AuditEntry, error) {
+	after, err := Snapshot(ctx, db, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return Change(entity, id, AuditCreate, nil, after), nil
+	}
+	if !changed(before, after) {
+		return nil, nil
+	}
+	return Change(entity, id, AuditUpdate, before, after), nil
+}
+
+// Cascade is what a delete, restore or purge of one row does beyond the
+// row itself: the rows beneath it that go or come back with it, and the
+// workload links that disappear or reappear. Build it before the write
+// and take its Entries after, so each row is recorded as it was before a
+// delete or purge and as it is after a restore.
+type Cascade struct {
+	action string
+	rows   []cascaded
+	links  []Link
+}
+
+type cascaded struct {
+	entity, id string
+	related    string // the workload of a validation
+	before     any
+}
+
+// NewCascade collects the rows and links an action on one hierarchy row
+// (delete, restore or purge) or workload (delete) will carry with it.
+func NewCascade(ctx context.Context, db DBTX, entity, id, action string) (*Cascade, error) {
+	im, err := cascadeImpact(ctx, db, entity, id, action)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cascade{action: action, links: im.Unlinked}
+	for _, t := range tiers {
+		for _, rid := range im.Deleted[t.table] {
+			if t.typ != entity {
+				c.rows = append(c.rows, cascaded{entity: t.typ, id: rid})
+			}
+		}
+	}
+	for _, vid := range im.Deleted["workload_validations"] {
+		c.rows = append(c.rows, cascaded{entity: "workload_validation", id: vid, related: id})
+	}
+	if action == AuditRestore {
+		return c, nil
+	}
+	for i, r := range c.rows {
+		if c.rows[i].before, err = snapshot(ctx, db, r.entity, r.id); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func cascadeImpact(ctx context.Context, db DBTX, entity, id, action string) (*Impact, error) {
+	if entity == "workload" {
+		return (&workloadRepo{db}).DeleteImpact(ctx, id, 0)
+	}
+	i, err := tierOf(entity)
+	if err != nil {
+		return nil, err
+	}
+	switch action {
+	case AuditRestore:
+		// Only what went to the trash with the row comes back with it.
+		deletionID, err := trashed(ctx, db, tiers[i], id)
+		if err != nil {
+			return nil, err
+		}
+		return subtree(ctx, db, i, id, " AND deletion_id = ?", deletionID)
+	case AuditPurge:
+		return subtreeImpact(ctx, db, i, id, false)
+	}
+	return subtreeImpact(ctx, db, i, id, true)
+}
+
+// Entries returns one entry per cascaded row and link. Each link is
+// recorded against its component and related to its workload, as a
+// link or unlink of its own.
+func (c *Cascade) Entries(ctx context.Context, db DBTX) ([]*AuditEntry, error) {
+	var out []*AuditEntry
+	for _, r := range c.rows {
+		var after any
+		if c.action == AuditRestore {
+			var err error
+			if after, err = snapshot(ctx, db, r.entity, r.id); err != nil {
+				return nil, err
+			}
+		}
+		e := Change(r.entity, r.id, c.action, r.before, after)
+		if r.related != "" {
+			e.Relate("workload", r.related)
+		}
+		out = append(out, e)
+	}
+	for _, l := range c.links {
+		e := Change("component", l.ComponentID, AuditUnlink, l, nil)
+		if c.action == AuditRestore {
+			e = Change("component", l.ComponentID, AuditLink, nil, l)
+		}
+		out = append(out, e.Relate("workload", l.WorkloadID))
+	}
+	return out, nil
+}
+
+// snapshot is Snapshot extended to validations, which are recorded as
+// their columns, as the validations API returns them.
+func snapshot(ctx context.Context, db DBTX, entity, id string) (any, error) {
+	if entity != "workload_validation" {
+		return Snapshot(ctx, db, entity, id)
+	}
+	rows, err := db.QueryContext(ctx, "SELECT * FROM workload_validations WHERE validation_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, ErrNotFound
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	row := make(map[string]any, len(cols))
+	for i, col := range cols {
+		row[col] = values[i]
+	}
+	if s, ok := row["approved_data"].(string); ok {
+		row["approved_data"] = json.RawMessage(s)
+	}
+	return row, rows.Err()
+}
+
+// auditJSON encodes a before or after value; nil, including a nil
+// pointer, is stored as NULL.
+func auditJSON(v any) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	return &s, nil
+}
+
+// changed reports whether two snapshots of a row differ in anything but
+// the bookkeeping a write touches regardless, so a rerun import that
+// changes nothing leaves nothing in the log.
+func changed(before, after any) bool {
+	var rows [2]map[string]any
+	for i, v := range []any{before, after} {
+		b, err := json.Marshal(v)
+		if err != nil || json.Unmarshal(b, &rows[i]) != nil {
+			return true
+		}
+		delete(rows[i], "updated_at")
+		delete(rows[i], "version")
+	}
+	return !reflect.DeepEqual(rows[0], rows[1])
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+func TestAuditHistory(t *testing.T) {
+	db := testDB(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	s := New(db)
+
+	by := "jdoe"
+	entries := []*AuditEntry{
+		Change("component", f.Component, AuditUpdate, map[string]string{"name": "api"}, map[string]string{"name": "gateway"}),
+		Change("component", f.Component, AuditLink, nil, Link{f.Component, f.Workload}).Relate("workload", f.Workload),
+		Change("workload", f.Workload, AuditUpdate, nil, nil),
+		Change("import", "illumio", AuditImport, nil, map[string]int{"created": 1}),
+	}
+	entries[0].Actor = &by
+	for _, e := range entries {
+		must(t, s.Audit.Record(ctx, e))
+		if e.AuditID == 0 {
+			t.Fatal("Record did not set the id")
+		}
+	}
+
+	tests := []struct {
+		name    string
+		list    func(AuditFilter) (*listing.Result[AuditEntry], error)
+		params  url.Values
+		actions string // newest first
+	}{
+		{"all", func(f AuditFilter) (*listing.Result[AuditEntry], error) { return s.Audit.List(ctx, f) }, nil, "[import update link update]"},
+		{"by actor", func(f AuditFilter) (*listing.Result[AuditEntry], error) { return s.Audit.List(ctx, f) }, url.Values{"actor": {"jdoe"}}, "[update]"},
+		{"by action", func(f AuditFilter) (*listing.Result[AuditEntry], error) { return s.Audit.List(ctx, f) }, url.Values{"action": {"link"}}, "[link]"},
+		{"component history", func(fl AuditFilter) (*listing.Result[AuditEntry], error) {
+			return s.Audit.History(ctx, "component", f.Component, fl)
+		}, nil, "[link update]"},
+		// The link names the workload as its related row.
+		{"workload history", func(fl AuditFilter) (*listing.Result[AuditEntry], error) {
+			return s.Audit.History(ctx, "workload", f.Workload, fl)
+		}, nil, "[update link]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := tt.list(AuditFilter{Filter: listing.ParseFilter(tt.params)})
+			must(t, err)
+			var got []string
+			for _, e := range list.Items {
+				got = append(got, e.Action)
+			}
+			if fmt.Sprint(got) != tt.actions {
+				t.Errorf("actions = %v, want %s", got, tt.actions)
+			}
+		})
+	}
+
+	list, err := s.Audit.History(ctx, "component", f.Component, AuditFilter{})
+	must(t, err)
+	var after map[string]string
+	must(t, json.Unmarshal(list.Items[1].After.(json.RawMessage), &after))
+	if after["name"] != "gateway" || list.Items[1].Actor == nil || *list.Items[1].Actor != by {
+		t.Errorf("entry read back as %+v", list.Items[1])
+	}
+	if list.Items[0].Before != nil {
+		t.Errorf("nil before stored as %s", list.Items[0].Before)
+	}
+}
+
+func TestCascadeEntries(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare runs before the cascade is built, e.g. to trash the row.
+		prepare          func(*sql.DB, fixture) error
+		entity, action   string
+		id               func(fixture) string
+		apply            func(*Store, fixture) error
+		entries          string // entity:action, sorted
+		relatedWorkloads int
+	}{
+		{
+			name:    "delete application",
+			entity:  "application",
+			action:  AuditDelete,
+			id:      func(f fixture) string { return f.Application },
+			apply:   func(s *Store, f fixture) error { return s.Applications.Delete(context.Background(), f.Application, 0) },
+			entries: "[component:delete component:unlink]", relatedWorkloads: 1,
+		},
+		{
+			name:    "delete asset",
+			entity:  "asset",
+			action:  AuditDelete,
+			id:      func(f fixture) string { return f.Asset },
+			apply:   func(s *Store, f fixture) error { return s.Assets.Delete(context.Background(), f.Asset, 0) },
+			entries: "[app_grouping:delete application:delete component:delete component:unlink]", relatedWorkloads: 1,
+		},
+		{
+			name: "restore application",
+			prepare: func(db *sql.DB, f fixture) error {
+				return New(db).Applications.Delete(context.Background(), f.Application, 0)
+			},
+			entity: "application",
+			action: AuditRestore,
+			id:     func(f fixture) string { return f.Application },
+			apply: func(s *Store, f fixture) error {
+				_, err := s.Trash.Restore(context.Background(), "application", f.Application)
+				return err
+			},
+			entries: "[component:link component:restore]", relatedWorkloads: 1,
+		},
+		{
+			name: "purge application",
+			prepare: func(db *sql.DB, f fixture) error {
+				return New(db).Applications.Delete(context.Background(), f.Application, 0)
+			},
+			entity: "application",
+			action: AuditPurge,
+			id:     func(f fixture) string { return f.Application },
+			apply: func(s *Store, f fixture) error {
+				return s.Trash.Purge(context.Background(), "application", f.Application)
+			},
+			entries: "[component:purge component:unlink]", relatedWorkloads: 1,
+		},
+		{
+			name: "delete workload",
+			prepare: func(db *sql.DB, f fixture) error {
+				_, err := db.Exec(`INSERT INTO workload_validations (validation_id, workload_id, status, approved_data)
+					VALUES ('v1', ?, 'validated', '{"os":"linux"}')`, f.Workload)
+				return err
+			},
+			entity:  "workload",
+			action:  AuditDelete,
+			id:      func(f fixture) string { return f.Workload },
+			apply:   func(s *Store, f fixture) error { return s.Workloads.Delete(context.Background(), f.Workload, 0) },
+			entries: "[component:unlink workload_validation:delete]", relatedWorkloads: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			f := seed(t, db)
+			ctx := context.Background()
+			s := New(db)
+			if tt.prepare != nil {
+				must(t, tt.prepare(db, f))
+			}
+
+			c, err := NewCascade(ctx, db, tt.entity, tt.id(f), tt.action)
+			must(t, err)
+			must(t, tt.apply(s, f))
+			entries, err := c.Entries(ctx, db)
+			must(t, err)
+
+			var got []string
+			related := 0
+			for _, e := range entries {
+				got = append(got, e.Entity+":"+e.Action)
+				if e.RelatedID != nil && *e.RelatedID == f.Workload {
+					related++
+				}
+				// What goes keeps its last state; what comes back its new one.
+				if tt.action == AuditRestore && (e.After == nil || e.Before != nil) {
+					t.Errorf("%s:%s has before %v, after %v", e.Entity, e.Action, e.Before, e.After)
+				}
+				if tt.action != AuditRestore && (e.Before == nil || e.After != nil) {
+					t.Errorf("%s:%s has before %v, after %v", e.Entity, e.Action, e.Before, e.After)
+				}
+				must(t, s.Audit.Record(ctx, e))
+			}
+			sort.Strings(got)
+			if fmt.Sprint(got) != tt.entries {
+				t.Errorf("entries = %v, want %s", got, tt.entries)
+			}
+			if related != tt.relatedWorkloads {
+				t.Errorf("%d entries name the workload, want %d", related, tt.relatedWorkloads)
+			}
+		})
+	}
+}
+
+func TestUpsertChanges(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	s := New(db)
+
+	rows := []WorkloadUpdate{
+		{Hostname: ptr("h1"), OS: ptr("linux")},
+		{Hostname: ptr("h2"), Interfaces: []Interface{{Address: "10.0.0.2"}}},
+	}
+	res, err := s.Workloads.Upsert(ctx, rows)
+	must(t, err)
+	if len(res.Changes) != 2 || res.Changes[0].Action != AuditCreate || res.Changes[0].After == nil {
+		t.Fatalf("first run: changes %+v, want two creates", res.Changes)
+	}
+
+	// A rerun touches updated_at but changes nothing worth recording.
+	res, err = s.Workloads.Upsert(ctx, rows)
+	must(t, err)
+	if res.Updated != 2 || len(res.Changes) != 0 {
+		t.Errorf("rerun: updated %d with %d changes, want 2 with none", res.Updated, len(res.Changes))
+	}
+
+	res, err = s.Workloads.Upsert(ctx, []WorkloadUpdate{{Hostname: ptr("h1"), OS: ptr("windows")}})
+	must(t, err)
+	if len(res.Changes) != 1 {
+		t.Fatalf("changes = %+v, want one update", res.Changes)
+	}
+	e := res.Changes[0]
+	before, after := e.Before.(*Workload), e.After.(*Workload)
+	if e.Action != AuditUpdate || str(before.OS) != "linux" || str(after.OS) != "windows" {
+		t.Errorf("update %s: os %s → %s, want linux → windows", e.Action, str(before.OS), str(after.OS))
+	}
+}
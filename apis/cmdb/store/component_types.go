@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// ComponentClass is a top-level component category (App Server, Database, ...).
+type ComponentClass struct {
+	ComponentClassID string    `json:"component_class_id"`
+	Name             string    `json:"name"`
+	Label            string    `json:"label"`
+	Color            *string   `json:"color"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ComponentType is a specific implementation within a class, keyed by
+// the ServiceNow sys_class_name (e.g. cmdb_ci_app_server_tomcat).
+type ComponentType struct {
+	ComponentTypeID  string    `json:"component_type_id"`
+	ClassName        string    `json:"class_name"`
+	Label            string    `json:"label"`
+	Color            *string   `json:"color"`
+	ComponentClassID *string   `json:"component_class_id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type ComponentClassFilter struct {
+	Q string
+	listing.Page
+	listing.Filter
+}
+
+type ComponentTypeFilter struct {
+	Q                string
+	ComponentClassID string
+	listing.Page
+	listing.Filter
+}
+
+// ComponentClassRepository is read-only; classes are seeded by migrations.
+type ComponentClassRepository interface {
+	List(ctx context.Context, f ComponentClassFilter) (*listing.Result[ComponentClass], error)
+	Get(ctx context.Context, id string) (*ComponentClass, error)
+}
+
+// ComponentTypeRepository is read-only; types are seeded by migrations.
+type ComponentTypeRepository interface {
+	List(ctx context.Context, f ComponentTypeFilter) (*listing.Result[ComponentType], error)
+	Get(ctx context.Context, id string) (*ComponentType, error)
+}
+
+var componentClasses = table[ComponentClass]{
+	name:    "component_classes",
+	pk:      "component_class_id",
+	columns: "component_class_id, name, label, color, created_at, updated_at",
+	order:   []listing.Order{{Column: "label"}},
+	scan: func(s scanner) (*ComponentClass, error) {
+		var c ComponentClass
+		err := s.Scan(&c.ComponentClassID, &c.Name, &c.Label, &c.Color, ts(&c.CreatedAt), ts(&c.UpdatedAt))
+		return &c, err
+	},
+}
+
+var componentTypes = table[ComponentType]{
+	name:    "component_types",
+	pk:      "component_type_id",
+	columns: "component_type_id, class_name, label, color, component_class_id, created_at, updated_at",
+	order:   []listing.Order{{Column: "label"}},
+	scan: func(s scanner) (*ComponentType, error) {
+		var t ComponentType
+		err := s.Scan(&t.ComponentTypeID, &t.ClassName, &t.Label, &t.Color, &t.ComponentClassID,
+			ts(&t.CreatedAt), ts(&t.UpdatedAt))
+		return &t, err
+	},
+}
+
+type componentClassRepo struct{ db DBTX }
+
+func (r *componentClassRepo) List(ctx context.Context, f ComponentClassFilter) (*listing.Result[ComponentClass], error) {
+	var w where
+	w.like("label", f.Q)
+	return componentClasses.list(ctx, r.db, w, f.Page, f.Filter)
+}
+
+func (r *componentClassRepo) Get(ctx context.Context, id string) (*ComponentClass, error) {
+	return componentClasses.get(ctx, r.db, id)
+}
+
+type componentTypeRepo struct{ db DBTX }
+
+func (r *componentTypeRepo) List(ctx context.Context, f ComponentTypeFilter) (*listing.Result[ComponentType], error) {
+	var w where
+	w.like("label", f.Q)
+	w.eq("component_class_id", f.ComponentClassID)
+	return componentTypes.list(ctx, r.db, w, f.Page, f.Filter)
+}
+
+func (r *componentTypeRepo) Get(ctx context.Context, id string) (*ComponentType, error) {
+	return componentTypes.get(ctx, r.db, id)
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// Component is tier 5 of the BIA hierarchy: a deployed piece of an
+// application (an app server, a database, ...) that runs on workloads.
+type Component struct {
+	ComponentID      string    `json:"component_id"`
+	Name             *string   `json:"name"`
+	ApplicationID    string    `json:"application_id"`
+	ComponentClassID *string   `json:"component_class_id"`
+	ComponentTypeID  *string   `json:"component_type_id"`
+	SnowSysID        *string   `json:"snow_sys_id"`
+	Description      *string   `json:"description"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Version          int       `json:"version"`
+}
+
+// ComponentUpdate holds the fields to change; nil fields are left as is.
+type ComponentUpdate struct {
+	Name             *string `json:"name"`
+	ApplicationID    *string `json:"application_id"`
+	ComponentClassID *string `json:"component_class_id"`
+	ComponentTypeID  *string `json:"component_type_id"`
+	SnowSysID        *string `json:"snow_sys_id"`
+	Description      *string `json:"description"`
+}
+
+type ComponentFilter struct {
+	Q                string
+	ComponentTypeID  string
+	ComponentClassID string
+	listing.Page
+	listing.Filter
+}
+
+type ComponentRepository interface {
+	List(ctx context.Context, f ComponentFilter) (*listing.Result[Component], error)
+	Get(ctx context.Context, id string) (*Component, error)
+	Create(ctx context.Context, c *Component) error
+	Update(ctx context.Context, id string, version int, u ComponentUpdate) (*Component, error)
+	Patch(ctx context.Context, id string, version int, p Patch) (*Component, error)
+	Delete(ctx context.Context, id string, version int) error
+	DeleteImpact(ctx context.Context, id string, version int) (*Impact, error)
+	// ReparentImpact previews moving the component to applicationID.
+	ReparentImpact(ctx context.Context, id string, version int, applicationID *string) (*ReparentImpact, error)
+}
+
+var components = table[Component]{
+	name: "components",
+	pk:   "component_id",
+	columns: "component_id, name, application_id, component_class_id, component_type_id, " +
+		"snow_sys_id, description, created_at, updated_at, version",
+	order: []listing.Order{{Column: "created_at"}},
+	scan: func(s scanner) (*Component, error) {
+		var c Component
+		err := s.Scan(&c.ComponentID, &c.Name, &c.ApplicationID, &c.ComponentClassID, &c.ComponentTypeID,
+			&c.SnowSysID, &c.Description, ts(&c.CreatedAt), ts(&c.UpdatedAt), &c.Version)
+		return &c, err
+	},
+}
+
+type componentRepo struct{ db DBTX }
+
+func (r *componentRepo) List(ctx context.Context, f ComponentFilter) (*listing.Result[Component], error) {
+	var w where
+	w.like("name", f.Q)
+	w.eq("component_type_id", f.ComponentTypeID)
+	w.eq("component_class_id", f.ComponentClassID)
+	return components.list(ctx, r.db, w, f.Page, f.Filter)
+}
+
+func (r *componentRepo) Get(ctx context.Context, id string) (*Component, error) {
+	return components.get(ctx, r.db, id)
+}
+
+func (r *componentRepo) Create(ctx context.Context, c *Component) error {
+	if c.ComponentID == "" {
+		c.ComponentID = newID()
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO components (component_id, name, application_id, component_class_id, component_type_id, snow_sys_id, description)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		c.ComponentID, c.Name, c.ApplicationID, c.ComponentClassID, c.ComponentTypeID, c.SnowSysID, c.Description)
+	if err != nil {
+		return components.explain(ctx, r.db, err, "", fields(c))
+	}
+	saved, err := r.Get(ctx, c.ComponentID)
+	if err != nil {
+		return err
+	}
+	*c = *saved
+	return nil
+}
+
+func (r *componentRepo) Update(ctx context.Context, id string, version int, u ComponentUpdate) (*Component, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE components SET
+			name=COALESCE(?,name), application_id=COALESCE(?,application_id),
+			component_class_id=COALESCE(?,component_class_id), component_type_id=COALESCE(?,component_type_id),
+			snow_sys_id=COALESCE(?,snow_sys_id), description=COALESCE(?,description),
+			updated_at=datetime('now')
+		 WHERE component_id=? AND (?=0 OR version=?) AND deleted_at IS NULL`,
+		u.Name, u.ApplicationID, u.ComponentClassID, u.ComponentTypeID, u.SnowSysID, u.Description, id, version, version)
+	if err != nil {
+		return nil, components.explain(ctx, r.db, err, id, fields(u))
+	}
+	if err := components.matched(ctx, r.db, res, id); err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+// Patch applies a JSON Merge Patch; null clears a nullable column.
+func (r *componentRepo) Patch(ctx context.Context, id string, version int, p Patch) (*Component, error) {
+	sets, args, err := assignments[Component, ComponentUpdate](p)
+	if err != nil {
+		return nil, err
+	}
+	return components.patch(ctx, r.db, id, version, sets, args)
+}
+
+func (r *componentRepo) Delete(ctx context.Context, id string, version int) error {
+	return components.delete(ctx, r.db, id, version)
+}
+
+func (r *componentRepo) DeleteImpact(ctx context.Context, id string, version int) (*Impact, error) {
+	return components.deleteImpact(ctx, r.db, id, version)
+}
+
+func (r *componentRepo) ReparentImpact(ctx context.Context, id string, version int, applicationID *string) (*ReparentImpact, error) {
+	return reparentImpact(ctx, r.db, id, version, applicationID)
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Constraint is the kind of database constraint a write violated.
+type Constraint string
+
+const (
+	ConstraintUnique     Constraint = "unique"
+	ConstraintForeignKey Constraint = "foreign_key"
+	ConstraintNotNull    Constraint = "not_null"
+	ConstraintCheck      Constraint = "check"
+)
+
+// ConstraintError is a write the database rejected. Fields names the
+// offending columns where SQLite (or a follow-up query) can tell, and
+// ExistingID the row already holding a unique value.
+type ConstraintError struct {
+	Constraint Constraint
+	Table      string
+	Fields     []string
+	ExistingID string
+}
+
+func (e *ConstraintError) Error() string {
+	fields := strings.Join(e.Fields, ", ")
+	switch e.Constraint {
+	case ConstraintUnique:
+		if fields == "" {
+			return "duplicate value"
+		}
+		return fmt.Sprintf("%s already has a row with this %s", e.Table, strings.Join(e.Fields, " and "))
+	case ConstraintForeignKey:
+		if fields == "" {
+			return "a referenced row does not exist"
+		}
+		return fmt.Sprintf("%s does not reference an existing row", fields)
+	case ConstraintNotNull:
+		return fields + " is required"
+	default:
+		if fields == "" {
+			return "value fails a check constraint"
+		}
+		return fmt.Sprintf("invalid %s", fields)
+	}
+}
+
+// constraintDetail picks the columns out of SQLite's message, e.g.
+// "UNIQUE constraint failed: assets.name, assets.portfolio_id".
+var constraintDetail = regexp.MustCompile(`(?:UNIQUE|NOT NULL|CHECK) constraint failed: ([^(]+)`)
+
+// Classify turns a SQLite constraint failure into a *ConstraintError,
+// using what the error message says. Other errors are returned as is.
+func Classify(err error) error {
+	var se *sqlite.Error
+	if !errors.As(err, &se) || se.Code()&0xff != sqlite3.SQLITE_CONSTRAINT {
+		return err
+	}
+	ce := &ConstraintError{}
+	switch se.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		ce.Constraint = ConstraintUnique
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		ce.Constraint = ConstraintForeignKey
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		ce.Constraint = ConstraintNotNull
+	case sqlite3.SQLITE_CONSTRAINT_CHECK:
+		ce.Constraint = ConstraintCheck
+	case sqlite3.SQLITE_CONSTRAINT_TRIGGER:
+		// The soft delete triggers refuse live rows under trashed parents.
+		if !strings.Contains(se.Error(), "FOREIGN KEY constraint failed") {
+			return err
+		}
+		ce.Constraint = ConstraintForeignKey
+	default:
+		return err
+	}
+	if m := constraintDetail.FindStringSubmatch(se.Error()); m != nil {
+		for _, col := range strings.Split(strings.TrimSpace(m[1]), ", ") {
+			table, field, ok := strings.Cut(col, ".")
+			if !ok {
+				// A named CHECK constraint; report the name.
+				field = col
+			} else {
+				ce.Table = table
+			}
+			ce.Fields = append(ce.Fields, field)
+		}
+	}
+	return ce
+}
+
+// explain classifies a failed write to t and fills in what the message
+// leaves out: the row holding a duplicate value, or which foreign key
+// points nowhere. changes are the column values written; for an update
+// (id set) they are laid over the row's current values.
+func (t table[T]) explain(ctx context.Context, db DBTX, err error, id string, changes map[string]any) error {
+	ce, ok := Classify(err).(*ConstraintError)
+	if !ok {
+		return err
+	}
+	if ce.Table == "" {
+		ce.Table = t.name
+	}
+	values := changes
+	if id != "" {
+		if row, err := t.get(ctx, db, id); err == nil {
+			values = fields(row)
+			for k, v := range changes {
+				values[k] = v
+			}
+		}
+	}
+
+	switch ce.Constraint {
+	case ConstraintUnique:
+		if ce.Table != t.name || len(ce.Fields) == 0 {
+			break
+		}
+		conds := make([]string, len(ce.Fields))
+		args := make([]any, len(ce.Fields))
+		for i, f := range ce.Fields {
+			conds[i] = f + " = ?"
+			args[i] = values[f]
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", t.pk, t.name, strings.Join(conds, " AND "))
+		if id != "" {
+			query += fmt.Sprintf(" AND %s <> ?", t.pk)
+			args = append(args, id)
+		}
+		db.QueryRowContext(ctx, query, args...).Scan(&ce.ExistingID)
+
+	case ConstraintForeignKey:
+		rows, err := db.QueryContext(ctx, `SELECT "from", "table", "to" FROM pragma_foreign_key_list(?)`, t.name)
+		if err != nil {
+			break
+		}
+		type fk struct{ from, table, to string }
+		var fks []fk
+		for rows.Next() {
+			var k fk
+			if rows.Scan(&k.from, &k.table, &k.to) == nil {
+				fks = append(fks, k)
+			}
+		}
+		rows.Close()
+		for _, k := range fks {
+			v, ok := values[k.from]
+			if !ok || v == nil {
+				continue
+			}
+			live := ""
+			if tierIndex(k.table) >= 0 {
+				live = " AND deleted_at IS NULL"
+			}
+			var one int
+			err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT 1 FROM %s WHERE %s = ?%s", k.table, k.to, live), v).Scan(&one)
+			if err != nil {
+				ce.Fields = []string{k.from}
+				break
+			}
+		}
+	}
+	return ce
+}
+
+// fields returns the set fields of a model or update struct (or a pointer
+// to one) by json name, as driver values. Nil pointers and slices are
+// left out.
+func fields(v any) map[string]any {
+	out := map[string]any{}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rv.Field(i)
+		switch f.Kind() {
+		case reflect.Slice:
+			continue
+		case reflect.Pointer:
+			if f.IsNil() {
+				continue
+			}
+			f = f.Elem()
+		}
+		switch x := f.Interface().(type) {
+		case Bool:
+			out[jsonName(rt.Field(i))] = boolArg(&x)
+		case bool:
+			b := Bool(x)
+			out[jsonName(rt.Field(i))] = boolArg(&b)
+		default:
+			out[jsonName(rt.Field(i))] = x
+		}
+	}
+	return out
+}
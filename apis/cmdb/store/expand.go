@@ -0,0 +1,311 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ExpandError is returned for an ?expand path that names no relation.
+type ExpandError struct{ msg string }
+
+func (e *ExpandError) Error() string { return e.msg }
+
+// relation is a to-one link followed through a foreign key.
+type relation struct {
+	fk    string
+	table string
+}
+
+// relations lists, per table, the links ?expand can follow.
+var relations = map[string]map[string]relation{
+	"assets": {
+		"portfolio": {fk: "portfolio_id", table: "portfolios"},
+	},
+	"app_groupings": {
+		"asset": {fk: "asset_id", table: "assets"},
+	},
+	"applications": {
+		"app_grouping": {fk: "app_grouping_id", table: "app_groupings"},
+	},
+	"components": {
+		"application":     {fk: "application_id", table: "applications"},
+		"component_type":  {fk: "component_type_id", table: "component_types"},
+		"component_class": {fk: "component_class_id", table: "component_classes"},
+	},
+	"component_types": {
+		"component_class": {fk: "component_class_id", table: "component_classes"},
+	},
+}
+
+// meta is the untyped view of a table used when following relations.
+type meta struct {
+	name    string
+	pk      string
+	columns string
+	scan    func(scanner) (any, error)
+	vocab   map[string]string
+}
+
+func (t table[T]) meta() meta {
+	return meta{
+		name:    t.name,
+		pk:      t.pk,
+		columns: t.columns,
+		scan:    func(s scanner) (any, error) { return t.scan(s) },
+		vocab:   t.vocab,
+	}
+}
+
+var (
+	tablesByName = map[string]meta{}
+	tablesByType = map[reflect.Type]meta{}
+)
+
+func init() {
+	register(portfolios)
+	register(assets)
+	register(appGroupings)
+	register(applications)
+	register(components)
+	register(componentTypes)
+	register(componentClasses)
+	register(workloads)
+}
+
+func register[T any](t table[T]) {
+	m := t.meta()
+	tablesByName[m.name] = m
+	tablesByType[reflect.TypeFor[T]()] = m
+}
+
+// Expanded is a row with its expanded relations embedded as extra fields;
+// a relation whose foreign key is unset encodes as null.
+type Expanded struct {
+	Row     any
+	Related map[string]*Expanded
+}
+
+func (e *Expanded) MarshalJSON() ([]byte, error) {
+	row, err := json.Marshal(e.Row)
+	if err != nil || len(e.Related) == 0 {
+		return row, err
+	}
+	rel, err := json.Marshal(e.Related)
+	if err != nil {
+		return nil, err
+	}
+	// Splice the relation fields into the row's object.
+	var buf bytes.Buffer
+	buf.Write(row[:len(row)-1])
+	buf.WriteByte(',')
+	buf.Write(rel[1:])
+	return buf.Bytes(), nil
+}
+
+// expandNode is one followed relation; alias is its table's alias in the
+// joined query.
+type expandNode struct {
+	name     string
+	rel      relation
+	alias    string
+	children []*expandNode
+}
+
+// parseExpand turns "application.app_grouping,component_type" into a
+// relation tree rooted at table.
+func parseExpand(table, expand string) ([]*expandNode, error) {
+	var roots []*expandNode
+	n := 0
+	for _, path := range strings.Split(expand, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		from, level := table, &roots
+		for _, name := range strings.Split(path, ".") {
+			rel, ok := relations[from][name]
+			if !ok {
+				return nil, &ExpandError{fmt.Sprintf("cannot expand %q on %s", name, from)}
+			}
+			var node *expandNode
+			for _, existing := range *level {
+				if existing.name == name {
+					node = existing
+				}
+			}
+			if node == nil {
+				n++
+				node = &expandNode{name: name, rel: rel, alias: fmt.Sprintf("e%d", n)}
+				*level = append(*level, node)
+			}
+			from, level = rel.table, &node.children
+		}
+	}
+	return roots, nil
+}
+
+// Expand embeds the relations named by expand into each row, loading all
+// of them for every row in one joined query.
+func Expand[T any](ctx context.Context, db DBTX, rows []T, expand string) ([]*Expanded, error) {
+	base, ok := tablesByType[reflect.TypeFor[T]()]
+	if !ok {
+		return nil, &ExpandError{fmt.Sprintf("cannot expand %T rows", *new(T))}
+	}
+	roots, err := parseExpand(base.name, expand)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*Expanded, len(rows))
+	byID := map[string]*Expanded{}
+	ids := make([]any, len(rows))
+	for i, r := range rows {
+		out[i] = &Expanded{Row: r, Related: map[string]*Expanded{}}
+		id, _ := columnValue(r, base.pk).(string)
+		byID[id] = out[i]
+		ids[i] = id
+	}
+	if len(roots) == 0 || len(rows) == 0 {
+		return out, nil
+	}
+
+	cols := []string{"e0." + base.pk}
+	var joins []string
+	var walk func(parent string, nodes []*expandNode)
+	walk = func(parent string, nodes []*expandNode) {
+		for _, n := range nodes {
+			target := tablesByName[n.rel.table]
+			joins = append(joins, fmt.Sprintf("LEFT JOIN %s %s ON %s.%s = %s.%s",
+				target.name, n.alias, n.alias, target.pk, parent, n.rel.fk))
+			cols = append(cols, n.alias+"."+target.pk+" IS NOT NULL", prefixed(n.alias, target.columns))
+			walk(n.alias, n.children)
+		}
+	}
+	walk("e0", roots)
+
+	query := fmt.Sprintf("SELECT %s FROM %s e0 %s WHERE e0.%s IN (%s)",
+		strings.Join(cols, ", "), base.name, strings.Join(joins, " "), base.pk,
+		strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "))
+	res, err := db.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var id string
+		var dest collector
+		dest.add(&id)
+		fill := plan(&dest, roots)
+		if err := res.Scan(dest.dest...); err != nil {
+			return nil, err
+		}
+		if e := byID[id]; e != nil {
+			fill(e)
+		}
+	}
+	return out, res.Err()
+}
+
+// plan registers the scan targets for nodes and returns the function that
+// attaches the scanned rows to their parent once the row has been read.
+func plan(dest *collector, nodes []*expandNode) func(*Expanded) {
+	type part struct {
+		name     string
+		present  *bool
+		row      any
+		children func(*Expanded)
+	}
+	parts := make([]part, len(nodes))
+	for i, n := range nodes {
+		p := &parts[i]
+		p.name = n.name
+		p.present = new(bool)
+		dest.add(p.present)
+		p.row, _ = tablesByName[n.rel.table].scan(dest)
+		p.children = plan(dest, n.children)
+	}
+	return func(parent *Expanded) {
+		for _, p := range parts {
+			if !*p.present {
+				parent.Related[p.name] = nil
+				continue
+			}
+			e := &Expanded{Row: reflect.ValueOf(p.row).Elem().Interface(), Related: map[string]*Expanded{}}
+			p.children(e)
+			parent.Related[p.name] = e
+		}
+	}
+}
+
+// collector is a scanner that records the destinations a table's scan
+// function asks for, so several tables can be read from one joined row.
+// Every destination tolerates NULL, which a missing relation yields for
+// all of its columns.
+type collector struct{ dest []any }
+
+func (c *collector) Scan(dest ...any) error {
+	for _, d := range dest {
+		c.add(d)
+	}
+	return nil
+}
+
+func (c *collector) add(d any) { c.dest = append(c.dest, nullable{d}) }
+
+type nullable struct{ dest any }
+
+func (n nullable) Scan(v any) error {
+	if v == nil {
+		return nil
+	}
+	switch d := n.dest.(type) {
+	case sql.Scanner:
+		return d.Scan(v)
+	case *string:
+		var s sql.NullString
+		err := s.Scan(v)
+		*d = s.String
+		return err
+	case **string:
+		var s sql.NullString
+		err := s.Scan(v)
+		*d = &s.String
+		return err
+	case *bool:
+		var b sql.NullBool
+		err := b.Scan(v)
+		*d = b.Bool
+		return err
+	case *int:
+		var i sql.NullInt64
+		err := i.Scan(v)
+		*d = int(i.Int64)
+		return err
+	case *int64:
+		var i sql.NullInt64
+		err := i.Scan(v)
+		*d = i.Int64
+		return err
+	}
+	return fmt.Errorf("expand: unsupported scan target %T", n.dest)
+}
+
+// Relations lists the ?expand names available from each table, for the
+// schema endpoint.
+func Relations() map[string][]string {
+	out := map[string][]string{}
+	for table, rels := range relations {
+		for name := range rels {
+			out[table] = append(out[table], name)
+		}
+		sort.Strings(out[table])
+	}
+	return out
+}
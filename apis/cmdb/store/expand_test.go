@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// expandPaths lists every ?expand path reachable from table, nested ones
+// included, so a column added to any table is scanned through a join.
+func expandPaths(table string) []string {
+	var out []string
+	for name, rel := range relations[table] {
+		out = append(out, name)
+		for _, sub := range expandPaths(rel.table) {
+			out = append(out, name+"."+sub)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func expandList[T any](t table[T]) func(context.Context, DBTX, string) ([]*Expanded, error) {
+	return func(ctx context.Context, db DBTX, expand string) ([]*Expanded, error) {
+		rows, err := t.list(ctx, db, where{}, listing.Page{}, listing.Filter{})
+		if err != nil {
+			return nil, err
+		}
+		return Expand(ctx, db, rows.Items, expand)
+	}
+}
+
+func TestExpandEveryRelation(t *testing.T) {
+	db := testDB(t)
+	seed(t, db)
+	ctx := context.Background()
+
+	expanders := map[string]func(context.Context, DBTX, string) ([]*Expanded, error){
+		"assets":          expandList(assets),
+		"app_groupings":   expandList(appGroupings),
+		"applications":    expandList(applications),
+		"components":      expandList(components),
+		"component_types": expandList(componentTypes),
+	}
+	for table := range relations {
+		expand, ok := expanders[table]
+		if !ok {
+			t.Errorf("%s has relations but no expander in this test", table)
+			continue
+		}
+		for _, path := range expandPaths(table) {
+			t.Run(table+"/"+path, func(t *testing.T) {
+				out, err := expand(ctx, db, path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(out) == 0 {
+					t.Fatal("no rows")
+				}
+				// The seeded rows have every relation set; component
+				// types without a class are skipped.
+				for _, e := range out {
+					if missing := missingRelation(e, strings.Split(path, ".")); missing != "" {
+						if table == "component_types" {
+							continue
+						}
+						t.Errorf("%s: %s not expanded", path, missing)
+					}
+				}
+			})
+		}
+	}
+}
+
+// missingRelation follows names through e's related rows and returns the
+// first one that is absent.
+func missingRelation(e *Expanded, names []string) string {
+	for _, name := range names {
+		next := e.Related[name]
+		if next == nil {
+			return name
+		}
+		e = next
+	}
+	return ""
+}
+
+func TestExpandUnknownRelation(t *testing.T) {
+	db := testDB(t)
+	seed(t, db)
+	_, err := expandList(components)(context.Background(), db, "application.nope")
+	if _, ok := err.(*ExpandError); !ok {
+		t.Fatalf("err = %v, want *ExpandError", err)
+	}
+}
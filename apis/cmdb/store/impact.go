@@ -0,0 +1,258 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Impact is what a delete would remove, as previewed by ?dry_run=true.
+type Impact struct {
+	// Deleted lists, per table, the ids of the rows that would go,
+	// starting with the target itself.
+	Deleted map[string][]string `json:"deleted"`
+	// Unlinked lists the component–workload links that would go.
+	Unlinked []Link `json:"unlinked"`
+	// Orphaned lists the workloads that would be left linked to no
+	// component at all.
+	Orphaned []string `json:"orphaned_workloads"`
+	// Counts holds the size of each of the above, keyed by table name,
+	// "unlinked" and "orphaned_workloads".
+	Counts map[string]int `json:"counts"`
+	// Restorable is true when the rows go to the trash rather than being
+	// deleted outright.
+	Restorable bool `json:"restorable"`
+}
+
+// Link is one component_workloads row.
+type Link struct {
+	ComponentID string `json:"component_id"`
+	WorkloadID  string `json:"workload_id"`
+}
+
+func newImpact() *Impact {
+	return &Impact{
+		Deleted:  map[string][]string{},
+		Unlinked: []Link{},
+		Orphaned: []string{},
+		Counts:   map[string]int{},
+	}
+}
+
+// count fills in Counts from the lists.
+func (im *Impact) count() *Impact {
+	for table, ids := range im.Deleted {
+		im.Counts[table] = len(ids)
+	}
+	im.Counts["unlinked"] = len(im.Unlinked)
+	im.Counts["orphaned_workloads"] = len(im.Orphaned)
+	return im
+}
+
+// in returns an IN list of placeholders for ids, with ids as arguments.
+func in(ids []string) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")", args
+}
+
+func queryIDs(ctx context.Context, db DBTX, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// deleteImpact previews t.delete: the row must exist at version (0 for
+// any), as for the delete itself.
+func (t table[T]) deleteImpact(ctx context.Context, db DBTX, id string, version int) (*Impact, error) {
+	row, err := t.get(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	if v, _ := VersionOf(row); version != 0 && v != version {
+		return nil, ErrVersionMismatch
+	}
+	i := tierIndex(t.name)
+	if i < 0 {
+		im := newImpact()
+		im.Deleted[t.name] = []string{id}
+		return im, nil
+	}
+	im, err := subtreeImpact(ctx, db, i, id, true)
+	if err != nil {
+		return nil, err
+	}
+	im.Restorable = true
+	return im, nil
+}
+
+// subtreeImpact collects the row of tier i and everything beneath it —
+// only the rows outside the trash when live is set, as a delete moves
+// them, or all of them, as a purge removes them — with their links.
+func subtreeImpact(ctx context.Context, db DBTX, i int, id string, live bool) (*Impact, error) {
+	if live {
+		return subtree(ctx, db, i, id, " AND deleted_at IS NULL")
+	}
+	return subtree(ctx, db, i, id, "")
+}
+
+// subtree is subtreeImpact for the rows beneath the target that match
+// cond, an extra WHERE clause with its args.
+func subtree(ctx context.Context, db DBTX, i int, id string, cond string, condArgs ...any) (*Impact, error) {
+	im := newImpact()
+	ids := []string{id}
+	im.Deleted[tiers[i].table] = ids
+	for j := i + 1; j < len(tiers) && len(ids) > 0; j++ {
+		list, args := in(ids)
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IN %s%s", tiers[j].pk, tiers[j].table, tiers[j].parent, list, cond)
+		var err error
+		if ids, err = queryIDs(ctx, db, query+" ORDER BY 1", append(args, condArgs...)...); err != nil {
+			return nil, err
+		}
+		im.Deleted[tiers[j].table] = ids
+	}
+
+	if components := im.Deleted["components"]; len(components) > 0 {
+		list, args := in(components)
+		rows, err := db.QueryContext(ctx,
+			"SELECT component_id, workload_id FROM component_workloads WHERE component_id IN "+list+" ORDER BY 1, 2", args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var l Link
+			if err := rows.Scan(&l.ComponentID, &l.WorkloadID); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			im.Unlinked = append(im.Unlinked, l)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		// Workloads linked now through a live component in the set and
+		// through no live component outside it.
+		im.Orphaned, err = queryIDs(ctx, db,
+			`SELECT DISTINCT cw.workload_id FROM component_workloads cw
+			 JOIN components c ON c.component_id = cw.component_id AND c.deleted_at IS NULL
+			 WHERE cw.component_id IN `+list+`
+			   AND NOT EXISTS (SELECT 1 FROM component_workloads o
+			                   JOIN components oc ON oc.component_id = o.component_id AND oc.deleted_at IS NULL
+			                   WHERE o.workload_id = cw.workload_id AND o.component_id NOT IN `+list+`)
+			 ORDER BY 1`, append(args, args...)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return im.count(), nil
+}
+
+// ReparentImpact is what moving a component to another application would
+// do, as previewed by ?dry_run=true. Its workload links move with it.
+type ReparentImpact struct {
+	ComponentID       string `json:"component_id"`
+	FromApplicationID string `json:"from_application_id"`
+	ToApplicationID   string `json:"to_application_id"`
+	// Workloads are the workloads linked to the component.
+	Workloads []string `json:"workloads"`
+	// Departed lists each node of the old hierarchy the component leaves,
+	// with the workloads that would no longer roll up under it.
+	Departed []Departure `json:"departed"`
+}
+
+// Departure is one hierarchy node losing workloads to a re-parent.
+type Departure struct {
+	Type      string   `json:"type"`
+	ID        string   `json:"id"`
+	Count     int      `json:"count"`
+	Workloads []string `json:"workloads"`
+}
+
+// ancestry is the application and the nodes above it, by Scope.
+func ancestry(ctx context.Context, db DBTX, applicationID string) (map[Scope]string, error) {
+	var app, group, asset, portfolio string
+	err := db.QueryRowContext(ctx,
+		`SELECT a.application_id, ag.app_grouping_id, ast.asset_id, ast.portfolio_id
+		 FROM applications a
+		 JOIN app_groupings ag ON ag.app_grouping_id = a.app_grouping_id
+		 JOIN assets ast ON ast.asset_id = ag.asset_id
+		 WHERE a.application_id = ? AND a.deleted_at IS NULL`, applicationID).Scan(&app, &group, &asset, &portfolio)
+	if err != nil {
+		return nil, err
+	}
+	return map[Scope]string{
+		ScopeApplication: app,
+		ScopeAppGrouping: group,
+		ScopeAsset:       asset,
+		ScopePortfolio:   portfolio,
+	}, nil
+}
+
+// reparentImpact previews moving component id (at version, 0 for any) to
+// applicationID; nil or the current application changes nothing.
+func reparentImpact(ctx context.Context, db DBTX, id string, version int, applicationID *string) (*ReparentImpact, error) {
+	comp, err := components.get(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && comp.Version != version {
+		return nil, ErrVersionMismatch
+	}
+	im := &ReparentImpact{
+		ComponentID:       id,
+		FromApplicationID: comp.ApplicationID,
+		ToApplicationID:   comp.ApplicationID,
+		Departed:          []Departure{},
+	}
+	if im.Workloads, err = queryIDs(ctx, db,
+		"SELECT workload_id FROM component_workloads WHERE component_id = ? ORDER BY 1", id); err != nil {
+		return nil, err
+	}
+	if applicationID == nil || *applicationID == comp.ApplicationID {
+		return im, nil
+	}
+	im.ToApplicationID = *applicationID
+
+	from, err := ancestry(ctx, db, comp.ApplicationID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := ancestry(ctx, db, *applicationID)
+	if err != nil {
+		return nil, &ConstraintError{Constraint: ConstraintForeignKey, Table: "components", Fields: []string{"application_id"}}
+	}
+	for _, scope := range []Scope{ScopeApplication, ScopeAppGrouping, ScopeAsset, ScopePortfolio} {
+		if from[scope] == to[scope] {
+			break
+		}
+		s := scopes[scope]
+		workloads, err := queryIDs(ctx, db,
+			`SELECT x.workload_id FROM component_workloads x
+			 WHERE x.component_id = ?
+			   AND NOT EXISTS (SELECT 1 FROM component_workloads cw
+			                   JOIN components c ON c.component_id = cw.component_id`+s.joins+`
+			                   WHERE cw.workload_id = x.workload_id AND c.component_id <> x.component_id
+			                     AND c.deleted_at IS NULL AND `+s.column+` = ?)
+			 ORDER BY 1`, id, from[scope])
+		if err != nil {
+			return nil, err
+		}
+		im.Departed = append(im.Departed, Departure{Type: string(scope), ID: from[scope], Count: len(workloads), Workloads: workloads})
+	}
+	return im, nil
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// impactFixture adds to the seeded branch a sibling application whose
+// component shares the workload, and a second portfolio to move into.
+type impactFixture struct {
+	fixture
+	SiblingApp, SiblingComponent string
+	OtherApp                     string
+}
+
+func seedImpact(t *testing.T, db *sql.DB) impactFixture {
+	t.Helper()
+	ctx := context.Background()
+	s := New(db)
+	f := seed(t, db)
+
+	app := &Application{Name: "ledger-batch", AppGroupingID: f.AppGrouping}
+	must(t, s.Applications.Create(ctx, app))
+	c := &Component{Name: ptr("batch"), ApplicationID: app.ApplicationID}
+	must(t, s.Components.Create(ctx, c))
+	_, err := db.ExecContext(ctx, "INSERT INTO component_workloads (component_id, workload_id) VALUES (?, ?)", c.ComponentID, f.Workload)
+	must(t, err)
+
+	p := &Portfolio{Name: "Lending"}
+	must(t, s.Portfolios.Create(ctx, p))
+	a := &Asset{Name: "Loans", PortfolioID: p.PortfolioID}
+	must(t, s.Assets.Create(ctx, a))
+	g := &AppGrouping{Name: "Origination", AssetID: a.AssetID}
+	must(t, s.AppGroupings.Create(ctx, g))
+	other := &Application{Name: "loans-api", AppGroupingID: g.AppGroupingID}
+	must(t, s.Applications.Create(ctx, other))
+
+	return impactFixture{fixture: f, SiblingApp: app.ApplicationID, SiblingComponent: c.ComponentID, OtherApp: other.ApplicationID}
+}
+
+func TestDeleteImpact(t *testing.T) {
+	tests := []struct {
+		name     string
+		preview  func(*Store, impactFixture) (*Impact, error)
+		counts   map[string]int
+		orphaned bool
+	}{
+		{
+			name: "component",
+			preview: func(s *Store, f impactFixture) (*Impact, error) {
+				return s.Components.DeleteImpact(context.Background(), f.Component, 0)
+			},
+			counts: map[string]int{"components": 1, "unlinked": 1, "orphaned_workloads": 0},
+		},
+		{
+			name: "application",
+			preview: func(s *Store, f impactFixture) (*Impact, error) {
+				return s.Applications.DeleteImpact(context.Background(), f.Application, 0)
+			},
+			counts: map[string]int{"applications": 1, "components": 1, "unlinked": 1, "orphaned_workloads": 0},
+		},
+		{
+			name: "app grouping",
+			preview: func(s *Store, f impactFixture) (*Impact, error) {
+				return s.AppGroupings.DeleteImpact(context.Background(), f.AppGrouping, 0)
+			},
+			counts:   map[string]int{"app_groupings": 1, "applications": 2, "components": 2, "unlinked": 2, "orphaned_workloads": 1},
+			orphaned: true,
+		},
+		{
+			name: "portfolio",
+			preview: func(s *Store, f impactFixture) (*Impact, error) {
+				return s.Portfolios.DeleteImpact(context.Background(), f.Portfolio, 0)
+			},
+			counts:   map[string]int{"portfolios": 1, "assets": 1, "app_groupings": 1, "applications": 2, "components": 2, "unlinked": 2, "orphaned_workloads": 1},
+			orphaned: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			f := seedImpact(t, db)
+			s := New(db)
+
+			im, err := tt.preview(s, f)
+			must(t, err)
+			for key, want := range tt.counts {
+				if im.Counts[key] != want {
+					t.Errorf("counts[%s] = %d, want %d", key, im.Counts[key], want)
+				}
+			}
+			if !im.Restorable {
+				t.Error("hierarchy delete not restorable")
+			}
+			if tt.orphaned && (len(im.Orphaned) != 1 || im.Orphaned[0] != f.Workload) {
+				t.Errorf("orphaned = %v, want the workload", im.Orphaned)
+			}
+			if n := trashedRows(t, db); n != 0 {
+				t.Errorf("preview trashed %d rows", n)
+			}
+		})
+	}
+}
+
+// TestDeleteImpactMatchesDelete checks the preview names exactly the rows
+// the delete then moves to the trash, skipping rows already there.
+func TestDeleteImpactMatchesDelete(t *testing.T) {
+	db := testDB(t)
+	f := seedImpact(t, db)
+	ctx := context.Background()
+	s := New(db)
+	must(t, s.Components.Delete(ctx, f.SiblingComponent, 0))
+
+	im, err := s.Assets.DeleteImpact(ctx, f.Asset, 0)
+	must(t, err)
+	if got := fmt.Sprint(im.Deleted["components"]); got != fmt.Sprint([]string{f.Component}) {
+		t.Errorf("components = %s, want only the live one", got)
+	}
+	before := trashedRows(t, db)
+	must(t, s.Assets.Delete(ctx, f.Asset, 0))
+	total := 0
+	for _, ids := range im.Deleted {
+		total += len(ids)
+	}
+	if moved := trashedRows(t, db) - before; moved != total {
+		t.Errorf("delete trashed %d rows, preview said %d", moved, total)
+	}
+}
+
+func TestDeleteImpactPreconditions(t *testing.T) {
+	db := testDB(t)
+	f := seedImpact(t, db)
+	ctx := context.Background()
+	s := New(db)
+
+	if _, err := s.Applications.DeleteImpact(ctx, f.Application, 99); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("stale version: err = %v, want ErrVersionMismatch", err)
+	}
+	if _, err := s.Applications.DeleteImpact(ctx, "missing", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing row: err = %v, want ErrNotFound", err)
+	}
+
+	im, err := s.Workloads.DeleteImpact(ctx, f.Workload, 0)
+	must(t, err)
+	if im.Restorable || im.Counts["unlinked"] != 2 || im.Counts["workloads"] != 1 {
+		t.Errorf("workload impact = %+v, want 2 links, not restorable", im.Counts)
+	}
+
+	must(t, s.Applications.Delete(ctx, f.Application, 0))
+	im, err = s.Trash.PurgeImpact(ctx, "application", f.Application)
+	must(t, err)
+	if im.Restorable || im.Counts["components"] != 1 || im.Counts["unlinked"] != 1 {
+		t.Errorf("purge impact = %+v, want the trashed component and its link", im.Counts)
+	}
+}
+
+func TestReparentImpact(t *testing.T) {
+	db := testDB(t)
+	f := seedImpact(t, db)
+	ctx := context.Background()
+	s := New(db)
+
+	tests := []struct {
+		name     string
+		to       *string
+		departed string // type:count, in order
+	}{
+		{"unchanged", nil, "[]"},
+		{"same application", ptr(f.Application), "[]"},
+		// The workload stays in the grouping through the sibling.
+		{"sibling application", ptr(f.SiblingApp), "[application:1]"},
+		{"other portfolio", ptr(f.OtherApp), "[application:1 app_grouping:0 asset:0 portfolio:0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im, err := s.Components.ReparentImpact(ctx, f.Component, 0, tt.to)
+			must(t, err)
+			var got []string
+			for _, d := range im.Departed {
+				got = append(got, fmt.Sprintf("%s:%d", d.Type, d.Count))
+			}
+			if fmt.Sprint(got) != tt.departed {
+				t.Errorf("departed = %v, want %s", got, tt.departed)
+			}
+			if len(im.Workloads) != 1 {
+				t.Errorf("workloads = %v, want the linked one", im.Workloads)
+			}
+		})
+	}
+
+	_, err := s.Components.ReparentImpact(ctx, f.Component, 0, ptr("missing"))
+	var ce *ConstraintError
+	if !errors.As(err, &ce) || ce.Fields[0] != "application_id" {
+		t.Errorf("unknown application: err = %v, want foreign key on application_id", err)
+	}
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Interface is one network address of a workload.
+type Interface struct {
+	Name    *string `json:"name"`
+	Address string  `json:"address"`
+	// Family is ipv4 or ipv6; it is derived from Address on write.
+	Family    string `json:"family"`
+	CIDRBlock *int   `json:"cidr_block"`
+	// Source is manual (the default), illumio or servicenow.
+	Source string `json:"source"`
+}
+
+// normalizeInterfaces validates addresses and fills in the family and
+// default source. "10.0.0.5/24" is accepted as address plus cidr_block.
+// Repeated addresses keep the first entry.
+func normalizeInterfaces(list []Interface) ([]Interface, error) {
+	out := make([]Interface, 0, len(list))
+	seen := map[string]bool{}
+	for _, in := range list {
+		raw := strings.TrimSpace(in.Address)
+		var addr netip.Addr
+		if p, err := netip.ParsePrefix(raw); err == nil {
+			addr = p.Addr()
+			if in.CIDRBlock == nil {
+				bits := p.Bits()
+				in.CIDRBlock = &bits
+			}
+		} else if addr, err = netip.ParseAddr(raw); err != nil {
+			return nil, &ValidationError{fmt.Sprintf("invalid interface address %q", in.Address)}
+		}
+		addr = addr.Unmap()
+
+		in.Address = addr.String()
+		in.Family = "ipv4"
+		if addr.Is6() {
+			in.Family = "ipv6"
+		}
+		if in.Source == "" {
+			in.Source = "manual"
+		}
+		if !seen[in.Address] {
+			seen[in.Address] = true
+			out = append(out, in)
+		}
+	}
+	return out, nil
+}
+
+// normalizeIP returns the canonical form of an IP address, so IPv6
+// lookups match however the address was written; other input is returned
+// unchanged.
+func normalizeIP(ip string) string {
+	if addr, err := netip.ParseAddr(strings.TrimSpace(ip)); err == nil {
+		return addr.Unmap().String()
+	}
+	return ip
+}
+
+// normalizedIP is normalizeIP for an optional column value.
+func normalizedIP(ip *string) *string {
+	if ip == nil {
+		return nil
+	}
+	v := normalizeIP(*ip)
+	return &v
+}
+
+// ipMatch is the condition matching a workload by its primary address or
+// any of its interfaces; ipArgs gives its arguments.
+const ipMatch = "(ip_address IN (?, ?) OR workload_id IN (SELECT workload_id FROM workload_interfaces WHERE address = ?))"
+
+// ipArgs returns ipMatch's arguments for ip. Primary addresses are stored
+// in canonical form, but one written before that was enforced, in a form
+// migration 013 could not rewrite, still matches ip as written.
+func ipArgs(ip string) []any {
+	canon := normalizeIP(ip)
+	return []any{canon, strings.TrimSpace(ip), canon}
+}
+
+// replaceInterfaces sets the workload's interfaces to list.
+func replaceInterfaces(ctx context.Context, db DBTX, workloadID string, list []Interface) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM workload_interfaces WHERE workload_id = ?", workloadID); err != nil {
+		return err
+	}
+	for _, in := range list {
+		_, err := db.ExecContext(ctx,
+			"INSERT INTO workload_interfaces (workload_id, address, family, cidr_block, name, source) VALUES (?, ?, ?, ?, ?, ?)",
+			workloadID, in.Address, in.Family, in.CIDRBlock, in.Name, in.Source)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadInterfaces fills in Interfaces on each workload with one query.
+// Large batches read the whole table rather than bind thousands of ids.
+func loadInterfaces(ctx context.Context, db DBTX, ws []Workload) error {
+	if len(ws) == 0 {
+		return nil
+	}
+	byID := make(map[string]*Workload, len(ws))
+	args := make([]any, len(ws))
+	for i := range ws {
+		ws[i].Interfaces = []Interface{}
+		byID[ws[i].WorkloadID] = &ws[i]
+		args[i] = ws[i].WorkloadID
+	}
+
+	query := "SELECT workload_id, name, address, family, cidr_block, source FROM workload_interfaces"
+	if len(args) <= 500 {
+		query += " WHERE workload_id IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ") + ")"
+	} else {
+		args = nil
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY family, address", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var in Interface
+		if err := rows.Scan(&id, &in.Name, &in.Address, &in.Family, &in.CIDRBlock, &in.Source); err != nil {
+			return err
+		}
+		if w := byID[id]; w != nil {
+			w.Interfaces = append(w.Interfaces, in)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+)
+
+// TestIPAddressCanonical checks every write path stores ip_address as
+// lookups search for it, so an address is found by the form it was
+// written in.
+func TestIPAddressCanonical(t *testing.T) {
+	const sent, stored = " 2001:DB8::1 ", "2001:db8::1"
+	tests := []struct {
+		name  string
+		write func(*Store, *sql.DB, fixture) error
+	}{
+		{"create", func(s *Store, db *sql.DB, f fixture) error {
+			return s.Workloads.Create(context.Background(), &Workload{Hostname: "h1", IPAddress: ptr(sent)})
+		}},
+		{"update", func(s *Store, db *sql.DB, f fixture) error {
+			_, err := s.Workloads.Update(context.Background(), f.Workload, 0, WorkloadUpdate{IPAddress: ptr(sent)})
+			return err
+		}},
+		{"patch", func(s *Store, db *sql.DB, f fixture) error {
+			_, err := s.Workloads.Patch(context.Background(), f.Workload, 0, Patch{"ip_address": json.RawMessage(`" 2001:DB8::1 "`)})
+			return err
+		}},
+		{"upsert", func(s *Store, db *sql.DB, f fixture) error {
+			_, err := s.Workloads.Upsert(context.Background(), []WorkloadUpdate{{Hostname: ptr("h1"), IPAddress: ptr(sent)}})
+			return err
+		}},
+		{"canonicalizer", func(s *Store, db *sql.DB, f fixture) error {
+			ip, err := NewCanonicalizer(db).Canonical(context.Background(), "workloads", "ip_address", ptr(sent))
+			if err != nil {
+				return err
+			}
+			// As the native host's seed writes it, outside the store.
+			_, err = db.Exec("UPDATE workloads SET ip_address = ? WHERE workload_id = ?", ip, f.Workload)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			f := seed(t, db)
+			ctx := context.Background()
+			s := New(db)
+			must(t, tt.write(s, db, f))
+
+			if n := count(t, db, "workloads", "ip_address = ?", stored); n != 1 {
+				t.Errorf("%d workloads stored %s", n, stored)
+			}
+			for _, ip := range []string{sent, "2001:db8:0::1", "2001:DB8::1"} {
+				if _, err := s.Workloads.GetByIP(ctx, ip); err != nil {
+					t.Errorf("GetByIP(%q): %v", ip, err)
+				}
+			}
+		})
+	}
+}
+
+// TestIPAddressAsWritten checks an address stored before ip_address was
+// canonical, in a form migration 013 leaves alone, still matches as
+// written.
+func TestIPAddressAsWritten(t *testing.T) {
+	db := testDB(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	s := New(db)
+	_, err := db.Exec("UPDATE workloads SET ip_address = '2001:0db8::0001' WHERE workload_id = ?", f.Workload)
+	must(t, err)
+
+	w, err := s.Workloads.GetByIP(ctx, "2001:0db8::0001")
+	must(t, err)
+	if w.WorkloadID != f.Workload {
+		t.Errorf("found %s, want the seeded workload", w.WorkloadID)
+	}
+	list, err := s.Workloads.List(ctx, WorkloadFilter{IPAddress: "2001:0db8::0001"})
+	must(t, err)
+	if len(list.Items) != 1 {
+		t.Errorf("filter found %d workloads, want 1", len(list.Items))
+	}
+}
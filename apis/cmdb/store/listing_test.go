@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// TestListPaging walks every page by cursor and checks it yields the same
+// rows, in the same order, as one unpaged list, NULLs and ties included.
+func TestListPaging(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	s := New(db)
+	envs := []*string{ptr("production"), nil, ptr("development"), ptr("production"), nil, ptr("test"), ptr("production")}
+	for i, env := range envs {
+		must(t, s.Workloads.Create(ctx, &Workload{Hostname: fmt.Sprintf("h%d", i), Environment: env, IsVirtual: i%2 == 0}))
+	}
+
+	tests := []string{
+		"sort=environment",
+		"sort=-environment,hostname",
+		"sort=-is_virtual,-environment",
+		"environment[null]=false&sort=environment,-hostname",
+		"environment[in]=production,test&sort=-hostname",
+	}
+	for _, query := range tests {
+		t.Run(query, func(t *testing.T) {
+			params, _ := url.ParseQuery(query)
+			filter := listing.ParseFilter(params)
+			all, err := s.Workloads.List(ctx, WorkloadFilter{Filter: filter})
+			must(t, err)
+
+			var paged []string
+			page := listing.Page{Limit: 2}
+			for n := 0; ; n++ {
+				if n > len(envs) {
+					t.Fatal("cursor never ran out")
+				}
+				res, err := s.Workloads.List(ctx, WorkloadFilter{Page: page, Filter: filter})
+				must(t, err)
+				if res.Total != all.Total {
+					t.Errorf("page total %d, want %d", res.Total, all.Total)
+				}
+				for _, w := range res.Items {
+					paged = append(paged, w.Hostname)
+				}
+				if res.NextCursor == "" {
+					break
+				}
+				page.Cursor = res.NextCursor
+			}
+
+			var want []string
+			for _, w := range all.Items {
+				want = append(want, w.Hostname)
+			}
+			if fmt.Sprint(paged) != fmt.Sprint(want) {
+				t.Errorf("paged %v, want %v", paged, want)
+			}
+		})
+	}
+}
+
+func TestListRejectsFilters(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	s := New(db)
+
+	tests := []struct {
+		query     string
+		badCursor bool // else a FilterError
+	}{
+		{"secret[eq]=x", false},
+		{"sort=secret", false},
+		{"cursor=garbage", true},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		f := WorkloadFilter{Filter: listing.ParseFilter(params), Page: listing.Page{Limit: 1, Cursor: params.Get("cursor")}}
+		_, err := s.Workloads.List(ctx, f)
+		var fe *listing.FilterError
+		if tt.badCursor && !errors.Is(err, listing.ErrBadCursor) || !tt.badCursor && !errors.As(err, &fe) {
+			t.Errorf("%s: err = %v", tt.query, err)
+		}
+	}
+	// A plain param naming no column is another query parameter.
+	_, err := s.Workloads.List(ctx, WorkloadFilter{Filter: listing.ParseFilter(url.Values{"dry_run": {"1"}})})
+	must(t, err)
+}
@@ -0,0 +1,230 @@
+package store
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+)
+
+// Match is a workload found for one lookup identifier.
+type Match struct {
+	Identifier string `json:"identifier"`
+	// MatchedBy is hostname, fqdn, short_name, ip or cidr.
+	MatchedBy string   `json:"matched_by"`
+	Workload  Workload `json:"workload"`
+}
+
+// HostKey is a hostname as names are compared: trimmed, lower case and
+// without a trailing dot.
+func HostKey(host string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+}
+
+// ShortName is the first label of a hostname's key.
+func ShortName(host string) string {
+	host, _, _ = strings.Cut(HostKey(host), ".")
+	return host
+}
+
+// HostIndex finds values by host name the way every workload match in the
+// CMDB does: case-insensitively, an exact hostname first, then an exact
+// alias such as an FQDN, then the short name, so "web01" finds
+// "web01.corp.example.com" and the other way round.
+type HostIndex[T any] struct {
+	host, alias, short map[string][]T
+}
+
+func NewHostIndex[T any]() *HostIndex[T] {
+	return &HostIndex[T]{host: map[string][]T{}, alias: map[string][]T{}, short: map[string][]T{}}
+}
+
+// Add indexes v under its hostname and any aliases. Blank names are
+// skipped.
+func (x *HostIndex[T]) Add(v T, hostname string, aliases ...string) {
+	shorts := map[string]bool{}
+	if key := HostKey(hostname); key != "" {
+		x.host[key] = append(x.host[key], v)
+		shorts[ShortName(key)] = true
+	}
+	for _, a := range aliases {
+		if key := HostKey(a); key != "" {
+			x.alias[key] = append(x.alias[key], v)
+			shorts[ShortName(key)] = true
+		}
+	}
+	for s := range shorts {
+		x.short[s] = append(x.short[s], v)
+	}
+}
+
+// Find returns the values name matches, in the order they were added,
+// and how: hostname, fqdn (an alias) or short_name.
+func (x *HostIndex[T]) Find(name string) ([]T, string) {
+	key := HostKey(name)
+	if found := x.host[key]; len(found) > 0 {
+		return found, "hostname"
+	}
+	if found := x.alias[key]; len(found) > 0 {
+		return found, "fqdn"
+	}
+	return x.short[ShortName(key)], "short_name"
+}
+
+// First returns the first value name matches.
+func (x *HostIndex[T]) First(name string) (T, bool) {
+	found, _ := x.Find(name)
+	if len(found) == 0 {
+		var zero T
+		return zero, false
+	}
+	return found[0], true
+}
+
+// workloadIndex resolves identifiers against every workload in memory, so
+// a batch costs one table scan rather than one query per identifier.
+type workloadIndex struct {
+	names *HostIndex[*Workload]
+	ips   []workloadIP
+}
+
+type workloadIP struct {
+	addr netip.Addr
+	w    *Workload
+}
+
+func newWorkloadIndex(all []Workload) *workloadIndex {
+	idx := &workloadIndex{names: NewHostIndex[*Workload]()}
+	for i := range all {
+		w := &all[i]
+		var aliases []string
+		if w.FQDN != nil {
+			aliases = append(aliases, *w.FQDN)
+		}
+		idx.names.Add(w, w.Hostname, aliases...)
+		addrs := map[netip.Addr]bool{}
+		if w.IPAddress != nil {
+			if addr, err := netip.ParseAddr(*w.IPAddress); err == nil {
+				addrs[addr.Unmap()] = true
+			}
+		}
+		for _, in := range w.Interfaces {
+			if addr, err := netip.ParseAddr(in.Address); err == nil {
+				addrs[addr.Unmap()] = true
+			}
+		}
+		for addr := range addrs {
+			idx.ips = append(idx.ips, workloadIP{addr, w})
+		}
+	}
+	return idx
+}
+
+// byAddr returns each workload with an address satisfying ok, once.
+func (idx *workloadIndex) byAddr(ok func(netip.Addr) bool) []*Workload {
+	var found []*Workload
+	seen := map[*Workload]bool{}
+	for _, ip := range idx.ips {
+		if ok(ip.addr) && !seen[ip.w] {
+			seen[ip.w] = true
+			found = append(found, ip.w)
+		}
+	}
+	return found
+}
+
+// match returns the workloads for one identifier and how they matched, or
+// "" for an identifier that is neither an address, a range nor a name.
+// Names are matched as HostIndex does.
+func (idx *workloadIndex) match(id string) ([]*Workload, string) {
+	if prefix, err := netip.ParsePrefix(id); err == nil {
+		return idx.byAddr(prefix.Masked().Contains), "cidr"
+	}
+	if addr, err := netip.ParseAddr(id); err == nil {
+		addr = addr.Unmap()
+		return idx.byAddr(func(a netip.Addr) bool { return a == addr }), "ip"
+	}
+
+	if !validName(HostKey(id)) {
+		return nil, ""
+	}
+	return idx.names.Find(id)
+}
+
+// validName reports whether name could be a host name: dot-separated
+// labels of letters, digits, hyphens and underscores, not all of them
+// numeric. It rules out malformed addresses and ranges such as
+// "10.0.0.300" and "bad/99", which would otherwise just not be found.
+func validName(name string) bool {
+	numeric := true
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return false
+		}
+		for _, r := range label {
+			switch {
+			case r >= '0' && r <= '9':
+			case r >= 'a' && r <= 'z', r == '-', r == '_':
+				numeric = false
+			default:
+				return false
+			}
+		}
+	}
+	return !numeric
+}
+
+// MaxLookupMatches bounds the workloads returned for one identifier, so a
+// range such as 0.0.0.0/0 cannot return the whole table.
+const MaxLookupMatches = 100
+
+// LookupResult is what a batch lookup found. NotFound, Invalid and
+// Truncated list identifiers in request order: those that matched
+// nothing, those that are not a hostname, FQDN, address or range, and
+// those whose matches were cut to MaxLookupMatches.
+type LookupResult struct {
+	Matches   []Match
+	NotFound  []string
+	Invalid   []string
+	Truncated []string
+}
+
+// Lookup resolves each identifier — hostname, FQDN, IP address or CIDR
+// range — to the workloads it names. Addresses match ip_address and every
+// interface.
+func (r *workloadRepo) Lookup(ctx context.Context, identifiers []string) (*LookupResult, error) {
+	all, err := workloads.query(ctx, r.db, "SELECT "+workloadColumns+" FROM workloads ORDER BY hostname")
+	if err != nil {
+		return nil, err
+	}
+	if err := loadInterfaces(ctx, r.db, all); err != nil {
+		return nil, err
+	}
+	idx := newWorkloadIndex(all)
+
+	res := &LookupResult{Matches: []Match{}, NotFound: []string{}, Invalid: []string{}, Truncated: []string{}}
+	seen := map[string]bool{}
+	for _, id := range identifiers {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		found, by := idx.match(id)
+		switch {
+		case by == "":
+			res.Invalid = append(res.Invalid, id)
+			continue
+		case len(found) == 0:
+			res.NotFound = append(res.NotFound, id)
+			continue
+		case len(found) > MaxLookupMatches:
+			found = found[:MaxLookupMatches]
+			res.Truncated = append(res.Truncated, id)
+		}
+		for _, w := range found {
+			res.Matches = append(res.Matches, Match{Identifier: id, MatchedBy: by, Workload: *w})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	s := New(db)
+	for _, w := range []*Workload{
+		{Hostname: "web01", FQDN: ptr("web01.corp.example.com"), IPAddress: ptr("10.0.0.1")},
+		{Hostname: "db01.corp.example.com", Interfaces: []Interface{{Address: "10.0.1.5"}, {Address: "fd00::5"}}},
+		{Hostname: "app_02"},
+	} {
+		must(t, s.Workloads.Create(ctx, w))
+	}
+
+	tests := []struct {
+		id     string
+		status string // matched_by, or not_found / invalid
+		hosts  string
+	}{
+		{"WEB01", "hostname", "[web01]"},
+		{"web01.corp.example.com.", "fqdn", "[web01]"},
+		{"db01", "short_name", "[db01.corp.example.com]"},
+		{"web01.other.example.com", "short_name", "[web01]"},
+		{"app_02", "hostname", "[app_02]"},
+		{"10.0.0.1", "ip", "[web01]"},
+		{"::ffff:10.0.1.5", "ip", "[db01.corp.example.com]"},
+		{"10.0.0.0/16", "cidr", "[db01.corp.example.com web01]"},
+		{"fd00::/64", "cidr", "[db01.corp.example.com]"},
+		{"web99", "not_found", ""},
+		{"10.9.9.9", "not_found", ""},
+		{"bad/99", "invalid", ""},
+		{"10.0.0.0/99", "invalid", ""},
+		{"10.0.0.300", "invalid", ""},
+		{"web 01", "invalid", ""},
+		{"web..01", "invalid", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			res, err := s.Workloads.Lookup(ctx, []string{tt.id})
+			must(t, err)
+			var got string
+			var hosts []string
+			switch {
+			case len(res.Invalid) == 1:
+				got = "invalid"
+			case len(res.NotFound) == 1:
+				got = "not_found"
+			case len(res.Matches) > 0:
+				got = res.Matches[0].MatchedBy
+				for _, m := range res.Matches {
+					hosts = append(hosts, m.Workload.Hostname)
+				}
+			}
+			if got != tt.status {
+				t.Errorf("status = %q, want %q", got, tt.status)
+			}
+			if hosts != nil && fmt.Sprint(hosts) != tt.hosts {
+				t.Errorf("hosts = %v, want %s", hosts, tt.hosts)
+			}
+		})
+	}
+}
+
+func TestLookupCapsMatches(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	s := New(db)
+	var rows []WorkloadUpdate
+	for i := range MaxLookupMatches + 5 {
+		rows = append(rows, WorkloadUpdate{Hostname: ptr(fmt.Sprintf("h%03d", i)), IPAddress: ptr(fmt.Sprintf("10.1.%d.%d", i/250, i%250+1))})
+	}
+	_, err := s.Workloads.Upsert(ctx, rows)
+	must(t, err)
+
+	res, err := s.Workloads.Lookup(ctx, []string{"0.0.0.0/0", "h001", "::/0"})
+	must(t, err)
+	if len(res.Matches) != MaxLookupMatches+1 {
+		t.Errorf("%d matches, want %d: the capped range and h001", len(res.Matches), MaxLookupMatches+1)
+	}
+	if fmt.Sprint(res.Truncated) != "[0.0.0.0/0]" || fmt.Sprint(res.NotFound) != "[::/0]" {
+		t.Errorf("truncated %v, not found %v", res.Truncated, res.NotFound)
+	}
+}
+
+func TestHostIndex(t *testing.T) {
+	x := NewHostIndex[string]()
+	x.Add("a", "web01.corp.example.com")
+	x.Add("b", "WEB01", "web01.dmz.example.com.")
+	x.Add("c", " db01 ")
+	x.Add("d", "")
+
+	tests := []struct {
+		name, by, found string
+	}{
+		{"web01", "hostname", "[b]"},
+		{"Web01.Corp.Example.com", "hostname", "[a]"},
+		{"web01.dmz.example.com", "fqdn", "[b]"},
+		{"web01.other.example.com", "short_name", "[a b]"},
+		{"db01.corp.example.com", "short_name", "[c]"},
+		{"DB01.", "hostname", "[c]"},
+		{"", "short_name", "[]"},
+	}
+	for _, tt := range tests {
+		found, by := x.Find(tt.name)
+		if by != tt.by || fmt.Sprint(found) != tt.found {
+			t.Errorf("Find(%q) = %v by %s, want %s by %s", tt.name, found, by, tt.found, tt.by)
+		}
+	}
+	if v, ok := x.First("web01.other.example.com"); !ok || v != "a" {
+		t.Errorf("First = %q, %v; want the first added", v, ok)
+	}
+}
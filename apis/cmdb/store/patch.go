@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Patch is a JSON Merge Patch (RFC 7396) for one row: fields that are
+// absent are left alone and an explicit null clears the column.
+type Patch map[string]json.RawMessage
+
+// assignments turns a patch into SET clauses. The writable columns are
+// the json fields of U, the entity's update struct, decoded with the same
+// types a PUT uses; whether a column may be cleared comes from the model
+// T, where nullable columns are pointer fields.
+func assignments[T, U any](p Patch) (sets []string, args []any, err error) {
+	model := map[string]reflect.Type{}
+	mt := reflect.TypeFor[T]()
+	for i := 0; i < mt.NumField(); i++ {
+		model[jsonName(mt.Field(i))] = mt.Field(i).Type
+	}
+
+	writable := map[string]bool{}
+	ut := reflect.TypeFor[U]()
+	for i := 0; i < ut.NumField(); i++ {
+		f := ut.Field(i)
+		name := jsonName(f)
+		writable[name] = true
+		raw, ok := p[name]
+		if !ok || f.Type.Kind() != reflect.Pointer {
+			continue
+		}
+		v := reflect.New(f.Type)
+		if err := json.Unmarshal(raw, v.Interface()); err != nil {
+			return nil, nil, &ValidationError{fmt.Sprintf("invalid %s: %v", name, err)}
+		}
+		sets = append(sets, name+" = ?")
+		if v.Elem().IsNil() {
+			if model[name].Kind() != reflect.Pointer {
+				return nil, nil, &ValidationError{name + " cannot be null"}
+			}
+			args = append(args, nil)
+			continue
+		}
+		switch val := v.Elem().Interface().(type) {
+		case *Bool:
+			args = append(args, boolArg(val))
+		default:
+			args = append(args, v.Elem().Elem().Interface())
+		}
+	}
+
+	for name := range p {
+		if !writable[name] {
+			return nil, nil, &ValidationError{fmt.Sprintf("%s is not a writable field", name)}
+		}
+	}
+	return sets, args, nil
+}
+
+func jsonName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+// patch applies SET clauses to the row at version (0 for any), bumping
+// updated_at, and returns the result. Vocabulary columns are normalized
+// first. An empty patch changes nothing but still checks that the row
+// exists at that version.
+func (t table[T]) patch(ctx context.Context, db DBTX, id string, version int, sets []string, args []any) (*T, error) {
+	if len(sets) == 0 {
+		row, err := t.get(ctx, db, id)
+		if err != nil {
+			return nil, err
+		}
+		if v, _ := VersionOf(row); version != 0 && v != version {
+			return nil, ErrVersionMismatch
+		}
+		return row, nil
+	}
+	vc := &vocabCache{}
+	for i, set := range sets {
+		column := strings.TrimSuffix(set, " = ?")
+		vocabulary, ok := t.vocab[column]
+		raw, isString := args[i].(string)
+		if !ok || !isString {
+			continue
+		}
+		value, err := vc.canonical(ctx, db, vocabulary, column, raw)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s, updated_at = datetime('now') WHERE %s = ? AND (? = 0 OR version = ?)%s",
+		t.name, strings.Join(sets, ", "), t.pk, t.live())
+	res, err := db.ExecContext(ctx, query, append(args, id, version, version)...)
+	if err != nil {
+		changes := make(map[string]any, len(sets))
+		for i, set := range sets {
+			changes[strings.TrimSuffix(set, " = ?")] = args[i]
+		}
+		return nil, t.explain(ctx, db, err, id, changes)
+	}
+	if err := t.matched(ctx, db, res, id); err != nil {
+		return nil, err
+	}
+	return t.get(ctx, db, id)
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		// read renders the asset read back, to compare with want; err,
+		// if set, is the ValidationError the patch must fail with instead.
+		read func(*Asset) string
+		want string
+		err  string
+	}{
+		{
+			name:  "set",
+			patch: `{"description": "ledger of record"}`,
+			read:  func(a *Asset) string { return str(a.Description) + " " + a.Name },
+			want:  "ledger of record Ledger",
+		},
+		{
+			name:  "null clears a nullable column",
+			patch: `{"description": null, "full_name": null}`,
+			read:  func(a *Asset) string { return fmt.Sprint(a.Description == nil, a.FullName == nil) + " " + a.Name },
+			want:  "true true Ledger",
+		},
+		{
+			name:  "absent fields are left alone",
+			patch: `{"full_name": "General Ledger"}`,
+			read:  func(a *Asset) string { return str(a.FullName) + " " + str(a.Description) },
+			want:  "General Ledger draft",
+		},
+		{
+			name:  "vocabulary alias",
+			patch: `{"criticality": "1 - most critical", "environment": "Prod"}`,
+			read:  func(a *Asset) string { return str(a.Criticality) + " " + str(a.Environment) },
+			want:  "critical production",
+		},
+		{name: "null on a NOT NULL column", patch: `{"name": null}`, err: "name cannot be null"},
+		{name: "null on a required parent", patch: `{"portfolio_id": null}`, err: "portfolio_id cannot be null"},
+		{name: "not writable", patch: `{"asset_id": "other"}`, err: "asset_id is not a writable field"},
+		{name: "unknown field", patch: `{"nope": 1}`, err: "nope is not a writable field"},
+		{name: "wrong type", patch: `{"name": 5}`, err: "invalid name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			f := seed(t, db)
+			ctx := context.Background()
+			s := New(db)
+			_, err := s.Assets.Patch(ctx, f.Asset, 0, Patch{"description": json.RawMessage(`"draft"`), "full_name": json.RawMessage(`"Ledger"`)})
+			must(t, err)
+
+			var p Patch
+			must(t, json.Unmarshal([]byte(tt.patch), &p))
+			_, err = s.Assets.Patch(ctx, f.Asset, 0, p)
+			if tt.err != "" {
+				var ve *ValidationError
+				if !errors.As(err, &ve) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want ValidationError %q", err, tt.err)
+				}
+				return
+			}
+			must(t, err)
+			got, err := s.Assets.Get(ctx, f.Asset)
+			must(t, err)
+			if g := tt.read(got); g != tt.want {
+				t.Errorf("read back %q, want %q", g, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchVersion(t *testing.T) {
+	db := testDB(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	s := New(db)
+
+	a, err := s.Assets.Get(ctx, f.Asset)
+	must(t, err)
+	// An empty patch changes nothing but still checks the version.
+	same, err := s.Assets.Patch(ctx, f.Asset, a.Version, Patch{})
+	must(t, err)
+	if same.Version != a.Version {
+		t.Errorf("empty patch moved the version to %d", same.Version)
+	}
+	if _, err := s.Assets.Patch(ctx, f.Asset, a.Version+1, Patch{}); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("empty patch, stale version: err = %v, want ErrVersionMismatch", err)
+	}
+
+	next, err := s.Assets.Patch(ctx, f.Asset, a.Version, Patch{"name": json.RawMessage(`"Ledger II"`)})
+	must(t, err)
+	if next.Version != a.Version+1 {
+		t.Errorf("version = %d, want %d", next.Version, a.Version+1)
+	}
+	if _, err := s.Assets.Patch(ctx, f.Asset, a.Version, Patch{"name": json.RawMessage(`"Ledger III"`)}); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("stale version: err = %v, want ErrVersionMismatch", err)
+	}
+	if _, err := s.Assets.Patch(ctx, "missing", 0, Patch{"name": json.RawMessage(`"x"`)}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing row: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPatchWorkloadInterfaces(t *testing.T) {
+	db := testDB(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	s := New(db)
+
+	tests := []struct {
+		name, patch string
+		interfaces  string
+	}{
+		{"replace", `{"interfaces": [{"address": "10.0.0.2"}, {"address": "10.0.0.3"}]}`, "[10.0.0.2 10.0.0.3]"},
+		{"other fields keep them", `{"os": "linux", "is_virtual": true}`, "[10.0.0.2 10.0.0.3]"},
+		{"null clears them", `{"interfaces": null}`, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Patch
+			must(t, json.Unmarshal([]byte(tt.patch), &p))
+			_, err := s.Workloads.Patch(ctx, f.Workload, 0, p)
+			must(t, err)
+			w, err := s.Workloads.Get(ctx, f.Workload)
+			must(t, err)
+			var got []string
+			for _, i := range w.Interfaces {
+				got = append(got, i.Address)
+			}
+			if fmt.Sprint(got) != tt.interfaces {
+				t.Errorf("interfaces = %v, want %s", got, tt.interfaces)
+			}
+		})
+	}
+
+	for _, tt := range []struct{ name, patch, err string }{
+		{"bad address", `{"interfaces": [{"address": "not-an-ip"}]}`, "not-an-ip"},
+		{"null hostname", `{"hostname": null}`, "hostname cannot be null"},
+		{"null flag", `{"is_virtual": null}`, "is_virtual cannot be null"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Patch
+			must(t, json.Unmarshal([]byte(tt.patch), &p))
+			var ve *ValidationError
+			_, err := s.Workloads.Patch(ctx, f.Workload, 0, p)
+			if !errors.As(err, &ve) || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want ValidationError containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// Portfolio is tier 1 of the BIA hierarchy.
+type Portfolio struct {
+	PortfolioID string    `json:"portfolio_id"`
+	Name        string    `json:"name"`
+	SnowSysID   *string   `json:"snow_sys_id"`
+	State       *string   `json:"state"`
+	Description *string   `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int       `json:"version"`
+}
+
+// PortfolioUpdate holds the fields to change; nil fields are left as is.
+type PortfolioUpdate struct {
+	Name        *string `json:"name"`
+	SnowSysID   *string `json:"snow_sys_id"`
+	State       *string `json:"state"`
+	Description *string `json:"description"`
+}
+
+type PortfolioFilter struct {
+	Q string
+	listing.Page
+	listing.Filter
+}
+
+type PortfolioRepository interface {
+	List(ctx context.Context, f PortfolioFilter) (*listing.Result[Portfolio], error)
+	Get(ctx context.Context, id string) (*Portfolio, error)
+	Create(ctx context.Context, p *Portfolio) error
+	Update(ctx context.Context, id string, version int, u PortfolioUpdate) (*Portfolio, error)
+	Patch(ctx context.Context, id string, version int, p Patch) (*Portfolio, error)
+	Delete(ctx context.Context, id string, version int) error
+	DeleteImpact(ctx context.Context, id string, version int) (*Impact, error)
+}
+
+var portfolios = table[Portfolio]{
+	name:    "portfolios",
+	pk:      "portfolio_id",
+	columns: "portfolio_id, name, snow_sys_id, state, description, created_at, updated_at, version",
+	order:   []listing.Order{{Column: "name"}},
+	scan: func(s scanner) (*Portfolio, error) {
+		var p Portfolio
+		err := s.Scan(&p.PortfolioID, &p.Name, &p.SnowSysID, &p.State, &p.Description,
+			ts(&p.CreatedAt), ts(&p.UpdatedAt), &p.Version)
+		return &p, err
+	},
+}
+
+type portfolioRepo struct{ db DBTX }
+
+func (r *portfolioRepo) List(ctx context.Context, f PortfolioFilter) (*listing.Result[Portfolio], error) {
+	var w where
+	w.like("name", f.Q)
+	return portfolios.list(ctx, r.db, w, f.Page, f.Filter)
+}
+
+func (r *portfolioRepo) Get(ctx context.Context, id string) (*Portfolio, error) {
+	return portfolios.get(ctx, r.db, id)
+}
+
+// Create inserts p, assigning an ID if it has none, and reloads it so
+// defaults and timestamps are filled in.
+func (r *portfolioRepo) Create(ctx context.Context, p *Portfolio) error {
+	if p.PortfolioID == "" {
+		p.PortfolioID = newID()
+	}
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO portfolios (portfolio_id, name, snow_sys_id, state, description) VALUES (?, ?, ?, ?, ?)",
+		p.PortfolioID, p.Name, p.SnowSysID, p.State, p.Description)
+	if err != nil {
+		return portfolios.explain(ctx, r.db, err, "", fields(p))
+	}
+	saved, err := r.Get(ctx, p.PortfolioID)
+	if err != nil {
+		return err
+	}
+	*p = *saved
+	return nil
+}
+
+func (r *portfolioRepo) Update(ctx context.Context, id string, version int, u PortfolioUpdate) (*Portfolio, error) {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE portfolios SET name=COALESCE(?,name), snow_sys_id=COALESCE(?,snow_sys_id), state=COALESCE(?,state), description=COALESCE(?,description), updated_at=datetime('now') WHERE portfolio_id=? AND (?=0 OR version=?) AND deleted_at IS NULL",
+		u.Name, u.SnowSysID, u.State, u.Description, id, version, version)
+	if err != nil {
+		return nil, portfolios.explain(ctx, r.db, err, id, fields(u))
+	}
+	if err := portfolios.matched(ctx, r.db, res, id); err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+// Patch applies a JSON Merge Patch; null clears a nullable column.
+func (r *portfolioRepo) Patch(ctx context.Context, id string, version int, p Patch) (*Portfolio, error) {
+	sets, args, err := assignments[Portfolio, PortfolioUpdate](p)
+	if err != nil {
+		return nil, err
+	}
+	return portfolios.patch(ctx, r.db, id, version, sets, args)
+}
+
+func (r *portfolioRepo) Delete(ctx context.Context, id string, version int) error {
+	return portfolios.delete(ctx, r.db, id, version)
+}
+
+func (r *portfolioRepo) DeleteImpact(ctx context.Context, id string, version int) (*Impact, error) {
+	return portfolios.deleteImpact(ctx, r.db, id, version)
+}
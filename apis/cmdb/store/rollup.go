@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// Scope is a hierarchy tier whose workloads can be rolled up.
+type Scope string
+
+const (
+	ScopePortfolio   Scope = "portfolio"
+	ScopeAsset       Scope = "asset"
+	ScopeAppGrouping Scope = "app_grouping"
+	ScopeApplication Scope = "application"
+)
+
+// scopes gives, per tier, its table and primary key, the joins from
+// components up to it, and the column identifying the node.
+var scopes = map[Scope]struct {
+	table, pk, joins, column string
+}{
+	ScopeApplication: {"applications", "application_id", "", "c.application_id"},
+	ScopeAppGrouping: {"app_groupings", "app_grouping_id",
+		" JOIN applications a ON a.application_id = c.application_id",
+		"a.app_grouping_id"},
+	ScopeAsset: {"assets", "asset_id",
+		" JOIN applications a ON a.application_id = c.application_id" +
+			" JOIN app_groupings ag ON ag.app_grouping_id = a.app_grouping_id",
+		"ag.asset_id"},
+	ScopePortfolio: {"portfolios", "portfolio_id",
+		" JOIN applications a ON a.application_id = c.application_id" +
+			" JOIN app_groupings ag ON ag.app_grouping_id = a.app_grouping_id" +
+			" JOIN assets ast ON ast.asset_id = ag.asset_id",
+		"ast.portfolio_id"},
+}
+
+// LinkingComponent is a component through which a workload sits under a
+// hierarchy node.
+type LinkingComponent struct {
+	ComponentID   string  `json:"component_id"`
+	Name          *string `json:"name"`
+	ApplicationID string  `json:"application_id"`
+}
+
+// LinkedWorkload is a workload with the components that link it.
+type LinkedWorkload struct {
+	Workload
+	Components []LinkingComponent `json:"components"`
+}
+
+// ListUnder returns the distinct workloads linked to any component beneath
+// the node, each with its linking components there. The usual workload
+// filters, sort and paging apply.
+func (r *workloadRepo) ListUnder(ctx context.Context, scope Scope, id string, f WorkloadFilter) (*listing.Result[LinkedWorkload], error) {
+	s, ok := scopes[scope]
+	if !ok {
+		return nil, fmt.Errorf("unknown scope %q", scope)
+	}
+	var exists int
+	err := r.db.QueryRowContext(ctx, fmt.Sprintf("SELECT 1 FROM %s WHERE %s = ? AND deleted_at IS NULL", s.table, s.pk), id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	from := " FROM component_workloads cw JOIN components c ON c.component_id = cw.component_id" + s.joins +
+		" WHERE c.deleted_at IS NULL AND " + s.column + " = ?"
+
+	w := r.filter(f)
+	w.add("workload_id IN (SELECT cw.workload_id"+from+")", id)
+	page, err := workloads.list(ctx, r.db, w, f.Page, f.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadInterfaces(ctx, r.db, page.Items); err != nil {
+		return nil, err
+	}
+
+	result := &listing.Result[LinkedWorkload]{
+		Items:      make([]LinkedWorkload, len(page.Items)),
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+	}
+	if len(page.Items) == 0 {
+		return result, nil
+	}
+	byID := map[string]*LinkedWorkload{}
+	args := []any{id}
+	for i, wl := range page.Items {
+		result.Items[i] = LinkedWorkload{Workload: wl, Components: []LinkingComponent{}}
+		byID[wl.WorkloadID] = &result.Items[i]
+		args = append(args, wl.WorkloadID)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT DISTINCT cw.workload_id, c.component_id, c.name, c.application_id"+from+
+			" AND cw.workload_id IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(page.Items)), ", ")+")"+
+			" ORDER BY c.name, c.component_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var workloadID string
+		var lc LinkingComponent
+		if err := rows.Scan(&workloadID, &lc.ComponentID, &lc.Name, &lc.ApplicationID); err != nil {
+			return nil, err
+		}
+		if lw := byID[workloadID]; lw != nil {
+			lw.Components = append(lw.Components, lc)
+		}
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+func TestListUnder(t *testing.T) {
+	db := testDB(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	s := New(db)
+
+	link := func(component, workload string) {
+		t.Helper()
+		_, err := db.Exec("INSERT INTO component_workloads (component_id, workload_id) VALUES (?, ?)", component, workload)
+		must(t, err)
+	}
+	workload := func(hostname string) string {
+		t.Helper()
+		w := &Workload{Hostname: hostname}
+		must(t, s.Workloads.Create(ctx, w))
+		return w.WorkloadID
+	}
+
+	// The seeded workload sits under two components; a second workload
+	// under one of them.
+	worker := &Component{Name: ptr("worker"), ApplicationID: f.Application}
+	must(t, s.Components.Create(ctx, worker))
+	link(worker.ComponentID, f.Workload)
+	link(worker.ComponentID, workload("ledger02.corp.example.com"))
+
+	// Linked only through a trashed component.
+	gone := &Component{Name: ptr("gone"), ApplicationID: f.Application}
+	must(t, s.Components.Create(ctx, gone))
+	link(gone.ComponentID, workload("gone01"))
+	must(t, s.Components.Delete(ctx, gone.ComponentID, 0))
+
+	// Under another portfolio.
+	p := &Portfolio{Name: "Cards"}
+	must(t, s.Portfolios.Create(ctx, p))
+	a := &Asset{Name: "Issuing", PortfolioID: p.PortfolioID}
+	must(t, s.Assets.Create(ctx, a))
+	g := &AppGrouping{Name: "Issuing", AssetID: a.AssetID}
+	must(t, s.AppGroupings.Create(ctx, g))
+	app := &Application{Name: "issuing-api", AppGroupingID: g.AppGroupingID}
+	must(t, s.Applications.Create(ctx, app))
+	other := &Component{Name: ptr("api"), ApplicationID: app.ApplicationID}
+	must(t, s.Components.Create(ctx, other))
+	link(other.ComponentID, workload("cards01"))
+
+	trashed := &Application{Name: "retired", AppGroupingID: f.AppGrouping}
+	must(t, s.Applications.Create(ctx, trashed))
+	must(t, s.Applications.Delete(ctx, trashed.ApplicationID, 0))
+
+	const both = "[ledger01.corp.example.com [api worker] ledger02.corp.example.com [worker]]"
+	tests := []struct {
+		name   string
+		scope  Scope
+		id     string
+		filter WorkloadFilter
+		want   string // hostname and linking component names of each
+		total  int
+	}{
+		{"portfolio", ScopePortfolio, f.Portfolio, WorkloadFilter{}, both, 2},
+		{"asset", ScopeAsset, f.Asset, WorkloadFilter{}, both, 2},
+		{"app grouping", ScopeAppGrouping, f.AppGrouping, WorkloadFilter{}, both, 2},
+		{"application", ScopeApplication, f.Application, WorkloadFilter{}, both, 2},
+		{"other portfolio", ScopePortfolio, p.PortfolioID, WorkloadFilter{}, "[cards01 [api]]", 1},
+		{"search", ScopePortfolio, f.Portfolio, WorkloadFilter{Q: "ledger02"}, "[ledger02.corp.example.com [worker]]", 1},
+		{"paged", ScopePortfolio, f.Portfolio, WorkloadFilter{Page: listing.Page{Limit: 1}}, "[ledger01.corp.example.com [api worker]]", 2},
+		{"empty", ScopeAsset, a.AssetID, WorkloadFilter{Q: "nothing"}, "[]", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := s.Workloads.ListUnder(ctx, tt.scope, tt.id, tt.filter)
+			must(t, err)
+			got := []any{}
+			for _, lw := range res.Items {
+				var names []string
+				for _, c := range lw.Components {
+					names = append(names, *c.Name)
+				}
+				got = append(got, lw.Hostname, names)
+			}
+			if fmt.Sprint(got) != tt.want || res.Total != tt.total {
+				t.Errorf("got %v (total %d), want %s (total %d)", got, res.Total, tt.want, tt.total)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name  string
+		scope Scope
+		id    string
+	}{
+		{"missing node", ScopeAsset, "missing"},
+		{"trashed node", ScopeApplication, trashed.ApplicationID},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Workloads.ListUnder(ctx, tt.scope, tt.id, WorkloadFilter{}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+	if _, err := s.Workloads.ListUnder(ctx, "component", f.Component, WorkloadFilter{}); err == nil {
+		t.Error("unknown scope: no error")
+	}
+}
@@ -0,0 +1,355 @@
+// Package store provides typed models and repositories for the CMDB
+// hierarchy: Portfolio → Asset → App Grouping → Application → Component,
+// plus Workloads and the component class/type lookups.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// ErrNotFound is returned when a row does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrVersionMismatch is returned when a conditional write names a version
+// the row no longer has.
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// ValidationError is returned when input is rejected before reaching the
+// database.
+type ValidationError struct{ msg string }
+
+func (e *ValidationError) Error() string { return e.msg }
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// Store groups the repositories for each entity.
+type Store struct {
+	Portfolios       PortfolioRepository
+	Assets           AssetRepository
+	AppGroupings     AppGroupingRepository
+	Applications     ApplicationRepository
+	Components       ComponentRepository
+	ComponentTypes   ComponentTypeRepository
+	ComponentClasses ComponentClassRepository
+	Workloads        WorkloadRepository
+	Vocabularies     VocabularyRepository
+	Trash            TrashRepository
+	Audit            AuditRepository
+}
+
+// New returns a Store backed by db.
+func New(db DBTX) *Store {
+	return &Store{
+		Portfolios:       &portfolioRepo{db},
+		Assets:           &assetRepo{db},
+		AppGroupings:     &appGroupingRepo{db},
+		Applications:     &applicationRepo{db},
+		Components:       &componentRepo{db},
+		ComponentTypes:   &componentTypeRepo{db},
+		ComponentClasses: &componentClassRepo{db},
+		Workloads:        &workloadRepo{db},
+		Vocabularies:     &vocabularyRepo{db},
+		Trash:            &trashRepo{db},
+		Audit:            &auditRepo{db},
+	}
+}
+
+func newID() string {
+	return uuid.New().String()
+}
+
+// prefixed qualifies each column in a comma-separated list with alias.
+func prefixed(alias, columns string) string {
+	cols := strings.Split(columns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+	return strings.Join(cols, ", ")
+}
+
+// inTx runs fn in a transaction when db is a *sql.DB, and directly on db
+// when it is already a transaction.
+func inTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	conn, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ─── Query helpers ──────────────────────────────────────────
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// table holds the per-entity pieces shared by the generic get/list/delete.
+type table[T any] struct {
+	name    string
+	pk      string
+	columns string
+	order   []listing.Order
+	scan    func(scanner) (*T, error)
+	// vocab maps columns restricted to a managed vocabulary to its name.
+	vocab map[string]string
+}
+
+func (t table[T]) get(ctx context.Context, db DBTX, id string) (*T, error) {
+	row := db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?%s", t.columns, t.name, t.pk, t.live()), id)
+	v, err := t.scan(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return v, err
+}
+
+// list returns one page of the rows matching w and the generic filter,
+// in the requested (or the table's default) order, along with the total
+// match count and the cursor for the next page.
+func (t table[T]) list(ctx context.Context, db DBTX, w where, page listing.Page, f listing.Filter) (*listing.Result[T], error) {
+	schema, err := listing.Describe(ctx, db, t.name)
+	if err != nil {
+		return nil, err
+	}
+	if t.live() != "" {
+		w.add("deleted_at IS NULL")
+	}
+	q, err := f.Apply(listing.Query{
+		Columns: t.columns,
+		From:    t.name,
+		Where:   w.clauses,
+		Args:    w.args,
+		Order:   t.order,
+		PK:      t.pk,
+	}, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := q.Select(page)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := t.query(ctx, db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &listing.Result[T]{}
+	result.Items, result.NextCursor = listing.Trim(q, page, rows, columnValue[T])
+
+	query, args = q.Count()
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&result.Total); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (t table[T]) query(ctx context.Context, db DBTX, query string, args ...any) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []T{}
+	for rows.Next() {
+		v, err := t.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *v)
+	}
+	return list, rows.Err()
+}
+
+// delete removes the row, or moves it to the trash for the hierarchy
+// tables; a non-zero version makes it conditional on the row still being
+// at that version.
+func (t table[T]) delete(ctx context.Context, db DBTX, id string, version int) error {
+	if tierIndex(t.name) >= 0 {
+		return t.trash(ctx, db, id, version)
+	}
+	res, err := db.ExecContext(ctx,
+		fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND (? = 0 OR version = ?)", t.name, t.pk), id, version, version)
+	if err != nil {
+		return err
+	}
+	return t.matched(ctx, db, res, id)
+}
+
+// matched explains a conditional write that affected no rows: the row is
+// gone (ErrNotFound) or at another version (ErrVersionMismatch).
+func (t table[T]) matched(ctx context.Context, db DBTX, res sql.Result, id string) error {
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	var one int
+	err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT 1 FROM %s WHERE %s = ?%s", t.name, t.pk, t.live()), id).Scan(&one)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+// VersionOf returns the version of a row model (or a pointer to one), if
+// it has one.
+func VersionOf(row any) (int, bool) {
+	rv := reflect.ValueOf(row)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return 0, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return 0, false
+	}
+	v, ok := columnValue(rv.Interface(), "version").(int)
+	return v, ok
+}
+
+// columnValue reads the field of v whose json tag is column; models tag
+// every field with its column name.
+func columnValue[T any](v T, column string) any {
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		if strings.Split(rt.Field(i).Tag.Get("json"), ",")[0] != column {
+			continue
+		}
+		f := rv.Field(i)
+		if f.Kind() == reflect.Pointer {
+			if f.IsNil() {
+				return nil
+			}
+			f = f.Elem()
+		}
+		return f.Interface()
+	}
+	return nil
+}
+
+// where collects optional filter clauses; empty values are skipped.
+type where struct {
+	clauses []string
+	args    []any
+}
+
+func (w *where) eq(column, val string) {
+	if val != "" {
+		w.clauses = append(w.clauses, column+" = ?")
+		w.args = append(w.args, val)
+	}
+}
+
+func (w *where) like(column, val string) {
+	if val != "" {
+		w.clauses = append(w.clauses, column+" LIKE ?")
+		w.args = append(w.args, "%"+val+"%")
+	}
+}
+
+// add appends a clause as is.
+func (w *where) add(clause string, args ...any) {
+	w.clauses = append(w.clauses, clause)
+	w.args = append(w.args, args...)
+}
+
+// ─── Column types ───────────────────────────────────────────
+
+// timestamp scans SQLite's datetime('now') text (UTC) into a time.Time,
+// which encodes as RFC 3339 in JSON.
+type timestamp struct{ t *time.Time }
+
+func ts(t *time.Time) timestamp { return timestamp{t} }
+
+func (s timestamp) Scan(v any) error {
+	switch x := v.(type) {
+	case nil:
+		*s.t = time.Time{}
+		return nil
+	case time.Time:
+		*s.t = x.UTC()
+		return nil
+	case string:
+		return s.parse(x)
+	case []byte:
+		return s.parse(string(x))
+	}
+	return fmt.Errorf("timestamp: unsupported type %T", v)
+}
+
+func (s timestamp) parse(v string) error {
+	for _, layout := range []string{time.DateTime, time.RFC3339Nano} {
+		if t, err := time.ParseInLocation(layout, v, time.UTC); err == nil {
+			*s.t = t.UTC()
+			return nil
+		}
+	}
+	return fmt.Errorf("timestamp: cannot parse %q", v)
+}
+
+// boolean scans a nullable 0/1 INTEGER column; NULL reads as false.
+type boolean struct{ b *bool }
+
+func (s boolean) Scan(v any) error {
+	var n sql.NullInt64
+	if err := n.Scan(v); err != nil {
+		return err
+	}
+	*s.b = n.Valid && n.Int64 != 0
+	return nil
+}
+
+// Bool decodes JSON true/false and, for older clients, 0/1.
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", "1":
+		*b = true
+	case "false", "0":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean: %s", data)
+	}
+	return nil
+}
+
+// boolArg converts an optional Bool into a driver argument.
+func boolArg(b *Bool) any {
+	if b == nil {
+		return nil
+	}
+	if *b {
+		return 1
+	}
+	return 0
+}
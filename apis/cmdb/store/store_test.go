@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	migrations "github.com/jihaia/aperture/packages/migrations"
+	_ "modernc.org/sqlite"
+)
+
+var testDBs atomic.Int64
+
+// testDB returns a fresh, fully migrated in-memory database. It holds a
+// single connection, since each connection to :memory: is its own database.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:test%d?mode=memory&_pragma=foreign_keys(ON)", testDBs.Add(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrations.Output = io.Discard
+	if err := migrations.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// fixture is one branch of the hierarchy with a component linked to a
+// workload.
+type fixture struct {
+	Portfolio, Asset, AppGrouping, Application, Component, Workload string
+}
+
+func seed(t *testing.T, db *sql.DB) fixture {
+	t.Helper()
+	ctx := context.Background()
+	s := New(db)
+
+	var typeID, classID string
+	err := db.QueryRow(`SELECT component_type_id, component_class_id FROM component_types
+		WHERE component_class_id IS NOT NULL ORDER BY component_type_id LIMIT 1`).Scan(&typeID, &classID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Portfolio{Name: "Payments"}
+	must(t, s.Portfolios.Create(ctx, p))
+	a := &Asset{Name: "Ledger", PortfolioID: p.PortfolioID}
+	must(t, s.Assets.Create(ctx, a))
+	g := &AppGrouping{Name: "Core", AssetID: a.AssetID}
+	must(t, s.AppGroupings.Create(ctx, g))
+	app := &Application{Name: "ledger-api", AppGroupingID: g.AppGroupingID}
+	must(t, s.Applications.Create(ctx, app))
+	c := &Component{Name: ptr("api"), ApplicationID: app.ApplicationID, ComponentTypeID: &typeID, ComponentClassID: &classID}
+	must(t, s.Components.Create(ctx, c))
+	w := &Workload{Hostname: "ledger01.corp.example.com", IPAddress: ptr("10.0.0.1")}
+	must(t, s.Workloads.Create(ctx, w))
+	_, err = db.Exec("INSERT INTO component_workloads (component_id, workload_id) VALUES (?, ?)", c.ComponentID, w.WorkloadID)
+	must(t, err)
+
+	return fixture{
+		Portfolio:   p.PortfolioID,
+		Asset:       a.AssetID,
+		AppGrouping: g.AppGroupingID,
+		Application: app.ApplicationID,
+		Component:   c.ComponentID,
+		Workload:    w.WorkloadID,
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// count returns the number of rows in table matching cond.
+func count(t *testing.T, db *sql.DB, table, cond string, args ...any) int {
+	t.Helper()
+	var n int
+	must(t, db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", table, cond), args...).Scan(&n))
+	return n
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// tier is one level of the BIA hierarchy. Deleting a row of a tier moves
+// it and everything beneath it to the trash.
+type tier struct {
+	typ    string // entity type, as used by the API
+	table  string
+	pk     string
+	parent string // foreign key to the tier above; empty for portfolios
+}
+
+var tiers = []tier{
+	{"portfolio", "portfolios", "portfolio_id", ""},
+	{"asset", "assets", "asset_id", "portfolio_id"},
+	{"app_grouping", "app_groupings", "app_grouping_id", "asset_id"},
+	{"application", "applications", "application_id", "app_grouping_id"},
+	{"component", "components", "component_id", "application_id"},
+}
+
+// tierIndex returns the position of table in tiers, or -1 for tables
+// that are deleted outright.
+func tierIndex(table string) int {
+	for i, t := range tiers {
+		if t.table == table {
+			return i
+		}
+	}
+	return -1
+}
+
+func tierOf(typ string) (int, error) {
+	for i, t := range tiers {
+		if t.typ == typ {
+			return i, nil
+		}
+	}
+	return -1, &ValidationError{fmt.Sprintf("unknown type %q", typ)}
+}
+
+// live is the condition hiding trashed rows of t's table, or "" when the
+// table has no trash.
+func (t table[T]) live() string {
+	if tierIndex(t.name) < 0 {
+		return ""
+	}
+	return " AND deleted_at IS NULL"
+}
+
+// trash moves the row and everything beneath it to the trash under one
+// deletion id.
+func (t table[T]) trash(ctx context.Context, db DBTX, id string, version int) error {
+	i := tierIndex(t.name)
+	now := time.Now().UTC().Format(time.DateTime)
+	deletionID := newID()
+	return inTx(ctx, db, func(tx DBTX) error {
+		res, err := tx.ExecContext(ctx,
+			fmt.Sprintf("UPDATE %s SET deleted_at = ?, deletion_id = ? WHERE %s = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)", t.name, t.pk),
+			now, deletionID, id, version, version)
+		if err != nil {
+			return err
+		}
+		if err := t.matched(ctx, tx, res, id); err != nil {
+			return err
+		}
+		for j := i + 1; j < len(tiers); j++ {
+			above, child := tiers[j-1], tiers[j]
+			_, err := tx.ExecContext(ctx, fmt.Sprintf(
+				"UPDATE %s SET deleted_at = ?, deletion_id = ? WHERE deleted_at IS NULL AND %s IN (SELECT %s FROM %s WHERE deletion_id = ?)",
+				child.table, child.parent, above.pk, above.table), now, deletionID, deletionID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TrashEntry is a row someone deleted, with the number of rows beneath it
+// that went to the trash with it.
+type TrashEntry struct {
+	Type       string    `json:"type"`
+	ID         string    `json:"id"`
+	Name       *string   `json:"name"`
+	ParentID   *string   `json:"parent_id"`
+	DeletedAt  time.Time `json:"deleted_at"`
+	DeletionID string    `json:"deletion_id"`
+	Cascaded   int       `json:"cascaded"`
+}
+
+type TrashRepository interface {
+	// List returns the deleted rows, newest first, optionally of one type.
+	// Rows that went to the trash only because their parent did are
+	// counted in the parent's entry rather than listed.
+	List(ctx context.Context, typ string) ([]TrashEntry, error)
+	// Restore brings back the row and everything deleted with it, and
+	// returns how many rows were restored.
+	Restore(ctx context.Context, typ, id string) (int, error)
+	// Purge permanently deletes a row in the trash and everything beneath
+	// it.
+	Purge(ctx context.Context, typ, id string) error
+	PurgeImpact(ctx context.Context, typ, id string) (*Impact, error)
+}
+
+type trashRepo struct{ db DBTX }
+
+func (r *trashRepo) List(ctx context.Context, typ string) ([]TrashEntry, error) {
+	var selects []string
+	for i, t := range tiers {
+		if typ != "" && typ != t.typ {
+			continue
+		}
+		parent, root := "NULL", ""
+		if t.parent != "" {
+			above := tiers[i-1]
+			parent = "r." + t.parent
+			root = fmt.Sprintf(" AND NOT EXISTS (SELECT 1 FROM %s p WHERE p.%s = r.%s AND p.deletion_id = r.deletion_id)",
+				above.table, above.pk, t.parent)
+		}
+		cascaded := "0"
+		for j := i + 1; j < len(tiers); j++ {
+			cascaded += fmt.Sprintf(" + (SELECT count(*) FROM %s WHERE deletion_id = r.deletion_id)", tiers[j].table)
+		}
+		selects = append(selects, fmt.Sprintf(
+			"SELECT '%s', r.%s, r.name, %s, r.deleted_at, r.deletion_id, %s FROM %s r WHERE r.deleted_at IS NOT NULL%s",
+			t.typ, t.pk, parent, cascaded, t.table, root))
+	}
+	if len(selects) == 0 {
+		if _, err := tierOf(typ); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := r.db.QueryContext(ctx, strings.Join(selects, " UNION ALL ")+" ORDER BY 5 DESC, 1, 3")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []TrashEntry{}
+	for rows.Next() {
+		var e TrashEntry
+		if err := rows.Scan(&e.Type, &e.ID, &e.Name, &e.ParentID, ts(&e.DeletedAt), &e.DeletionID, &e.Cascaded); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// trashed returns the deletion id of a row in the trash.
+func trashed(ctx context.Context, db DBTX, t tier, id string) (string, error) {
+	var deletionID string
+	err := db.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT deletion_id FROM %s WHERE %s = ? AND deleted_at IS NOT NULL", t.table, t.pk), id).Scan(&deletionID)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return deletionID, err
+}
+
+// Restore works top down so each tier's parents are back before it is.
+// It fails with a foreign key ConstraintError while the row's parent is
+// still in the trash.
+func (r *trashRepo) Restore(ctx context.Context, typ, id string) (int, error) {
+	i, err := tierOf(typ)
+	if err != nil {
+		return 0, err
+	}
+	restored := 0
+	err = inTx(ctx, r.db, func(tx DBTX) error {
+		deletionID, err := trashed(ctx, tx, tiers[i], id)
+		if err != nil {
+			return err
+		}
+		t := tiers[i]
+		res, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE %s SET deleted_at = NULL, deletion_id = NULL WHERE %s = ?", t.table, t.pk), id)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		restored += int(n)
+		for j := i + 1; j < len(tiers); j++ {
+			above, child := tiers[j-1], tiers[j]
+			res, err := tx.ExecContext(ctx, fmt.Sprintf(
+				"UPDATE %s SET deleted_at = NULL, deletion_id = NULL WHERE deletion_id = ? AND %s IN (SELECT %s FROM %s WHERE deleted_at IS NULL)",
+				child.table, child.parent, above.pk, above.table), deletionID)
+			if err != nil {
+				return err
+			}
+			n, _ := res.RowsAffected()
+			restored += int(n)
+		}
+		return nil
+	})
+	if ce, ok := Classify(err).(*ConstraintError); ok {
+		ce.Table, ce.Fields = tiers[i].table, []string{tiers[i].parent}
+		return 0, ce
+	}
+	if err != nil {
+		return 0, err
+	}
+	return restored, nil
+}
+
+func (r *trashRepo) Purge(ctx context.Context, typ, id string) error {
+	i, err := tierOf(typ)
+	if err != nil {
+		return err
+	}
+	t := tiers[i]
+	res, err := r.db.ExecContext(ctx,
+		fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND deleted_at IS NOT NULL", t.table, t.pk), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *trashRepo) PurgeImpact(ctx context.Context, typ, id string) (*Impact, error) {
+	i, err := tierOf(typ)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := trashed(ctx, r.db, tiers[i], id); err != nil {
+		return nil, err
+	}
+	return subtreeImpact(ctx, r.db, i, id, false)
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTrashCascade(t *testing.T) {
+	tests := []struct {
+		typ     string
+		id      func(fixture) string
+		trashed int // rows that go, the target included
+	}{
+		{"portfolio", func(f fixture) string { return f.Portfolio }, 5},
+		{"asset", func(f fixture) string { return f.Asset }, 4},
+		{"app_grouping", func(f fixture) string { return f.AppGrouping }, 3},
+		{"application", func(f fixture) string { return f.Application }, 2},
+		{"component", func(f fixture) string { return f.Component }, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			db := testDB(t)
+			f := seed(t, db)
+			ctx := context.Background()
+			s := New(db)
+			i, _ := tierOf(tt.typ)
+			id := tt.id(f)
+
+			must(t, deleteTier(ctx, s, tt.typ, id))
+			if got := trashedRows(t, db); got != tt.trashed {
+				t.Errorf("%d rows in the trash, want %d", got, tt.trashed)
+			}
+			if _, err := s.Components.Get(ctx, f.Component); !errors.Is(err, ErrNotFound) {
+				t.Errorf("component still visible: %v", err)
+			}
+			list, err := s.Components.List(ctx, ComponentFilter{})
+			must(t, err)
+			if list.Total != 0 {
+				t.Errorf("component list has %d rows", list.Total)
+			}
+			// The link survives in the trash, to come back on restore.
+			if n := count(t, db, "component_workloads", "component_id = ?", f.Component); n != 1 {
+				t.Errorf("%d links, want 1", n)
+			}
+
+			entries, err := s.Trash.List(ctx, "")
+			must(t, err)
+			if len(entries) != 1 || entries[0].ID != id || entries[0].Cascaded != tt.trashed-1 {
+				t.Fatalf("trash = %+v, want one %s entry cascading %d", entries, tt.typ, tt.trashed-1)
+			}
+			if tiers[i].parent != "" && entries[0].ParentID == nil {
+				t.Error("entry has no parent_id")
+			}
+
+			n, err := s.Trash.Restore(ctx, tt.typ, id)
+			must(t, err)
+			if n != tt.trashed || trashedRows(t, db) != 0 {
+				t.Errorf("restored %d, %d left; want %d, 0", n, trashedRows(t, db), tt.trashed)
+			}
+			if _, err := s.Components.Get(ctx, f.Component); err != nil {
+				t.Errorf("component not restored: %v", err)
+			}
+			if _, err := s.Trash.Restore(ctx, tt.typ, id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("second restore: err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+// TestRestoreKeepsSeparateDeletions checks a restore brings back only the
+// rows that went with it, not children deleted on their own earlier.
+func TestRestoreKeepsSeparateDeletions(t *testing.T) {
+	db := testDB(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	s := New(db)
+
+	must(t, s.Components.Delete(ctx, f.Component, 0))
+	must(t, s.Applications.Delete(ctx, f.Application, 0))
+	entries, err := s.Trash.List(ctx, "")
+	must(t, err)
+	if len(entries) != 2 {
+		t.Fatalf("trash has %d entries, want 2", len(entries))
+	}
+
+	// The component cannot come back while its application is away.
+	_, err = s.Trash.Restore(ctx, "component", f.Component)
+	var ce *ConstraintError
+	if !errors.As(err, &ce) || ce.Constraint != ConstraintForeignKey || ce.Fields[0] != "application_id" {
+		t.Fatalf("restore under a trashed parent: err = %v, want foreign key on application_id", err)
+	}
+
+	n, err := s.Trash.Restore(ctx, "application", f.Application)
+	must(t, err)
+	if n != 1 {
+		t.Errorf("restored %d rows, want only the application", n)
+	}
+	n, err = s.Trash.Restore(ctx, "component", f.Component)
+	must(t, err)
+	if n != 1 {
+		t.Errorf("restored %d rows, want 1", n)
+	}
+}
+
+func TestTrashedParentRejectsChildren(t *testing.T) {
+	db := testDB(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	s := New(db)
+	must(t, s.Applications.Delete(ctx, f.Application, 0))
+
+	err := s.Components.Create(ctx, &Component{Name: ptr("new"), ApplicationID: f.Application})
+	var ce *ConstraintError
+	if !errors.As(err, &ce) || ce.Constraint != ConstraintForeignKey {
+		t.Errorf("create under a trashed parent: err = %v, want foreign key", err)
+	}
+	_, err = s.Components.Update(ctx, f.Component, 0, ComponentUpdate{Description: ptr("x")})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("update of a trashed row: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	db := testDB(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	s := New(db)
+
+	if err := s.Trash.Purge(ctx, "application", f.Application); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("purge of a live row: err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Trash.PurgeImpact(ctx, "application", f.Application); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("purge preview of a live row: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Trash.Purge(ctx, "nope", f.Application); err == nil {
+		t.Fatal("purge of an unknown type succeeded")
+	}
+
+	must(t, s.Applications.Delete(ctx, f.Application, 0))
+	must(t, s.Trash.Purge(ctx, "application", f.Application))
+	if n := count(t, db, "applications", "application_id = ?", f.Application) +
+		count(t, db, "components", "component_id = ?", f.Component) +
+		count(t, db, "component_workloads", "component_id = ?", f.Component); n != 0 {
+		t.Errorf("%d rows left after purge", n)
+	}
+	if n := count(t, db, "workloads", "workload_id = ?", f.Workload); n != 1 {
+		t.Error("purge removed the workload")
+	}
+}
+
+func deleteTier(ctx context.Context, s *Store, typ, id string) error {
+	switch typ {
+	case "portfolio":
+		return s.Portfolios.Delete(ctx, id, 0)
+	case "asset":
+		return s.Assets.Delete(ctx, id, 0)
+	case "app_grouping":
+		return s.AppGroupings.Delete(ctx, id, 0)
+	case "application":
+		return s.Applications.Delete(ctx, id, 0)
+	}
+	return s.Components.Delete(ctx, id, 0)
+}
+
+func trashedRows(t *testing.T, db DBTX) int {
+	t.Helper()
+	n := 0
+	for _, tier := range tiers {
+		var c int
+		must(t, db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM "+tier.table+" WHERE deleted_at IS NOT NULL").Scan(&c))
+		n += c
+	}
+	return n
+}
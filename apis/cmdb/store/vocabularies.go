@@ -0,0 +1,367 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Vocabulary names.
+const (
+	VocabCriticality = "criticality"
+	VocabEnvironment = "environment"
+	VocabLocation    = "location"
+)
+
+// Vocabularies lists the managed vocabularies.
+var Vocabularies = []string{VocabCriticality, VocabEnvironment, VocabLocation}
+
+// Term is one allowed value of a vocabulary. Writes that use the value or
+// any alias, in any case, are stored as Value.
+type Term struct {
+	Vocabulary string    `json:"vocabulary"`
+	Value      string    `json:"value"`
+	Label      *string   `json:"label"`
+	Color      *string   `json:"color"`
+	SortOrder  int       `json:"sort_order"`
+	Aliases    []string  `json:"aliases"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TermUpdate holds the fields to change; nil fields are left as is.
+// Aliases, when present, replaces the term's aliases.
+type TermUpdate struct {
+	Label     *string  `json:"label"`
+	Color     *string  `json:"color"`
+	SortOrder *int     `json:"sort_order"`
+	Aliases   []string `json:"aliases"`
+}
+
+type VocabularyRepository interface {
+	List(ctx context.Context, vocabulary string) ([]Term, error)
+	Get(ctx context.Context, vocabulary, value string) (*Term, error)
+	Create(ctx context.Context, t *Term) error
+	Update(ctx context.Context, vocabulary, value string, u TermUpdate) (*Term, error)
+	Delete(ctx context.Context, vocabulary, value string) error
+}
+
+// VocabularyError is returned when a write uses a value that is neither a
+// term nor an alias of the column's vocabulary.
+type VocabularyError struct {
+	Vocabulary string
+	Field      string
+	Value      string
+}
+
+func (e *VocabularyError) Error() string {
+	return fmt.Sprintf("%q is not a known %s; add it or an alias under /vocabularies/%s", e.Value, e.Vocabulary, e.Vocabulary)
+}
+
+func knownVocabulary(vocabulary string) error {
+	for _, v := range Vocabularies {
+		if v == vocabulary {
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+const termColumns = "vocabulary, value, label, color, sort_order, created_at, updated_at"
+
+func scanTerm(s scanner) (*Term, error) {
+	var t Term
+	err := s.Scan(&t.Vocabulary, &t.Value, &t.Label, &t.Color, &t.SortOrder, ts(&t.CreatedAt), ts(&t.UpdatedAt))
+	return &t, err
+}
+
+type vocabularyRepo struct{ db DBTX }
+
+func (r *vocabularyRepo) List(ctx context.Context, vocabulary string) ([]Term, error) {
+	if err := knownVocabulary(vocabulary); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+termColumns+" FROM vocabulary_terms WHERE vocabulary = ? ORDER BY sort_order, value", vocabulary)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	terms := []Term{}
+	for rows.Next() {
+		t, err := scanTerm(rows)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return terms, r.loadAliases(ctx, vocabulary, terms)
+}
+
+func (r *vocabularyRepo) Get(ctx context.Context, vocabulary, value string) (*Term, error) {
+	row := r.db.QueryRowContext(ctx,
+		"SELECT "+termColumns+" FROM vocabulary_terms WHERE vocabulary = ? AND value = ?", vocabulary, value)
+	t, err := scanTerm(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	terms := []Term{*t}
+	if err := r.loadAliases(ctx, vocabulary, terms); err != nil {
+		return nil, err
+	}
+	return &terms[0], nil
+}
+
+// loadAliases fills in Aliases on each term of one vocabulary.
+func (r *vocabularyRepo) loadAliases(ctx context.Context, vocabulary string, terms []Term) error {
+	byValue := make(map[string]*Term, len(terms))
+	for i := range terms {
+		terms[i].Aliases = []string{}
+		byValue[strings.ToLower(terms[i].Value)] = &terms[i]
+	}
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT alias, value FROM vocabulary_aliases WHERE vocabulary = ? ORDER BY alias", vocabulary)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alias, value string
+		if err := rows.Scan(&alias, &value); err != nil {
+			return err
+		}
+		if t := byValue[strings.ToLower(value)]; t != nil {
+			t.Aliases = append(t.Aliases, alias)
+		}
+	}
+	return rows.Err()
+}
+
+func (r *vocabularyRepo) Create(ctx context.Context, t *Term) error {
+	if err := knownVocabulary(t.Vocabulary); err != nil {
+		return err
+	}
+	t.Value = strings.TrimSpace(t.Value)
+	if t.Value == "" {
+		return &ValidationError{"value is required"}
+	}
+	err := inTx(ctx, r.db, func(tx DBTX) error {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO vocabulary_terms (vocabulary, value, label, color, sort_order) VALUES (?, ?, ?, ?, ?)",
+			t.Vocabulary, t.Value, t.Label, t.Color, t.SortOrder)
+		if err != nil {
+			return err
+		}
+		return replaceAliases(ctx, tx, t.Vocabulary, t.Value, t.Aliases)
+	})
+	if ce, ok := Classify(err).(*ConstraintError); ok && ce.Table == "vocabulary_terms" {
+		ce.Fields = []string{"value"}
+		return ce
+	}
+	if err != nil {
+		return Classify(err)
+	}
+	saved, err := r.Get(ctx, t.Vocabulary, t.Value)
+	if err != nil {
+		return err
+	}
+	*t = *saved
+	return nil
+}
+
+func (r *vocabularyRepo) Update(ctx context.Context, vocabulary, value string, u TermUpdate) (*Term, error) {
+	err := inTx(ctx, r.db, func(tx DBTX) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE vocabulary_terms SET label=COALESCE(?,label), color=COALESCE(?,color), sort_order=COALESCE(?,sort_order),
+			 updated_at=datetime('now') WHERE vocabulary=? AND value=?`,
+			u.Label, u.Color, u.SortOrder, vocabulary, value)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		if u.Aliases == nil {
+			return nil
+		}
+		return replaceAliases(ctx, tx, vocabulary, value, u.Aliases)
+	})
+	if err != nil {
+		return nil, Classify(err)
+	}
+	return r.Get(ctx, vocabulary, value)
+}
+
+// Delete removes a term and its aliases. Rows already using the value
+// keep it, but new writes of it are rejected.
+func (r *vocabularyRepo) Delete(ctx context.Context, vocabulary, value string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM vocabulary_terms WHERE vocabulary = ? AND value = ?", vocabulary, value)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// replaceAliases sets a term's aliases. An alias may not be another
+// term's value; one already used by another term fails as a duplicate.
+func replaceAliases(ctx context.Context, tx DBTX, vocabulary, value string, aliases []string) error {
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM vocabulary_aliases WHERE vocabulary = ? AND value = ?", vocabulary, value); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || strings.EqualFold(alias, value) || seen[strings.ToLower(alias)] {
+			continue
+		}
+		seen[strings.ToLower(alias)] = true
+		var other string
+		err := tx.QueryRowContext(ctx,
+			"SELECT value FROM vocabulary_terms WHERE vocabulary = ? AND value = ?", vocabulary, alias).Scan(&other)
+		if err == nil {
+			return &ValidationError{fmt.Sprintf("alias %q is already the value of another term", alias)}
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO vocabulary_aliases (vocabulary, alias, value) VALUES (?, ?, ?)", vocabulary, alias, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ─── Normalization ──────────────────────────────────────────
+
+// vocabCache holds each vocabulary's lookup, lower-cased value or alias →
+// value, loaded on first use. One cache serves a whole request, so a bulk
+// upsert reads each vocabulary once.
+type vocabCache struct {
+	terms map[string]map[string]string
+	// open lists the vocabularies in which an unknown value becomes a new
+	// term instead of being rejected.
+	open map[string]bool
+}
+
+// importVocabs is the open set for imports. Locations are the source
+// systems' own site names, so an import may bring in new ones; the other
+// vocabularies stay closed and unknown values fail the row.
+var importVocabs = map[string]bool{VocabLocation: true}
+
+func (vc *vocabCache) lookup(ctx context.Context, db DBTX, vocabulary string) (map[string]string, error) {
+	if m, ok := vc.terms[vocabulary]; ok {
+		return m, nil
+	}
+	rows, err := db.QueryContext(ctx,
+		`SELECT value, value FROM vocabulary_terms WHERE vocabulary = ?
+		 UNION ALL SELECT alias, value FROM vocabulary_aliases WHERE vocabulary = ?`, vocabulary, vocabulary)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	m := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		// Values win over aliases.
+		if _, taken := m[strings.ToLower(key)]; !taken || key == value {
+			m[strings.ToLower(key)] = value
+		}
+	}
+	if vc.terms == nil {
+		vc.terms = map[string]map[string]string{}
+	}
+	vc.terms[vocabulary] = m
+	return m, rows.Err()
+}
+
+// canonical returns the stored form of raw for a column of the given
+// vocabulary, or nil for a blank value.
+func (vc *vocabCache) canonical(ctx context.Context, db DBTX, vocabulary, field, raw string) (*string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	m, err := vc.lookup(ctx, db, vocabulary)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := m[strings.ToLower(raw)]
+	if ok {
+		return &value, nil
+	}
+	if !vc.open[vocabulary] {
+		return nil, &VocabularyError{Vocabulary: vocabulary, Field: field, Value: raw}
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO vocabulary_terms (vocabulary, value) VALUES (?, ?)", vocabulary, raw); err != nil {
+		return nil, err
+	}
+	m[strings.ToLower(raw)] = raw
+	return &raw, nil
+}
+
+// Canonicalizer normalizes vocabulary columns for writers outside the
+// store, such as the ServiceNow importer, reading each vocabulary once.
+type Canonicalizer struct {
+	db DBTX
+	vc vocabCache
+}
+
+func NewCanonicalizer(db DBTX) *Canonicalizer {
+	return &Canonicalizer{db: db}
+}
+
+// Canonical returns the stored form of raw for table.column: raw as is
+// for a column without a vocabulary, nil for a blank value and a
+// *VocabularyError for an unknown one. A workload's ip_address is put in
+// canonical form too.
+func (c *Canonicalizer) Canonical(ctx context.Context, table, column string, raw *string) (*string, error) {
+	if table == workloads.name && column == "ip_address" {
+		return normalizedIP(raw), nil
+	}
+	vocabulary, ok := tablesByName[table].vocab[column]
+	if !ok || raw == nil {
+		return raw, nil
+	}
+	return c.vc.canonical(ctx, c.db, vocabulary, column, *raw)
+}
+
+// canonicalize normalizes, in place, the vocabulary columns of v, a
+// pointer to t's model or update struct.
+func (t table[T]) canonicalize(ctx context.Context, db DBTX, vc *vocabCache, v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		name := jsonName(rv.Type().Field(i))
+		vocabulary, ok := t.vocab[name]
+		if !ok {
+			continue
+		}
+		f, ok := rv.Field(i).Interface().(*string)
+		if !ok || f == nil {
+			continue
+		}
+		value, err := vc.canonical(ctx, db, vocabulary, name, *f)
+		if err != nil {
+			return err
+		}
+		rv.Field(i).Set(reflect.ValueOf(value))
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	migrations "github.com/jihaia/aperture/packages/migrations"
+)
+
+func TestCanonicalizeAliases(t *testing.T) {
+	db := testDB(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	s := New(db)
+
+	tests := []struct {
+		criticality, environment string
+		want                     [2]*string // criticality, environment
+		err                      string     // field of the VocabularyError
+	}{
+		{criticality: "critical", environment: "production", want: [2]*string{ptr("critical"), ptr("production")}},
+		{criticality: "1 - most critical", environment: "Prod", want: [2]*string{ptr("critical"), ptr("production")}},
+		{criticality: " HIGH ", environment: "uat", want: [2]*string{ptr("high"), ptr("staging")}},
+		{criticality: "3", environment: "DR", want: [2]*string{ptr("medium"), ptr("disaster recovery")}},
+		{criticality: "  ", environment: "", want: [2]*string{nil, nil}},
+		{criticality: "severe", environment: "prod", err: "criticality"},
+		{criticality: "low", environment: "sandbox", err: "environment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.criticality+"/"+tt.environment, func(t *testing.T) {
+			a := &Asset{Name: t.Name(), PortfolioID: f.Portfolio, Criticality: &tt.criticality, Environment: &tt.environment}
+			err := s.Assets.Create(ctx, a)
+			if tt.err != "" {
+				var ve *VocabularyError
+				if !errors.As(err, &ve) || ve.Field != tt.err {
+					t.Fatalf("err = %v, want VocabularyError on %s", err, tt.err)
+				}
+				return
+			}
+			must(t, err)
+			got, err := s.Assets.Get(ctx, a.AssetID)
+			must(t, err)
+			if !equalPtr(got.Criticality, tt.want[0]) || !equalPtr(got.Environment, tt.want[1]) {
+				t.Errorf("stored %v/%v, want %v/%v", str(got.Criticality), str(got.Environment), str(tt.want[0]), str(tt.want[1]))
+			}
+		})
+	}
+}
+
+func TestTermAliases(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	s := New(db)
+
+	must(t, s.Vocabularies.Create(ctx, &Term{Vocabulary: VocabLocation, Value: "us-east", Aliases: []string{"USE1", "virginia", "us-east"}}))
+	term, err := s.Vocabularies.Get(ctx, VocabLocation, "us-east")
+	must(t, err)
+	if strings.Join(term.Aliases, ",") != "USE1,virginia" {
+		t.Errorf("aliases = %v, want the term's own value dropped", term.Aliases)
+	}
+
+	// An alias may not be another term's value.
+	err = s.Vocabularies.Create(ctx, &Term{Vocabulary: VocabLocation, Value: "us-west", Aliases: []string{"US-EAST"}})
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Errorf("alias naming another term: err = %v, want ValidationError", err)
+	}
+
+	w := &Workload{Hostname: "h1", Location: ptr("Virginia")}
+	must(t, s.Workloads.Create(ctx, w))
+	if got := str(w.Location); got != "us-east" {
+		t.Errorf("location = %q, want us-east", got)
+	}
+
+	// Deleting the term removes its aliases; the value is unknown again.
+	must(t, s.Vocabularies.Delete(ctx, VocabLocation, "us-east"))
+	if n := count(t, db, "vocabulary_aliases", "vocabulary = 'location'"); n != 0 {
+		t.Errorf("%d aliases left after delete", n)
+	}
+	err = s.Workloads.Create(ctx, &Workload{Hostname: "h2", Location: ptr("virginia")})
+	var vocabErr *VocabularyError
+	if !errors.As(err, &vocabErr) {
+		t.Errorf("err = %v, want VocabularyError", err)
+	}
+}
+
+// TestVocabularyBackfill rolls back to before the vocabularies, writes the
+// kind of values that drifted, and checks 010 normalizes them.
+func TestVocabularyBackfill(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	applied, err := migrations.Status(db)
+	must(t, err)
+	var after int
+	for _, m := range applied {
+		if m.Name > "010" {
+			after++
+		}
+	}
+	_, err = migrations.Down(db, after)
+	must(t, err)
+	if n := count(t, db, "sqlite_master", "name = 'vocabulary_terms'"); n != 0 {
+		t.Fatal("vocabulary_terms survived the rollback")
+	}
+
+	_, err = db.Exec(`INSERT INTO portfolios (portfolio_id, name) VALUES ('p', 'P');
+		INSERT INTO assets (asset_id, name, portfolio_id, criticality, environment) VALUES
+		  ('a1', 'one', 'p', '1 - most critical', 'Prod'),
+		  ('a2', 'two', 'p', 'High', ' qa '),
+		  ('a3', 'three', 'p', 'Extreme', '');
+		INSERT INTO workloads (workload_id, hostname, environment, location) VALUES
+		  ('w1', 'h1', 'DEV', 'London'),
+		  ('w2', 'h2', 'dev', 'london')`)
+	must(t, err)
+	must(t, migrations.Run(db))
+
+	s := New(db)
+	tests := []struct {
+		id, criticality, environment string
+	}{
+		{"a1", "critical", "production"},
+		{"a2", "high", "test"},
+		{"a3", "Extreme", "<nil>"},
+	}
+	for _, tt := range tests {
+		a, err := s.Assets.Get(ctx, tt.id)
+		must(t, err)
+		if str(a.Criticality) != tt.criticality || str(a.Environment) != tt.environment {
+			t.Errorf("%s: %s/%s, want %s/%s", tt.id, str(a.Criticality), str(a.Environment), tt.criticality, tt.environment)
+		}
+	}
+	// Unknown values in use became terms; case variants share one.
+	if _, err := s.Vocabularies.Get(ctx, VocabCriticality, "extreme"); err != nil {
+		t.Errorf("Extreme was not adopted as a term: %v", err)
+	}
+	if n := count(t, db, "vocabulary_terms", "vocabulary = 'location'"); n != 1 {
+		t.Errorf("%d location terms, want 1", n)
+	}
+	if n := count(t, db, "workloads", "location = 'London' AND environment = 'development'"); n != 2 {
+		t.Errorf("%d workloads normalized, want 2", n)
+	}
+}
+
+func TestUpsertAdoptsLocations(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	s := New(db)
+
+	res, err := s.Workloads.Upsert(ctx, []WorkloadUpdate{
+		{Hostname: ptr("h1"), Location: ptr("us-east"), Environment: ptr("prod")},
+		{Hostname: ptr("h2"), Location: ptr("US-EAST")},
+		{Hostname: ptr("h3"), Environment: ptr("sandbox")},
+		{Location: ptr("eu-west")},
+		{Hostname: ptr("h4"), Interfaces: []Interface{{Address: "not-an-ip"}}},
+	})
+	must(t, err)
+	if res.Created != 2 || res.Errors != 3 {
+		t.Fatalf("created %d, errors %d; want 2, 3", res.Created, res.Errors)
+	}
+	wantFailures := map[int]string{2: "sandbox", 3: "hostname", 4: "not-an-ip"}
+	for _, f := range res.Failures {
+		if !strings.Contains(f.Error, wantFailures[f.Index]) {
+			t.Errorf("failure %d: %q, want it to mention %q", f.Index, f.Error, wantFailures[f.Index])
+		}
+		delete(wantFailures, f.Index)
+	}
+	if len(wantFailures) != 0 {
+		t.Errorf("no failure reported for rows %v", wantFailures)
+	}
+
+	if n := count(t, db, "vocabulary_terms", "vocabulary = 'location'"); n != 1 {
+		t.Errorf("%d location terms, want 1", n)
+	}
+	if n := count(t, db, "workloads", "location = 'us-east'"); n != 2 {
+		t.Errorf("%d workloads at us-east, want 2", n)
+	}
+	// Only imports open the vocabulary; API writes still reject.
+	err = s.Workloads.Create(ctx, &Workload{Hostname: "h5", Location: ptr("eu-west")})
+	var ve *VocabularyError
+	if !errors.As(err, &ve) {
+		t.Errorf("create with an unknown location: err = %v, want VocabularyError", err)
+	}
+}
+
+func TestCanonicalizer(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	c := NewCanonicalizer(db)
+
+	tests := []struct {
+		table, column string
+		raw           *string
+		want          *string
+		err           bool
+	}{
+		{"assets", "criticality", ptr("2 - somewhat critical"), ptr("high"), false},
+		{"assets", "environment", ptr("Live"), ptr("production"), false},
+		{"assets", "environment", ptr("moon"), nil, true},
+		{"assets", "name", ptr("2 - somewhat critical"), ptr("2 - somewhat critical"), false},
+		{"assets", "criticality", nil, nil, false},
+		{"portfolios", "state", ptr("x"), ptr("x"), false},
+	}
+	for _, tt := range tests {
+		got, err := c.Canonical(ctx, tt.table, tt.column, tt.raw)
+		if (err != nil) != tt.err {
+			t.Errorf("%s.%s %v: err = %v", tt.table, tt.column, str(tt.raw), err)
+			continue
+		}
+		if !tt.err && !equalPtr(got, tt.want) {
+			t.Errorf("%s.%s %v = %v, want %v", tt.table, tt.column, str(tt.raw), str(got), str(tt.want))
+		}
+	}
+}
+
+func equalPtr(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func str(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
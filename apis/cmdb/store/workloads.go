@@ -0,0 +1,462 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/jihaia/aperture/apis/cmdb/listing"
+)
+
+// Workload is a server, linked to components through component_workloads.
+type Workload struct {
+	WorkloadID  string    `json:"workload_id"`
+	Hostname    string    `json:"hostname"`
+	SnowSysID   *string   `json:"snow_sys_id"`
+	IPAddress   *string   `json:"ip_address"`
+	FQDN        *string   `json:"fqdn"`
+	OS          *string   `json:"os"`
+	Environment *string   `json:"environment"`
+	Location    *string   `json:"location"`
+	ClassType   *string   `json:"class_type"`
+	IsVirtual   bool      `json:"is_virtual"`
+	Description *string   `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int       `json:"version"`
+	// Interfaces lists every address of the workload besides ip_address.
+	Interfaces []Interface `json:"interfaces"`
+}
+
+// WorkloadUpdate holds the fields to change; nil fields are left as is.
+// It is also one row of a bulk upsert, keyed by hostname.
+type WorkloadUpdate struct {
+	Hostname    *string `json:"hostname"`
+	SnowSysID   *string `json:"snow_sys_id"`
+	IPAddress   *string `json:"ip_address"`
+	FQDN        *string `json:"fqdn"`
+	OS          *string `json:"os"`
+	Environment *string `json:"environment"`
+	Location    *string `json:"location"`
+	ClassType   *string `json:"class_type"`
+	IsVirtual   *Bool   `json:"is_virtual"`
+	Description *string `json:"description"`
+	// Interfaces, when present, replaces the workload's interfaces.
+	Interfaces []Interface `json:"interfaces"`
+}
+
+type WorkloadFilter struct {
+	Q         string
+	IPAddress string
+	listing.Page
+	listing.Filter
+}
+
+// UpsertResult reports what a bulk upsert did. Failures says why each
+// of the Errors rows was skipped; Changes holds an audit entry for each
+// row created or changed, for the caller to record.
+type UpsertResult struct {
+	Created  int             `json:"created"`
+	Updated  int             `json:"updated"`
+	Errors   int             `json:"errors"`
+	Total    int             `json:"total"`
+	Failures []UpsertFailure `json:"failures"`
+	Changes  []*AuditEntry   `json:"-"`
+}
+
+// UpsertFailure is one row a bulk upsert skipped; Index is its position
+// in the input.
+type UpsertFailure struct {
+	Index    int     `json:"index"`
+	Hostname *string `json:"hostname"`
+	Error    string  `json:"error"`
+}
+
+func (r *UpsertResult) fail(i int, hostname *string, err error) {
+	r.Errors++
+	r.Failures = append(r.Failures, UpsertFailure{Index: i, Hostname: hostname, Error: err.Error()})
+}
+
+type WorkloadRepository interface {
+	List(ctx context.Context, f WorkloadFilter) (*listing.Result[Workload], error)
+	ListByComponent(ctx context.Context, componentID string) ([]Workload, error)
+	ListUnder(ctx context.Context, scope Scope, id string, f WorkloadFilter) (*listing.Result[LinkedWorkload], error)
+	Get(ctx context.Context, id string) (*Workload, error)
+	GetByHostname(ctx context.Context, hostname string) (*Workload, error)
+	GetByIP(ctx context.Context, ip string) (*Workload, error)
+	Lookup(ctx context.Context, identifiers []string) (*LookupResult, error)
+	Create(ctx context.Context, w *Workload) error
+	Update(ctx context.Context, id string, version int, u WorkloadUpdate) (*Workload, error)
+	Patch(ctx context.Context, id string, version int, p Patch) (*Workload, error)
+	Upsert(ctx context.Context, rows []WorkloadUpdate) (*UpsertResult, error)
+	Delete(ctx context.Context, id string, version int) error
+	DeleteImpact(ctx context.Context, id string, version int) (*Impact, error)
+}
+
+const workloadColumns = "workload_id, hostname, snow_sys_id, ip_address, fqdn, os, environment, location, " +
+	"class_type, is_virtual, description, created_at, updated_at, version"
+
+var workloads = table[Workload]{
+	name:    "workloads",
+	pk:      "workload_id",
+	columns: workloadColumns,
+	order:   []listing.Order{{Column: "hostname"}},
+	scan: func(s scanner) (*Workload, error) {
+		var w Workload
+		err := s.Scan(&w.WorkloadID, &w.Hostname, &w.SnowSysID, &w.IPAddress, &w.FQDN, &w.OS,
+			&w.Environment, &w.Location, &w.ClassType, boolean{&w.IsVirtual}, &w.Description,
+			ts(&w.CreatedAt), ts(&w.UpdatedAt), &w.Version)
+		return &w, err
+	},
+	vocab: map[string]string{"environment": VocabEnvironment, "location": VocabLocation},
+}
+
+type workloadRepo struct{ db DBTX }
+
+func (r *workloadRepo) List(ctx context.Context, f WorkloadFilter) (*listing.Result[Workload], error) {
+	list, err := workloads.list(ctx, r.db, r.filter(f), f.Page, f.Filter)
+	if err != nil {
+		return nil, err
+	}
+	return list, loadInterfaces(ctx, r.db, list.Items)
+}
+
+func (r *workloadRepo) filter(f WorkloadFilter) where {
+	var w where
+	w.like("hostname", f.Q)
+	if f.IPAddress != "" {
+		w.add(ipMatch, ipArgs(f.IPAddress)...)
+	}
+	return w
+}
+
+func (r *workloadRepo) ListByComponent(ctx context.Context, componentID string) ([]Workload, error) {
+	list, err := workloads.query(ctx, r.db,
+		`SELECT `+prefixed("w", workloadColumns)+`
+		 FROM workloads w
+		 JOIN component_workloads cw ON cw.workload_id = w.workload_id
+		 JOIN components c ON c.component_id = cw.component_id AND c.deleted_at IS NULL
+		 WHERE cw.component_id = ?
+		 ORDER BY w.hostname`, componentID)
+	if err != nil {
+		return nil, err
+	}
+	return list, loadInterfaces(ctx, r.db, list)
+}
+
+func (r *workloadRepo) Get(ctx context.Context, id string) (*Workload, error) {
+	return r.first(ctx, "workload_id = ?", id)
+}
+
+func (r *workloadRepo) GetByHostname(ctx context.Context, hostname string) (*Workload, error) {
+	return r.first(ctx, "hostname = ?", hostname)
+}
+
+// GetByIP finds a workload by its primary address or any interface.
+func (r *workloadRepo) GetByIP(ctx context.Context, ip string) (*Workload, error) {
+	return r.first(ctx, ipMatch, ipArgs(ip)...)
+}
+
+func (r *workloadRepo) first(ctx context.Context, cond string, args ...any) (*Workload, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+workloadColumns+" FROM workloads WHERE "+cond+" LIMIT 1", args...)
+	w, err := workloads.scan(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := []Workload{*w}
+	if err := loadInterfaces(ctx, r.db, list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// Create inserts w with its interfaces. ip_address is stored in canonical
+// form, as every write path stores it; when unset it is taken from the
+// first interface, preferring IPv4.
+func (r *workloadRepo) Create(ctx context.Context, w *Workload) error {
+	if w.WorkloadID == "" {
+		w.WorkloadID = newID()
+	}
+	ifaces, err := normalizeInterfaces(w.Interfaces)
+	if err != nil {
+		return err
+	}
+	w.IPAddress = normalizedIP(w.IPAddress)
+	if w.IPAddress == nil {
+		w.IPAddress = primaryAddress(ifaces)
+	}
+	if err := workloads.canonicalize(ctx, r.db, &vocabCache{}, w); err != nil {
+		return err
+	}
+	err = inTx(ctx, r.db, func(tx DBTX) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO workloads (workload_id, hostname, snow_sys_id, ip_address, fqdn, os, environment, location, class_type, is_virtual, description)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			w.WorkloadID, w.Hostname, w.SnowSysID, w.IPAddress, w.FQDN, w.OS,
+			w.Environment, w.Location, w.ClassType, w.IsVirtual, w.Description)
+		if err != nil {
+			return err
+		}
+		return replaceInterfaces(ctx, tx, w.WorkloadID, ifaces)
+	})
+	if err != nil {
+		return workloads.explain(ctx, r.db, err, "", fields(w))
+	}
+	saved, err := r.Get(ctx, w.WorkloadID)
+	if err != nil {
+		return err
+	}
+	*w = *saved
+	return nil
+}
+
+func (r *workloadRepo) Update(ctx context.Context, id string, version int, u WorkloadUpdate) (*Workload, error) {
+	var ifaces []Interface
+	if u.Interfaces != nil {
+		var err error
+		if ifaces, err = normalizeInterfaces(u.Interfaces); err != nil {
+			return nil, err
+		}
+	}
+	u.IPAddress = normalizedIP(u.IPAddress)
+	if err := workloads.canonicalize(ctx, r.db, &vocabCache{}, &u); err != nil {
+		return nil, err
+	}
+	err := inTx(ctx, r.db, func(tx DBTX) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE workloads SET
+				hostname=COALESCE(?,hostname), snow_sys_id=COALESCE(?,snow_sys_id),
+				ip_address=COALESCE(?,ip_address), fqdn=COALESCE(?,fqdn),
+				os=COALESCE(?,os), environment=COALESCE(?,environment),
+				location=COALESCE(?,location),
+				class_type=COALESCE(?,class_type), is_virtual=COALESCE(?,is_virtual),
+				description=COALESCE(?,description), updated_at=datetime('now')
+			 WHERE workload_id=? AND (?=0 OR version=?)`,
+			u.Hostname, u.SnowSysID, u.IPAddress, u.FQDN,
+			u.OS, u.Environment, u.Location, u.ClassType, boolArg(u.IsVirtual), u.Description, id, version, version)
+		if err != nil {
+			return err
+		}
+		if err := workloads.matched(ctx, tx, res, id); err != nil {
+			return err
+		}
+		if u.Interfaces == nil {
+			return nil
+		}
+		return replaceInterfaces(ctx, tx, id, ifaces)
+	})
+	if err != nil {
+		return nil, workloads.explain(ctx, r.db, err, id, fields(u))
+	}
+	return r.Get(ctx, id)
+}
+
+// primaryAddress picks the address to record as ip_address: the first
+// IPv4 interface, else the first interface.
+func primaryAddress(ifaces []Interface) *string {
+	for _, in := range ifaces {
+		if in.Family == "ipv4" {
+			return &in.Address
+		}
+	}
+	if len(ifaces) > 0 {
+		return &ifaces[0].Address
+	}
+	return nil
+}
+
+// Upsert inserts or updates workloads by hostname. Null fields never
+// overwrite existing values, and snow_sys_id is left alone (it is only
+// set through Create and Update). A row's interfaces, when given, replace
+// the workload's. On a *sql.DB the batch runs in one transaction; on a
+// *sql.Tx it joins the caller's.
+func (r *workloadRepo) Upsert(ctx context.Context, rows []WorkloadUpdate) (*UpsertResult, error) {
+	var result *UpsertResult
+	err := inTx(ctx, r.db, func(tx DBTX) error {
+		var err error
+		result, err = upsertWorkloads(ctx, tx, rows)
+		return err
+	})
+	return result, err
+}
+
+func upsertWorkloads(ctx context.Context, tx DBTX, rows []WorkloadUpdate) (*UpsertResult, error) {
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO workloads (workload_id, hostname, ip_address, fqdn, os, environment, location, class_type, is_virtual, description)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(hostname) DO UPDATE SET
+		   ip_address=COALESCE(excluded.ip_address, ip_address),
+		   fqdn=COALESCE(excluded.fqdn, fqdn),
+		   os=COALESCE(excluded.os, os),
+		   environment=COALESCE(excluded.environment, environment),
+		   location=COALESCE(excluded.location, location),
+		   class_type=COALESCE(excluded.class_type, class_type),
+		   is_virtual=COALESCE(excluded.is_virtual, is_virtual),
+		   description=COALESCE(excluded.description, description),
+		   updated_at=datetime('now')`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	// Pre-load existing hostnames for created vs updated tracking
+	existing := map[string]string{}
+	hosts, err := tx.QueryContext(ctx, "SELECT hostname, workload_id FROM workloads")
+	if err != nil {
+		return nil, err
+	}
+	for hosts.Next() {
+		var h, id string
+		if err := hosts.Scan(&h, &id); err != nil {
+			hosts.Close()
+			return nil, err
+		}
+		existing[h] = id
+	}
+	hosts.Close()
+
+	result := &UpsertResult{Total: len(rows), Failures: []UpsertFailure{}}
+	// Imports bring their own site names; see importVocabs.
+	vc := &vocabCache{open: importVocabs}
+	for i, w := range rows {
+		if w.Hostname == nil || *w.Hostname == "" {
+			result.fail(i, w.Hostname, &ValidationError{"hostname is required"})
+			continue
+		}
+		if err := workloads.canonicalize(ctx, tx, vc, &w); err != nil {
+			if _, ok := err.(*VocabularyError); !ok {
+				return nil, err
+			}
+			result.fail(i, w.Hostname, err)
+			continue
+		}
+
+		w.IPAddress = normalizedIP(w.IPAddress)
+		var ifaces []Interface
+		if w.Interfaces != nil {
+			var err error
+			if ifaces, err = normalizeInterfaces(w.Interfaces); err != nil {
+				result.fail(i, w.Hostname, err)
+				continue
+			}
+			if w.IPAddress == nil {
+				w.IPAddress = primaryAddress(ifaces)
+			}
+		}
+
+		id, found := existing[*w.Hostname]
+		var before any
+		if found {
+			if before, err = Snapshot(ctx, tx, "workload", id); err != nil {
+				return nil, err
+			}
+		} else {
+			id = newID()
+		}
+		_, err := stmt.ExecContext(ctx,
+			id, *w.Hostname, w.IPAddress, w.FQDN, w.OS,
+			w.Environment, w.Location, w.ClassType, boolArg(w.IsVirtual), w.Description)
+		if err != nil {
+			result.fail(i, w.Hostname, Classify(err))
+			continue
+		}
+		if w.Interfaces != nil {
+			if err := replaceInterfaces(ctx, tx, id, ifaces); err != nil {
+				return nil, err
+			}
+		}
+		after, err := Snapshot(ctx, tx, "workload", id)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			result.Updated++
+			if changed(before, after) {
+				result.Changes = append(result.Changes, Change("workload", id, AuditUpdate, before, after))
+			}
+		} else {
+			result.Created++
+			existing[*w.Hostname] = id
+			result.Changes = append(result.Changes, Change("workload", id, AuditCreate, nil, after))
+		}
+	}
+
+	return result, nil
+}
+
+// Patch applies a JSON Merge Patch; null clears a nullable column. An
+// interfaces array replaces the workload's interfaces and null removes
+// them all.
+func (r *workloadRepo) Patch(ctx context.Context, id string, version int, p Patch) (*Workload, error) {
+	var ifaces []Interface
+	raw, replace := p["interfaces"]
+	if replace {
+		if err := json.Unmarshal(raw, &ifaces); err != nil {
+			return nil, &ValidationError{fmt.Sprintf("invalid interfaces: %v", err)}
+		}
+		var err error
+		if ifaces, err = normalizeInterfaces(ifaces); err != nil {
+			return nil, err
+		}
+	}
+	var ip *string
+	if raw, ok := p["ip_address"]; ok && json.Unmarshal(raw, &ip) == nil && ip != nil {
+		p = maps.Clone(p)
+		p["ip_address"], _ = json.Marshal(normalizeIP(*ip))
+	}
+	sets, args, err := assignments[Workload, WorkloadUpdate](p)
+	if err != nil {
+		return nil, err
+	}
+	err = inTx(ctx, r.db, func(tx DBTX) error {
+		if _, err := workloads.patch(ctx, tx, id, version, sets, args); err != nil {
+			return err
+		}
+		if !replace {
+			return nil
+		}
+		if err := replaceInterfaces(ctx, tx, id, ifaces); err != nil {
+			return err
+		}
+		if len(sets) > 0 {
+			return nil
+		}
+		// Only the interfaces changed; the row still counts as updated.
+		_, err := tx.ExecContext(ctx, "UPDATE workloads SET updated_at = datetime('now') WHERE workload_id = ?", id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+func (r *workloadRepo) Delete(ctx context.Context, id string, version int) error {
+	return workloads.delete(ctx, r.db, id, version)
+}
+
+func (r *workloadRepo) DeleteImpact(ctx context.Context, id string, version int) (*Impact, error) {
+	im, err := workloads.deleteImpact(ctx, r.db, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if im.Deleted["workload_validations"], err = queryIDs(ctx, r.db,
+		"SELECT validation_id FROM workload_validations WHERE workload_id = ? ORDER BY 1", id); err != nil {
+		return nil, err
+	}
+	components, err := queryIDs(ctx, r.db,
+		"SELECT component_id FROM component_workloads WHERE workload_id = ? ORDER BY 1", id)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range components {
+		im.Unlinked = append(im.Unlinked, Link{ComponentID: c, WorkloadID: id})
+	}
+	return im.count(), nil
+}
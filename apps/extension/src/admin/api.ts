@@ -1,6 +1,6 @@
 import type {
   Portfolio, Asset, AppGrouping, Application, Component,
-  ComponentClass, ComponentType, Workload, ListResponse,
+  ComponentClass, ComponentType, Workload, ListResponse, DeleteImpact,
 } from './types';
 
 const BASE = 'http://localhost:8080/v1/cmdb';
@@ -14,7 +14,7 @@ async function request<T>(path: string, opts?: RequestInit): Promise<T> {
     const body = await res.json().catch(() => ({}));
     throw new Error(body.error || `HTTP ${res.status}`);
   }
-  if (opts?.method === 'DELETE') return { deleted: true } as T;
+  if (opts?.method === 'DELETE' && !path.endsWith('dry_run=true')) return { deleted: true } as T;
   return res.json();
 }
 
@@ -110,6 +110,17 @@ export const updateComponent = (id: string, data: Record<string, any>) =>
 export const deleteComponent = (id: string) =>
   request<{ deleted: boolean }>(`/components/${id}`, { method: 'DELETE' });
 
+// ─── Delete previews (?dry_run=true) ──────────────────
+
+const previewDelete = (path: string) =>
+  request<{ impact: DeleteImpact }>(`${path}?dry_run=true`, { method: 'DELETE' }).then((r) => r.impact);
+
+export const previewDeletePortfolio = (id: string) => previewDelete(`/portfolios/${id}`);
+export const previewDeleteAsset = (id: string) => previewDelete(`/assets/${id}`);
+export const previewDeleteAppGrouping = (id: string) => previewDelete(`/app-groupings/${id}`);
+export const previewDeleteApplication = (id: string) => previewDelete(`/applications/${id}`);
+export const previewDeleteComponent = (id: string) => previewDelete(`/components/${id}`);
+
 // ─── Component Classes (read-only) ────────────────────
 
 export const listComponentClasses = () =>
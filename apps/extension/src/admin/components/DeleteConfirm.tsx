@@ -1,19 +1,55 @@
+import { useState, useEffect } from 'react';
+import type { DeleteImpact } from '../types';
+
 interface Props {
   entityName: string;
+  preview?: () => Promise<DeleteImpact>;
   onConfirm: () => void;
   onCancel: () => void;
   loading?: boolean;
 }
 
-export function DeleteConfirm({ entityName, onConfirm, onCancel, loading }: Props) {
+// Hierarchy tables, top down, with their display labels.
+const TIERS: [string, string][] = [
+  ['portfolios', 'portfolio'],
+  ['assets', 'asset'],
+  ['app_groupings', 'app grouping'],
+  ['applications', 'application'],
+  ['components', 'component'],
+];
+
+export function DeleteConfirm({ entityName, preview, onConfirm, onCancel, loading }: Props) {
+  const [impact, setImpact] = useState<DeleteImpact | null>(null);
+
+  useEffect(() => {
+    preview?.().then(setImpact).catch(() => setImpact(null));
+  }, []);
+
+  // The topmost tier in the impact is the entity itself; list the rest.
+  const tiers = TIERS.filter(([table]) => impact?.deleted[table]);
+  const children = tiers.slice(1).filter(([table]) => impact!.counts[table] > 0);
+
   return (
     <div className="fixed inset-0 bg-black/30 flex items-center justify-center z-50">
       <div className="bg-white rounded-lg shadow-lg p-6 max-w-sm w-full mx-4">
         <h3 className="text-sm font-semibold text-text mb-2">Delete {entityName}?</h3>
         <p className="text-xs text-text-muted mb-4">
-          This will permanently delete <strong>{entityName}</strong> and cannot be undone.
-          Any child entities will also be removed.
+          {impact?.restorable
+            ? <>This will move <strong>{entityName}</strong> and everything beneath it to the trash.</>
+            : <>This will permanently delete <strong>{entityName}</strong> and cannot be undone.
+                Any child entities will also be removed.</>}
         </p>
+        {impact && (
+          <ul className="text-xs text-text-muted mb-4 list-disc pl-4">
+            {children.map(([table, label]) => (
+              <li key={table}>{impact.counts[table]} {label}{impact.counts[table] === 1 ? '' : 's'}</li>
+            ))}
+            <li>{impact.counts.unlinked} workload link{impact.counts.unlinked === 1 ? '' : 's'}</li>
+            {impact.counts.orphaned_workloads > 0 && (
+              <li>{impact.counts.orphaned_workloads} workload{impact.counts.orphaned_workloads === 1 ? '' : 's'} left unlinked</li>
+            )}
+          </ul>
+        )}
         <div className="flex justify-end gap-2">
           <button onClick={onCancel} className="btn btn-outline btn-sm" disabled={loading}>
             Cancel
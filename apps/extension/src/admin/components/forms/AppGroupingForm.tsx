@@ -1,5 +1,5 @@
 import { useState, useEffect } from 'react';
-import { getAppGrouping, createAppGrouping, updateAppGrouping, deleteAppGrouping } from '../../api';
+import { getAppGrouping, createAppGrouping, updateAppGrouping, deleteAppGrouping, previewDeleteAppGrouping } from '../../api';
 import { DeleteConfirm } from '../DeleteConfirm';
 
 interface Props {
@@ -90,7 +90,7 @@ export function AppGroupingForm({ id, parentId, mode, onSaved, onDeleted }: Prop
       </button>
 
       {showDelete && (
-        <DeleteConfirm entityName={entityName} onConfirm={handleDelete} onCancel={() => setShowDelete(false)} loading={loading} />
+        <DeleteConfirm entityName={entityName} preview={() => previewDeleteAppGrouping(id!)} onConfirm={handleDelete} onCancel={() => setShowDelete(false)} loading={loading} />
       )}
     </form>
   );
@@ -1,5 +1,5 @@
 import { useState, useEffect } from 'react';
-import { getApplication, createApplication, updateApplication, deleteApplication } from '../../api';
+import { getApplication, createApplication, updateApplication, deleteApplication, previewDeleteApplication } from '../../api';
 import { DeleteConfirm } from '../DeleteConfirm';
 
 interface Props {
@@ -90,7 +90,7 @@ export function ApplicationForm({ id, parentId, mode, onSaved, onDeleted }: Prop
       </button>
 
       {showDelete && (
-        <DeleteConfirm entityName={entityName} onConfirm={handleDelete} onCancel={() => setShowDelete(false)} loading={loading} />
+        <DeleteConfirm entityName={entityName} preview={() => previewDeleteApplication(id!)} onConfirm={handleDelete} onCancel={() => setShowDelete(false)} loading={loading} />
       )}
     </form>
   );
@@ -1,5 +1,5 @@
 import { useState, useEffect } from 'react';
-import { getAsset, createAsset, updateAsset, deleteAsset } from '../../api';
+import { getAsset, createAsset, updateAsset, deleteAsset, previewDeleteAsset } from '../../api';
 import { DeleteConfirm } from '../DeleteConfirm';
 
 interface Props {
@@ -125,7 +125,7 @@ export function AssetForm({ id, parentId, mode, onSaved, onDeleted }: Props) {
       </button>
 
       {showDelete && (
-        <DeleteConfirm entityName={entityName} onConfirm={handleDelete} onCancel={() => setShowDelete(false)} loading={loading} />
+        <DeleteConfirm entityName={entityName} preview={() => previewDeleteAsset(id!)} onConfirm={handleDelete} onCancel={() => setShowDelete(false)} loading={loading} />
       )}
     </form>
   );
@@ -1,6 +1,6 @@
 import { useState, useEffect } from 'react';
 import { useQuery } from '@tanstack/react-query';
-import { getComponent, createComponent, updateComponent, deleteComponent, listComponentClasses, listComponentTypes } from '../../api';
+import { getComponent, createComponent, updateComponent, deleteComponent, previewDeleteComponent, listComponentClasses, listComponentTypes } from '../../api';
 import { DeleteConfirm } from '../DeleteConfirm';
 
 interface Props {
@@ -142,7 +142,7 @@ export function ComponentForm({ id, parentId, mode, onSaved, onDeleted }: Props)
       </button>
 
       {showDelete && (
-        <DeleteConfirm entityName={entityLabel} onConfirm={handleDelete} onCancel={() => setShowDelete(false)} loading={loading} />
+        <DeleteConfirm entityName={entityLabel} preview={() => previewDeleteComponent(id!)} onConfirm={handleDelete} onCancel={() => setShowDelete(false)} loading={loading} />
       )}
     </form>
   );
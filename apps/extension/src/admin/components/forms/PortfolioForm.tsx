@@ -1,5 +1,5 @@
 import { useState, useEffect } from 'react';
-import { getPortfolio, createPortfolio, updatePortfolio, deletePortfolio } from '../../api';
+import { getPortfolio, createPortfolio, updatePortfolio, deletePortfolio, previewDeletePortfolio } from '../../api';
 import { DeleteConfirm } from '../DeleteConfirm';
 
 interface Props {
@@ -97,7 +97,7 @@ export function PortfolioForm({ id, mode, onSaved, onDeleted }: Props) {
       </button>
 
       {showDelete && (
-        <DeleteConfirm entityName={entityName} onConfirm={handleDelete} onCancel={() => setShowDelete(false)} loading={loading} />
+        <DeleteConfirm entityName={entityName} preview={() => previewDeletePortfolio(id!)} onConfirm={handleDelete} onCancel={() => setShowDelete(false)} loading={loading} />
       )}
     </form>
   );
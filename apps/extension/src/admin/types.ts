@@ -6,6 +6,7 @@ export interface Portfolio {
   description: string | null;
   created_at: string;
   updated_at: string;
+  version: number;
 }
 
 export interface Asset {
@@ -21,6 +22,7 @@ export interface Asset {
   infrastructure: string | null;
   created_at: string;
   updated_at: string;
+  version: number;
 }
 
 export interface AppGrouping {
@@ -30,6 +32,7 @@ export interface AppGrouping {
   description: string | null;
   created_at: string;
   updated_at: string;
+  version: number;
 }
 
 export interface Application {
@@ -39,6 +42,7 @@ export interface Application {
   description: string | null;
   created_at: string;
   updated_at: string;
+  version: number;
 }
 
 export interface ComponentClass {
@@ -66,6 +70,15 @@ export interface Component {
   description: string | null;
   created_at: string;
   updated_at: string;
+  version: number;
+}
+
+export interface WorkloadInterface {
+  name: string | null;
+  address: string;
+  family: 'ipv4' | 'ipv6';
+  cidr_block: number | null;
+  source: string;
 }
 
 export interface Workload {
@@ -77,12 +90,59 @@ export interface Workload {
   environment: string | null;
   location: string | null;
   class_type: string | null;
-  is_virtual: number | null;
+  is_virtual: boolean;
   description: string | null;
+  interfaces: WorkloadInterface[];
+  version: number;
+}
+
+export type Vocabulary = 'criticality' | 'environment' | 'location';
+
+export interface VocabularyTerm {
+  vocabulary: Vocabulary;
+  value: string;
+  label: string | null;
+  color: string | null;
+  sort_order: number;
+  aliases: string[];
+  created_at: string;
+  updated_at: string;
 }
 
 export type EntityType = 'portfolio' | 'asset' | 'app_grouping' | 'application' | 'component';
 
+export interface DeleteImpact {
+  deleted: Record<string, string[]>;
+  unlinked: { component_id: string; workload_id: string }[];
+  orphaned_workloads: string[];
+  counts: Record<string, number>;
+  restorable: boolean;
+}
+
+export interface TrashEntry {
+  type: EntityType;
+  id: string;
+  name: string | null;
+  parent_id: string | null;
+  deleted_at: string;
+  deletion_id: string;
+  cascaded: number;
+}
+
+export interface AuditEntry {
+  audit_id: number;
+  entity: string;
+  entity_id: string | null;
+  action: 'create' | 'update' | 'delete' | 'link' | 'unlink' | 'restore' | 'purge' | 'import';
+  related_entity: string | null;
+  related_id: string | null;
+  before: Record<string, unknown> | null;
+  after: Record<string, unknown> | null;
+  actor: string | null;
+  request_id: string | null;
+  created_at: string;
+}
+
 export interface SelectedNode {
   type: EntityType;
   id: string;
@@ -93,4 +153,6 @@ export interface SelectedNode {
 export interface ListResponse<T> {
   data: T[];
   count: number;
+  total: number;
+  next_cursor: string | null;
 }
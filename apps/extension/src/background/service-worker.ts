@@ -943,6 +943,9 @@ async function syncIllumioWorkloadsAction() {
       updated += result.updated || 0;
       errors += result.errors || 0;
       log(`  Batch ${batchNum} done: +${result.created} created, +${result.updated} updated, +${result.errors} errors`);
+      for (const f of result.failures || []) {
+        log(`    ${f.hostname ?? `row ${f.index}`}: ${f.error}`);
+      }
     }
 
     log(`Sync complete: ${created} created, ${updated} updated, ${errors} errors out of ${mapped.length} total`);
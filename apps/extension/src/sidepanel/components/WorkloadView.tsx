@@ -9,7 +9,7 @@ interface CMDBWorkload {
   environment?: string;
   location?: string;
   class_type?: string;
-  is_virtual?: number;
+  is_virtual?: boolean;
   description?: string;
 }
 
@@ -317,7 +317,7 @@ function CMDBSection({
         {workload.class_type && (
           <Field label="Class" value={workload.class_type.replace(/^cmdb_ci_/, '').replace(/_/g, ' ')} />
         )}
-        {workload.is_virtual === 1 && (
+        {workload.is_virtual && (
           <div className="flex items-baseline justify-between">
             <span className="text-xs text-text-muted">Virtual</span>
             <span className="text-[10px] px-1.5 py-0.5 rounded bg-purple-50 text-purple-600 font-medium">VM</span>
@@ -2,8 +2,12 @@
 //
 // Usage:
 //
-//	go run ./cmd             # run pending migrations
-//	go run ./cmd status      # show applied migrations
+//	go run ./cmd               # run pending migrations
+//	go run ./cmd status        # show applied migrations
+//	go run ./cmd verify        # flag applied migrations whose files changed or disappeared
+//	go run ./cmd verify --accept  # record checksums of migrations applied before they were tracked
+//	go run ./cmd down [n]      # roll back the last n migrations (default 1)
+//	go run ./cmd create <name> # scaffold the next migration and its down file in sql/
 package main
 
 import (
@@ -11,12 +15,33 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	migrations "github.com/jihaia/aperture/packages/migrations"
 	_ "modernc.org/sqlite"
 )
 
 func main() {
+	cmd := "up"
+	if len(os.Args) > 1 {
+		cmd = os.Args[1]
+	}
+
+	if cmd == "create" {
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: migrate create <name>")
+			os.Exit(1)
+		}
+		up, down, err := migrations.Create("sql", os.Args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created %s\n", up)
+		fmt.Printf("Created %s\n", down)
+		return
+	}
+
 	dbPath := os.Getenv("APERTURE_DB_PATH")
 	if dbPath == "" {
 		home, err := os.UserHomeDir()
@@ -36,11 +61,6 @@ func main() {
 	}
 	defer db.Close()
 
-	cmd := "up"
-	if len(os.Args) > 1 {
-		cmd = os.Args[1]
-	}
-
 	switch cmd {
 	case "up", "migrate":
 		fmt.Printf("Database: %s\n", dbPath)
@@ -61,14 +81,66 @@ func main() {
 			fmt.Println("No migrations applied.")
 			return
 		}
-		fmt.Printf("%-40s %s\n", "MIGRATION", "APPLIED AT")
-		fmt.Printf("%-40s %s\n", "─────────", "──────────")
+		fmt.Printf("%-40s %-20s %s\n", "MIGRATION", "APPLIED AT", "CHECKSUM")
+		fmt.Printf("%-40s %-20s %s\n", "─────────", "──────────", "────────")
 		for _, m := range list {
-			fmt.Printf("%-40s %s\n", m.Name, m.AppliedAt)
+			sum := m.Checksum
+			if len(sum) > 12 {
+				sum = sum[:12]
+			}
+			fmt.Printf("%-40s %-20s %s\n", m.Name, m.AppliedAt, sum)
 		}
 
+	case "verify":
+		if len(os.Args) > 2 && os.Args[2] == "--accept" {
+			accepted, err := migrations.Accept(db)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			for _, name := range accepted {
+				fmt.Printf("%-40s recorded\n", name)
+			}
+		}
+		problems, err := migrations.Verify(db)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(problems) == 0 {
+			fmt.Println("All applied migrations match their files.")
+			return
+		}
+		unrecorded := false
+		for _, p := range problems {
+			fmt.Printf("%-40s %s\n", p.Name, p.Issue)
+			unrecorded = unrecorded || p.Issue == "unrecorded"
+		}
+		if unrecorded {
+			fmt.Println("Unrecorded migrations predate checksums; once the schema is checked, run: migrate verify --accept")
+		}
+		os.Exit(1)
+
+	case "down":
+		n := 1
+		if len(os.Args) > 2 {
+			v, err := strconv.Atoi(os.Args[2])
+			if err != nil || v < 1 {
+				fmt.Fprintf(os.Stderr, "Invalid count: %s\n", os.Args[2])
+				os.Exit(1)
+			}
+			n = v
+		}
+		fmt.Printf("Database: %s\n", dbPath)
+		fmt.Printf("Rolling back %d migration(s)...\n", n)
+		if _, err := migrations.Down(db, n); err != nil {
+			fmt.Fprintf(os.Stderr, "Rollback failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Done.")
+
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\nUsage: migrate [up|status]\n", cmd)
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\nUsage: migrate [up|status|verify [--accept]|down [n]|create <name>]\n", cmd)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create scaffolds the next numbered migration and its down file in dir
+// (normally packages/migrations/sql) and returns their paths. The number
+// follows the highest one found on disk or embedded in this build.
+func Create(dir, name string) (up, down string, err error) {
+	slug := strings.Trim(nonWord.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return "", "", fmt.Errorf("invalid migration name: %q", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("read %s: %w", dir, err)
+	}
+	names, err := Available()
+	if err != nil {
+		return "", "", err
+	}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	next := 1
+	for _, n := range names {
+		prefix, _, ok := strings.Cut(n, "_")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.Atoi(prefix); err == nil && v >= next {
+			next = v + 1
+		}
+	}
+
+	base := fmt.Sprintf("%03d_%s", next, slug)
+	up = filepath.Join(dir, base+".sql")
+	down = filepath.Join(dir, base+downSuffix)
+
+	upBody := fmt.Sprintf("-- %s\n\n", name)
+	downBody := fmt.Sprintf("-- Roll back %s.sql\n\n", base)
+	if err := os.WriteFile(up, []byte(upBody), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte(downBody), 0644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
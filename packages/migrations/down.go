@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// downSuffix marks the optional rollback file paired with a migration:
+// 004_reconciliations.sql is undone by 004_reconciliations.down.sql.
+const downSuffix = ".down.sql"
+
+// DownFile returns the name of the rollback file for a migration.
+func DownFile(name string) string {
+	return strings.TrimSuffix(name, ".sql") + downSuffix
+}
+
+// Down rolls back the last n applied migrations, newest first, each in its
+// own transaction. Every one of them must have a down file; if any is
+// missing nothing is rolled back.
+func Down(db *sql.DB, n int) ([]string, error) {
+	applied, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+	if n > len(applied) {
+		n = len(applied)
+	}
+
+	var targets []string
+	for i := len(applied) - 1; i >= len(applied)-n; i-- {
+		targets = append(targets, applied[i].Name)
+	}
+
+	scripts := make(map[string]string, len(targets))
+	for _, name := range targets {
+		content, err := fs.ReadFile(sqlFiles, "sql/"+DownFile(name))
+		if err != nil {
+			return nil, fmt.Errorf("%s has no down migration (%s)", name, DownFile(name))
+		}
+		scripts[name] = string(content)
+	}
+
+	var done []string
+	for _, name := range targets {
+		tx, err := db.Begin()
+		if err != nil {
+			return done, fmt.Errorf("begin tx for %s: %w", name, err)
+		}
+
+		if _, err := tx.Exec(scripts[name]); err != nil {
+			tx.Rollback()
+			return done, fmt.Errorf("roll back %s: %w", name, err)
+		}
+
+		if _, err := tx.Exec("DELETE FROM migrations WHERE name = ?", name); err != nil {
+			tx.Rollback()
+			return done, fmt.Errorf("unrecord %s: %w", name, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return done, fmt.Errorf("commit %s: %w", name, err)
+		}
+
+		fmt.Fprintf(Output, "  Rolled back: %s\n", name)
+		done = append(done, name)
+	}
+	return done, nil
+}
@@ -3,10 +3,14 @@
 package migrations
 
 import (
+	"crypto/sha256"
 	"database/sql"
 	"embed"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/fs"
+	"os"
 	"sort"
 	"strings"
 )
@@ -14,52 +18,25 @@ import (
 //go:embed sql/*.sql
 var sqlFiles embed.FS
 
+// Output receives progress messages from Run. Programs whose stdout is a
+// protocol channel (such as the native messaging host) should redirect it.
+var Output io.Writer = os.Stdout
+
 // Run applies all pending migrations to the given database connection.
 // It creates the migrations tracking table if it doesn't exist,
-// then applies any .sql files from sql/ that haven't been run yet.
+// then applies any .sql files from sql/ that haven't been run yet,
+// recording the checksum of each file it applies. Migrations applied
+// before checksums were tracked are left without one: the file may have
+// changed since, so Verify reports them as unrecorded.
 func Run(db *sql.DB) error {
-	// Create tracking table
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS migrations (
-			name TEXT PRIMARY KEY,
-			applied_at TEXT NOT NULL DEFAULT (datetime('now'))
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("create migrations table: %w", err)
-	}
-
-	// Get already-applied migrations
-	rows, err := db.Query("SELECT name FROM migrations")
-	if err != nil {
-		return fmt.Errorf("query migrations: %w", err)
-	}
-	defer rows.Close()
-
-	applied := make(map[string]bool)
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			return err
-		}
-		applied[name] = true
+	if err := ensureTable(db); err != nil {
+		return err
 	}
 
-	// Read all .sql files from embedded filesystem
-	entries, err := fs.ReadDir(sqlFiles, "sql")
+	pending, err := Pending(db)
 	if err != nil {
-		return fmt.Errorf("read migration files: %w", err)
-	}
-
-	var pending []string
-	for _, e := range entries {
-		if !e.IsDir() && strings.HasSuffix(e.Name(), ".sql") {
-			if !applied[e.Name()] {
-				pending = append(pending, e.Name())
-			}
-		}
+		return err
 	}
-	sort.Strings(pending)
 
 	if len(pending) == 0 {
 		return nil
@@ -82,7 +59,7 @@ func Run(db *sql.DB) error {
 			return fmt.Errorf("migrate %s: %w", name, err)
 		}
 
-		if _, err := tx.Exec("INSERT INTO migrations (name) VALUES (?)", name); err != nil {
+		if _, err := tx.Exec("INSERT INTO migrations (name, checksum) VALUES (?, ?)", name, checksum(content)); err != nil {
 			tx.Rollback()
 			return fmt.Errorf("record %s: %w", name, err)
 		}
@@ -91,15 +68,120 @@ func Run(db *sql.DB) error {
 			return fmt.Errorf("commit %s: %w", name, err)
 		}
 
-		fmt.Printf("  Applied: %s\n", name)
+		fmt.Fprintf(Output, "  Applied: %s\n", name)
 	}
 
 	return nil
 }
 
-// Status returns the list of applied migrations.
+// ensureTable creates the migrations table, adding the checksum column to
+// tables created before it existed.
+func ensureTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS migrations (
+			name TEXT PRIMARY KEY,
+			applied_at TEXT NOT NULL DEFAULT (datetime('now')),
+			checksum TEXT
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create migrations table: %w", err)
+	}
+
+	ok, err := hasChecksum(db)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if _, err := db.Exec("ALTER TABLE migrations ADD COLUMN checksum TEXT"); err != nil {
+			return fmt.Errorf("add checksum column: %w", err)
+		}
+	}
+	return nil
+}
+
+func hasChecksum(db *sql.DB) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT count(*) FROM pragma_table_info('migrations') WHERE name = 'checksum'").Scan(&n)
+	return n > 0, err
+}
+
+// Checksum returns the SHA-256 of an embedded migration file.
+func Checksum(name string) (string, error) {
+	content, err := fs.ReadFile(sqlFiles, "sql/"+name)
+	if err != nil {
+		return "", err
+	}
+	return checksum(content), nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Available returns the names of all embedded migration files, sorted.
+// Down files are not included.
+func Available() ([]string, error) {
+	entries, err := fs.ReadDir(sqlFiles, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("read migration files: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".sql") && !strings.HasSuffix(e.Name(), downSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Pending returns the embedded migrations that have not been applied yet.
+// A database without the migrations table has everything pending.
+func Pending(db *sql.DB) ([]string, error) {
+	applied, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool, len(applied))
+	for _, m := range applied {
+		done[m.Name] = true
+	}
+
+	names, err := Available()
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, name := range names {
+		if !done[name] {
+			pending = append(pending, name)
+		}
+	}
+	return pending, nil
+}
+
+// Status returns the list of applied migrations, or none if the
+// migrations table has not been created yet.
 func Status(db *sql.DB) ([]Migration, error) {
-	rows, err := db.Query("SELECT name, applied_at FROM migrations ORDER BY name")
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'migrations'").Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	cols := "name, applied_at, checksum"
+	if ok, err := hasChecksum(db); err != nil {
+		return nil, err
+	} else if !ok {
+		cols = "name, applied_at, NULL"
+	}
+
+	rows, err := db.Query("SELECT " + cols + " FROM migrations ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -108,15 +190,44 @@ func Status(db *sql.DB) ([]Migration, error) {
 	var list []Migration
 	for rows.Next() {
 		var m Migration
-		if err := rows.Scan(&m.Name, &m.AppliedAt); err != nil {
+		var sum sql.NullString
+		if err := rows.Scan(&m.Name, &m.AppliedAt, &sum); err != nil {
 			return nil, err
 		}
+		m.Checksum = sum.String
 		list = append(list, m)
 	}
-	return list, nil
+	return list, rows.Err()
 }
 
 type Migration struct {
-	Name      string
-	AppliedAt string
+	Name      string `json:"name"`
+	AppliedAt string `json:"applied_at"`
+	Checksum  string `json:"checksum,omitempty"`
+}
+
+// Report describes how far a database's schema is from the embedded migrations.
+type Report struct {
+	Applied  []Migration `json:"applied"`
+	Pending  []string    `json:"pending"`
+	UpToDate bool        `json:"up_to_date"`
+}
+
+// Check reports the applied and pending migrations without changing anything.
+func Check(db *sql.DB) (*Report, error) {
+	applied, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := Pending(db)
+	if err != nil {
+		return nil, err
+	}
+	if applied == nil {
+		applied = []Migration{}
+	}
+	if pending == nil {
+		pending = []string{}
+	}
+	return &Report{Applied: applied, Pending: pending, UpToDate: len(pending) == 0}, nil
 }
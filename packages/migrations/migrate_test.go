@@ -0,0 +1,223 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+var testDBs atomic.Int64
+
+// testDB returns an empty in-memory database on a single connection.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:migrate%d?mode=memory&_pragma=foreign_keys(ON)", testDBs.Add(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	Output = io.Discard
+	return db
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tables lists the schema's tables, the migrations table aside.
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table'
+		AND name NOT IN ('migrations', 'sqlite_sequence') ORDER BY name`)
+	must(t, err)
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var n string
+		must(t, rows.Scan(&n))
+		names = append(names, n)
+	}
+	return names
+}
+
+func TestEveryMigrationHasDown(t *testing.T) {
+	names, err := Available()
+	must(t, err)
+	for _, name := range names {
+		if _, err := fs.Stat(sqlFiles, "sql/"+DownFile(name)); err != nil {
+			t.Errorf("%s has no %s", name, DownFile(name))
+		}
+	}
+}
+
+func TestUpDownUp(t *testing.T) {
+	db := testDB(t)
+	names, err := Available()
+	must(t, err)
+
+	must(t, Run(db))
+	schema := fmt.Sprint(tables(t, db))
+	// Data written along the way must not stop the rollback.
+	_, err = db.Exec(`INSERT INTO portfolios (portfolio_id, name) VALUES ('p', 'P');
+		INSERT INTO assets (asset_id, name, portfolio_id) VALUES ('a', 'A', 'p');
+		INSERT INTO app_groupings (app_grouping_id, name, asset_id) VALUES ('g', 'G', 'a');
+		INSERT INTO applications (application_id, name, app_grouping_id) VALUES ('ap', 'AP', 'g');
+		INSERT INTO components (component_id, application_id) VALUES ('c', 'ap');
+		INSERT INTO workloads (workload_id, hostname) VALUES ('w', 'h');
+		INSERT INTO component_workloads (component_id, workload_id) VALUES ('c', 'w')`)
+	must(t, err)
+
+	// Step down one at a time, so each down file runs against the schema
+	// its own migration left.
+	for i := len(names) - 1; i >= 0; i-- {
+		done, err := Down(db, 1)
+		if err != nil {
+			t.Fatalf("down %s: %v", names[i], err)
+		}
+		if len(done) != 1 || done[0] != names[i] {
+			t.Fatalf("rolled back %v, want %s", done, names[i])
+		}
+		if names[i] == "003_component_classes.sql" {
+			// Name is required again; the unnamed component kept its row.
+			var name string
+			must(t, db.QueryRow("SELECT name FROM components WHERE component_id = 'c'").Scan(&name))
+			if name != "c" {
+				t.Errorf("component name = %q, want its id", name)
+			}
+		}
+	}
+	if left := tables(t, db); len(left) != 0 {
+		t.Errorf("tables left after rolling everything back: %v", left)
+	}
+	applied, err := Status(db)
+	must(t, err)
+	if len(applied) != 0 {
+		t.Errorf("%d migrations still recorded", len(applied))
+	}
+
+	must(t, Run(db))
+	if got := fmt.Sprint(tables(t, db)); got != schema {
+		t.Errorf("schema after up, down, up:\n%s\nwant\n%s", got, schema)
+	}
+	problems, err := Verify(db)
+	must(t, err)
+	if len(problems) != 0 {
+		t.Errorf("problems after a clean run: %+v", problems)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper string
+		issue  string
+	}{
+		{"modified", "UPDATE migrations SET checksum = 'stale' WHERE name = '004_reconciliations.sql'", "modified"},
+		{"missing", "INSERT INTO migrations (name, checksum) VALUES ('000_gone.sql', 'x')", "missing"},
+		// Run does not vouch for a legacy row by trusting today's file.
+		{"unrecorded", "UPDATE migrations SET checksum = NULL WHERE name = '004_reconciliations.sql'", "unrecorded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			must(t, Run(db))
+			_, err := db.Exec(tt.tamper)
+			must(t, err)
+			must(t, Run(db))
+
+			problems, err := Verify(db)
+			must(t, err)
+			if len(problems) != 1 || problems[0].Issue != tt.issue {
+				t.Errorf("problems = %+v, want one %s", problems, tt.issue)
+			}
+		})
+	}
+}
+
+// TestLegacyTable checks a migrations table from before checksums gains
+// the column, and its rows stay unrecorded.
+func TestLegacyTable(t *testing.T) {
+	db := testDB(t)
+	_, err := db.Exec(`CREATE TABLE migrations (name TEXT PRIMARY KEY, applied_at TEXT NOT NULL DEFAULT (datetime('now')))`)
+	must(t, err)
+	content, err := fs.ReadFile(sqlFiles, "sql/001_initial_schema.sql")
+	must(t, err)
+	_, err = db.Exec(string(content))
+	must(t, err)
+	_, err = db.Exec("INSERT INTO migrations (name) VALUES ('001_initial_schema.sql')")
+	must(t, err)
+
+	must(t, Run(db))
+	problems, err := Verify(db)
+	must(t, err)
+	if len(problems) != 1 || problems[0].Name != "001_initial_schema.sql" || problems[0].Issue != "unrecorded" {
+		t.Errorf("problems = %+v, want 001 unrecorded", problems)
+	}
+	pending, err := Pending(db)
+	must(t, err)
+	if len(pending) != 0 {
+		t.Errorf("pending after run: %v", pending)
+	}
+}
+
+func TestAccept(t *testing.T) {
+	db := testDB(t)
+	must(t, Run(db))
+	_, err := db.Exec(`UPDATE migrations SET checksum = NULL WHERE name IN ('001_initial_schema.sql', '002_seed_component_types.sql');
+		UPDATE migrations SET checksum = 'stale' WHERE name = '004_reconciliations.sql'`)
+	must(t, err)
+
+	accepted, err := Accept(db)
+	must(t, err)
+	if fmt.Sprint(accepted) != "[001_initial_schema.sql 002_seed_component_types.sql]" {
+		t.Errorf("accepted %v, want the two unrecorded", accepted)
+	}
+	// A changed file is not accepted: that needs a new migration.
+	problems, err := Verify(db)
+	must(t, err)
+	if len(problems) != 1 || problems[0].Issue != "modified" {
+		t.Errorf("problems after accept = %+v, want 004 modified", problems)
+	}
+	if accepted, err = Accept(db); err != nil || len(accepted) != 0 {
+		t.Errorf("second accept recorded %v, %v", accepted, err)
+	}
+}
+
+// TestCanonicalIPBackfill checks 013 rewrites the primary addresses it
+// can into the form the API now stores.
+func TestCanonicalIPBackfill(t *testing.T) {
+	db := testDB(t)
+	must(t, Run(db))
+	_, err := Down(db, 1)
+	must(t, err)
+
+	tests := []struct{ written, want string }{
+		{" 10.0.0.1 ", "10.0.0.1"},
+		{"2001:DB8::1", "2001:db8::1"},
+		{"::FFFF:10.0.0.2", "10.0.0.2"},
+		// Zero padding is beyond SQL; the API matches it as written.
+		{"2001:0DB8::0001", "2001:0db8::0001"},
+		{"  ", ""},
+	}
+	for i, tt := range tests {
+		_, err := db.Exec("INSERT INTO workloads (workload_id, hostname, ip_address) VALUES (?, ?, ?)", i, fmt.Sprint("h", i), tt.written)
+		must(t, err)
+	}
+	must(t, Run(db))
+	for i, tt := range tests {
+		var got sql.NullString
+		must(t, db.QueryRow("SELECT ip_address FROM workloads WHERE workload_id = ?", i).Scan(&got))
+		if got.String != tt.want {
+			t.Errorf("%q back-filled as %q, want %q", tt.written, got.String, tt.want)
+		}
+	}
+}
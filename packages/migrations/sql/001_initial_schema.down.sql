@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS component_workloads;
+DROP TABLE IF EXISTS workloads;
+DROP TABLE IF EXISTS components;
+DROP TABLE IF EXISTS component_types;
+DROP TABLE IF EXISTS applications;
+DROP TABLE IF EXISTS app_groupings;
+DROP TABLE IF EXISTS assets;
+DROP TABLE IF EXISTS portfolios;
@@ -0,0 +1,21 @@
+-- Removes the seeded component types. Components of these types keep
+-- their rows; component_type_id is cleared by ON DELETE SET NULL.
+DELETE FROM component_types WHERE class_name IN (
+  'cmdb_ci_app_server', 'cmdb_ci_app_server_tomcat', 'cmdb_ci_app_server_weblogic',
+  'cmdb_ci_app_server_jboss', 'cmdb_ci_app_server_websphere', 'cmdb_ci_app_server_java',
+  'cmdb_ci_app_server_nodejs', 'cmdb_ci_web_server', 'cmdb_ci_apache_web_server',
+  'cmdb_ci_microsoft_iis_web_server', 'cmdb_ci_nginx_web_server', 'cmdb_ci_db_instance',
+  'cmdb_ci_db_mssql_instance', 'cmdb_ci_db_ora_instance', 'cmdb_ci_db_ora_listener',
+  'cmdb_ci_db_postgresql_instance', 'cmdb_ci_db_mysql_instance',
+  'cmdb_ci_db_mongodb_instance', 'cmdb_ci_db_syb_instance', 'cmdb_ci_docker_engine',
+  'cmdb_ci_docker_container', 'cmdb_ci_kubernetes_cluster', 'cmdb_ci_lb_service',
+  'cmdb_ci_lb', 'cmdb_ci_lb_bigip', 'cmdb_ci_appl', 'cmdb_ci_appl_license_server',
+  'cmdb_ci_appl_sap', 'cmdb_ci_mq_queue_manager', 'cmdb_ci_mq_channel',
+  'cmdb_ci_mq_queue', 'cmdb_ci_cluster', 'cmdb_ci_cluster_node',
+  'cmdb_ci_cluster_resource', 'cmdb_ci_storage_device', 'cmdb_ci_storage_volume',
+  'cmdb_ci_service', 'cmdb_ci_service_discovered', 'cmdb_ci_service_auto',
+  'cmdb_ci_service_group', 'cmdb_ci_esx_server', 'cmdb_ci_hyper_v_server',
+  'cmdb_ci_ip_switch', 'cmdb_ci_ip_router', 'cmdb_ci_ip_firewall', 'cmdb_ci_server',
+  'cmdb_ci_linux_server', 'cmdb_ci_win_server', 'cmdb_ci_unix_server',
+  'cmdb_ci_aix_server', 'cmdb_ci_solaris_server', 'cmdb_ci_hpux_server'
+);
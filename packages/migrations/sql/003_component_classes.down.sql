@@ -0,0 +1,43 @@
+-- Restores the 001 components table: name required and unique within an
+-- application, no component_class_id. Unnamed components take their id
+-- as their name; components sharing a name in one application make the
+-- rollback fail rather than lose a row.
+CREATE TABLE _components_backup AS SELECT * FROM components;
+CREATE TABLE _cw_backup AS SELECT * FROM component_workloads;
+DROP TABLE component_workloads;
+DROP TABLE components;
+
+CREATE TABLE components (
+  component_id TEXT PRIMARY KEY NOT NULL,
+  name TEXT NOT NULL,
+  application_id TEXT NOT NULL REFERENCES applications(application_id) ON DELETE CASCADE,
+  component_type_id TEXT REFERENCES component_types(component_type_id) ON DELETE SET NULL,
+  snow_sys_id TEXT UNIQUE,
+  description TEXT,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+  UNIQUE(name, application_id)
+);
+CREATE INDEX idx_components_application ON components(application_id);
+CREATE INDEX idx_components_type ON components(component_type_id);
+CREATE INDEX idx_components_name ON components(name);
+
+INSERT INTO components (component_id, name, application_id, component_type_id, snow_sys_id, description, created_at, updated_at)
+  SELECT component_id, COALESCE(name, component_id), application_id, component_type_id, snow_sys_id, description, created_at, updated_at
+  FROM _components_backup;
+DROP TABLE _components_backup;
+
+CREATE TABLE component_workloads (
+  component_id TEXT NOT NULL REFERENCES components(component_id) ON DELETE CASCADE,
+  workload_id TEXT NOT NULL REFERENCES workloads(workload_id) ON DELETE CASCADE,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  PRIMARY KEY (component_id, workload_id)
+);
+CREATE INDEX idx_cw_workload ON component_workloads(workload_id);
+
+INSERT INTO component_workloads SELECT * FROM _cw_backup;
+DROP TABLE _cw_backup;
+
+DROP INDEX IF EXISTS idx_component_types_class;
+ALTER TABLE component_types DROP COLUMN component_class_id;
+DROP TABLE IF EXISTS component_classes;
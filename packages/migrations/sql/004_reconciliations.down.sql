@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reconciliation_items;
+DROP TABLE IF EXISTS reconciliations;
@@ -0,0 +1,32 @@
+-- Illumio ↔ CMDB reconciliation reports
+-- One row per reconciliation run, plus one item per out-of-sync workload
+
+-- ─── Reconciliations ────────────────────────────────────────
+CREATE TABLE reconciliations (
+  reconciliation_id TEXT PRIMARY KEY NOT NULL,
+  source TEXT NOT NULL DEFAULT 'illumio',
+  illumio_count INTEGER NOT NULL DEFAULT 0,
+  cmdb_count INTEGER NOT NULL DEFAULT 0,
+  matched_count INTEGER NOT NULL DEFAULT 0,
+  only_in_illumio_count INTEGER NOT NULL DEFAULT 0,
+  only_in_cmdb_count INTEGER NOT NULL DEFAULT 0,
+  drift_count INTEGER NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+CREATE INDEX idx_reconciliations_created ON reconciliations(created_at);
+
+-- ─── Reconciliation Items ───────────────────────────────────
+-- bucket: only_in_illumio | only_in_cmdb | drift
+-- drift: JSON array of {field, illumio, cmdb} for the drift bucket
+CREATE TABLE reconciliation_items (
+  item_id TEXT PRIMARY KEY NOT NULL,
+  reconciliation_id TEXT NOT NULL REFERENCES reconciliations(reconciliation_id) ON DELETE CASCADE,
+  bucket TEXT NOT NULL,
+  hostname TEXT NOT NULL,
+  workload_id TEXT,
+  illumio_href TEXT,
+  ip_address TEXT,
+  drift TEXT,
+  created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+CREATE INDEX idx_reconciliation_items_run ON reconciliation_items(reconciliation_id, bucket, hostname);
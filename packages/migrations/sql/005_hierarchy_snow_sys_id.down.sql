@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_applications_snow;
+ALTER TABLE applications DROP COLUMN snow_sys_id;
+
+DROP INDEX IF EXISTS idx_app_groupings_snow;
+ALTER TABLE app_groupings DROP COLUMN snow_sys_id;
@@ -0,0 +1,9 @@
+-- Add snow_sys_id to the middle tiers so every level of the BIA hierarchy
+-- can be upserted from ServiceNow by sys_id.
+-- SQLite cannot ADD COLUMN ... UNIQUE, so uniqueness is enforced by index.
+
+ALTER TABLE app_groupings ADD COLUMN snow_sys_id TEXT;
+CREATE UNIQUE INDEX idx_app_groupings_snow ON app_groupings(snow_sys_id);
+
+ALTER TABLE applications ADD COLUMN snow_sys_id TEXT;
+CREATE UNIQUE INDEX idx_applications_snow ON applications(snow_sys_id);
@@ -0,0 +1,21 @@
+-- Workload validation decisions made in the sidepanel
+-- Previously only kept in chrome.storage.local; now shared by the whole team.
+
+-- ─── Workload Validations ───────────────────────────────────
+-- status: pending | validated | edited | no_match
+-- approved_data: JSON payload the analyst approved (NULL for no_match)
+-- no_match_action: what to do with an unmatched workload (e.g. orphan)
+CREATE TABLE workload_validations (
+  validation_id TEXT PRIMARY KEY NOT NULL,
+  workload_id TEXT NOT NULL REFERENCES workloads(workload_id) ON DELETE CASCADE,
+  status TEXT NOT NULL CHECK (status IN ('pending', 'validated', 'edited', 'no_match')),
+  approved_data TEXT,
+  edits_made INTEGER NOT NULL DEFAULT 0,
+  no_match_action TEXT,
+  validated_by TEXT,
+  validated_at TEXT NOT NULL DEFAULT (datetime('now')),
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  updated_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+CREATE INDEX idx_workload_validations_workload ON workload_validations(workload_id, validated_at);
+CREATE INDEX idx_workload_validations_status ON workload_validations(status);
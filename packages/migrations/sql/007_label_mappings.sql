@@ -0,0 +1,17 @@
+-- Illumio label mapping: which CMDB attribute feeds each PCE label key
+-- source is one of the sources known to the API (see labels.Sources),
+-- e.g. asset.name, asset.environment, component_class.label, workload.location
+
+-- ─── Label Mappings ─────────────────────────────────────────
+CREATE TABLE label_mappings (
+  label_key TEXT PRIMARY KEY NOT NULL,
+  source TEXT NOT NULL,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  updated_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+INSERT OR IGNORE INTO label_mappings (label_key, source) VALUES
+  ('app',  'asset.name'),
+  ('env',  'asset.environment'),
+  ('loc',  'workload.location'),
+  ('role', 'component_class.label');
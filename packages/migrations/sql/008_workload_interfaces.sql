@@ -0,0 +1,18 @@
+-- Network interfaces per workload, so dual-homed servers and IPv6
+-- addresses can be recorded and looked up. workloads.ip_address stays as
+-- the primary address; lookups by IP search it and every interface.
+
+-- ─── Workload Interfaces ────────────────────────────────────
+-- family: ipv4 | ipv6
+-- source: where the interface came from (manual | illumio | servicenow)
+CREATE TABLE workload_interfaces (
+  workload_id TEXT NOT NULL REFERENCES workloads(workload_id) ON DELETE CASCADE,
+  address TEXT NOT NULL,
+  family TEXT NOT NULL CHECK (family IN ('ipv4', 'ipv6')),
+  cidr_block INTEGER,
+  name TEXT,
+  source TEXT NOT NULL DEFAULT 'manual',
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  PRIMARY KEY (workload_id, address)
+);
+CREATE INDEX idx_workload_interfaces_address ON workload_interfaces(address);
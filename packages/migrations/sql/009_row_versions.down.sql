@@ -0,0 +1,12 @@
+DROP TRIGGER IF EXISTS trg_portfolios_version;
+ALTER TABLE portfolios DROP COLUMN version;
+DROP TRIGGER IF EXISTS trg_assets_version;
+ALTER TABLE assets DROP COLUMN version;
+DROP TRIGGER IF EXISTS trg_app_groupings_version;
+ALTER TABLE app_groupings DROP COLUMN version;
+DROP TRIGGER IF EXISTS trg_applications_version;
+ALTER TABLE applications DROP COLUMN version;
+DROP TRIGGER IF EXISTS trg_components_version;
+ALTER TABLE components DROP COLUMN version;
+DROP TRIGGER IF EXISTS trg_workloads_version;
+ALTER TABLE workloads DROP COLUMN version;
@@ -0,0 +1,45 @@
+-- Row versions for optimistic concurrency. The API sends the version as
+-- the ETag and honours If-Match on PUT and DELETE. The triggers bump it on
+-- every update, whichever writer makes it (API, syncs, native host).
+
+ALTER TABLE portfolios ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+CREATE TRIGGER trg_portfolios_version AFTER UPDATE ON portfolios
+WHEN NEW.version = OLD.version
+BEGIN
+  UPDATE portfolios SET version = OLD.version + 1 WHERE portfolio_id = NEW.portfolio_id;
+END;
+
+ALTER TABLE assets ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+CREATE TRIGGER trg_assets_version AFTER UPDATE ON assets
+WHEN NEW.version = OLD.version
+BEGIN
+  UPDATE assets SET version = OLD.version + 1 WHERE asset_id = NEW.asset_id;
+END;
+
+ALTER TABLE app_groupings ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+CREATE TRIGGER trg_app_groupings_version AFTER UPDATE ON app_groupings
+WHEN NEW.version = OLD.version
+BEGIN
+  UPDATE app_groupings SET version = OLD.version + 1 WHERE app_grouping_id = NEW.app_grouping_id;
+END;
+
+ALTER TABLE applications ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+CREATE TRIGGER trg_applications_version AFTER UPDATE ON applications
+WHEN NEW.version = OLD.version
+BEGIN
+  UPDATE applications SET version = OLD.version + 1 WHERE application_id = NEW.application_id;
+END;
+
+ALTER TABLE components ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+CREATE TRIGGER trg_components_version AFTER UPDATE ON components
+WHEN NEW.version = OLD.version
+BEGIN
+  UPDATE components SET version = OLD.version + 1 WHERE component_id = NEW.component_id;
+END;
+
+ALTER TABLE workloads ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+CREATE TRIGGER trg_workloads_version AFTER UPDATE ON workloads
+WHEN NEW.version = OLD.version
+BEGIN
+  UPDATE workloads SET version = OLD.version + 1 WHERE workload_id = NEW.workload_id;
+END;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS vocabulary_aliases;
+DROP TABLE IF EXISTS vocabulary_terms;
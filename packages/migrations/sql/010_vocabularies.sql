@@ -0,0 +1,107 @@
+-- Managed vocabularies for assets.criticality, assets.environment,
+-- workloads.environment and workloads.location. Writes through the API
+-- are normalized to a term's value via its aliases (case-insensitively)
+-- and unknown values are rejected.
+
+-- ─── Vocabulary Terms ───────────────────────────────────────
+-- vocabulary: criticality | environment | location
+CREATE TABLE vocabulary_terms (
+  vocabulary TEXT NOT NULL CHECK (vocabulary IN ('criticality', 'environment', 'location')),
+  value TEXT NOT NULL COLLATE NOCASE,
+  label TEXT,
+  color TEXT,
+  sort_order INTEGER NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL DEFAULT (datetime('now')),
+  updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+  PRIMARY KEY (vocabulary, value)
+);
+
+-- ─── Vocabulary Aliases ─────────────────────────────────────
+-- Other spellings that normalize to a term's value.
+CREATE TABLE vocabulary_aliases (
+  vocabulary TEXT NOT NULL,
+  alias TEXT NOT NULL COLLATE NOCASE,
+  value TEXT NOT NULL COLLATE NOCASE,
+  PRIMARY KEY (vocabulary, alias),
+  FOREIGN KEY (vocabulary, value) REFERENCES vocabulary_terms(vocabulary, value) ON DELETE CASCADE
+);
+
+INSERT INTO vocabulary_terms (vocabulary, value, label, color, sort_order) VALUES
+  ('criticality', 'critical', 'Critical', '#dc2626', 1),
+  ('criticality', 'high', 'High', '#ea580c', 2),
+  ('criticality', 'medium', 'Medium', '#ca8a04', 3),
+  ('criticality', 'low', 'Low', '#16a34a', 4),
+  ('environment', 'production', 'Production', '#1d4ed8', 1),
+  ('environment', 'production support', 'Production Support', '#166534', 2),
+  ('environment', 'disaster recovery', 'Disaster Recovery', '#991b1b', 3),
+  ('environment', 'staging', 'Staging', '#7c3aed', 4),
+  ('environment', 'test', 'Test / QA', '#0e7490', 5),
+  ('environment', 'development', 'Development', '#92400e', 6);
+
+INSERT INTO vocabulary_aliases (vocabulary, alias, value) VALUES
+  ('criticality', '1 - most critical', 'critical'),
+  ('criticality', '1', 'critical'),
+  ('criticality', '2 - somewhat critical', 'high'),
+  ('criticality', '2', 'high'),
+  ('criticality', '3 - less critical', 'medium'),
+  ('criticality', '3', 'medium'),
+  ('criticality', '4 - not critical', 'low'),
+  ('criticality', '4', 'low'),
+  ('environment', 'prod', 'production'),
+  ('environment', 'prd', 'production'),
+  ('environment', 'live', 'production'),
+  ('environment', 'prod support', 'production support'),
+  ('environment', 'dr', 'disaster recovery'),
+  ('environment', 'stage', 'staging'),
+  ('environment', 'stg', 'staging'),
+  ('environment', 'preprod', 'staging'),
+  ('environment', 'uat', 'staging'),
+  ('environment', 'test / qa', 'test'),
+  ('environment', 'qa', 'test'),
+  ('environment', 'tst', 'test'),
+  ('environment', 'dev', 'development');
+
+-- ─── Back-fill ──────────────────────────────────────────────
+-- Values already in use that match no term or alias become terms of
+-- their own, so nothing is lost; then every row is rewritten to its
+-- term's value. Blank values become NULL.
+
+INSERT OR IGNORE INTO vocabulary_terms (vocabulary, value)
+SELECT DISTINCT 'criticality', trim(criticality) FROM assets
+WHERE trim(criticality) <> ''
+  AND trim(criticality) COLLATE NOCASE NOT IN (SELECT alias FROM vocabulary_aliases WHERE vocabulary = 'criticality');
+
+INSERT OR IGNORE INTO vocabulary_terms (vocabulary, value)
+SELECT DISTINCT 'environment', trim(environment) FROM assets
+WHERE trim(environment) <> ''
+  AND trim(environment) COLLATE NOCASE NOT IN (SELECT alias FROM vocabulary_aliases WHERE vocabulary = 'environment');
+
+INSERT OR IGNORE INTO vocabulary_terms (vocabulary, value)
+SELECT DISTINCT 'environment', trim(environment) FROM workloads
+WHERE trim(environment) <> ''
+  AND trim(environment) COLLATE NOCASE NOT IN (SELECT alias FROM vocabulary_aliases WHERE vocabulary = 'environment');
+
+INSERT OR IGNORE INTO vocabulary_terms (vocabulary, value)
+SELECT DISTINCT 'location', trim(location) FROM workloads
+WHERE trim(location) <> ''
+  AND trim(location) COLLATE NOCASE NOT IN (SELECT alias FROM vocabulary_aliases WHERE vocabulary = 'location');
+
+UPDATE assets SET criticality = COALESCE(
+  (SELECT value FROM vocabulary_terms WHERE vocabulary = 'criticality' AND value = trim(assets.criticality) COLLATE NOCASE),
+  (SELECT value FROM vocabulary_aliases WHERE vocabulary = 'criticality' AND alias = trim(assets.criticality) COLLATE NOCASE))
+WHERE criticality IS NOT NULL;
+
+UPDATE assets SET environment = COALESCE(
+  (SELECT value FROM vocabulary_terms WHERE vocabulary = 'environment' AND value = trim(assets.environment) COLLATE NOCASE),
+  (SELECT value FROM vocabulary_aliases WHERE vocabulary = 'environment' AND alias = trim(assets.environment) COLLATE NOCASE))
+WHERE environment IS NOT NULL;
+
+UPDATE workloads SET environment = COALESCE(
+  (SELECT value FROM vocabulary_terms WHERE vocabulary = 'environment' AND value = trim(workloads.environment) COLLATE NOCASE),
+  (SELECT value FROM vocabulary_aliases WHERE vocabulary = 'environment' AND alias = trim(workloads.environment) COLLATE NOCASE))
+WHERE environment IS NOT NULL;
+
+UPDATE workloads SET location = COALESCE(
+  (SELECT value FROM vocabulary_terms WHERE vocabulary = 'location' AND value = trim(workloads.location) COLLATE NOCASE),
+  (SELECT value FROM vocabulary_aliases WHERE vocabulary = 'location' AND alias = trim(workloads.location) COLLATE NOCASE))
+WHERE location IS NOT NULL;
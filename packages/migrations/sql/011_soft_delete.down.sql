@@ -0,0 +1,24 @@
+DROP TRIGGER IF EXISTS trg_component_workloads_live_component;
+DROP TRIGGER IF EXISTS trg_assets_live_parent_insert;
+DROP TRIGGER IF EXISTS trg_assets_live_parent_update;
+DROP TRIGGER IF EXISTS trg_app_groupings_live_parent_insert;
+DROP TRIGGER IF EXISTS trg_app_groupings_live_parent_update;
+DROP TRIGGER IF EXISTS trg_applications_live_parent_insert;
+DROP TRIGGER IF EXISTS trg_applications_live_parent_update;
+DROP TRIGGER IF EXISTS trg_components_live_parent_insert;
+DROP TRIGGER IF EXISTS trg_components_live_parent_update;
+DROP INDEX IF EXISTS idx_portfolios_deletion;
+ALTER TABLE portfolios DROP COLUMN deletion_id;
+ALTER TABLE portfolios DROP COLUMN deleted_at;
+DROP INDEX IF EXISTS idx_assets_deletion;
+ALTER TABLE assets DROP COLUMN deletion_id;
+ALTER TABLE assets DROP COLUMN deleted_at;
+DROP INDEX IF EXISTS idx_app_groupings_deletion;
+ALTER TABLE app_groupings DROP COLUMN deletion_id;
+ALTER TABLE app_groupings DROP COLUMN deleted_at;
+DROP INDEX IF EXISTS idx_applications_deletion;
+ALTER TABLE applications DROP COLUMN deletion_id;
+ALTER TABLE applications DROP COLUMN deleted_at;
+DROP INDEX IF EXISTS idx_components_deletion;
+ALTER TABLE components DROP COLUMN deletion_id;
+ALTER TABLE components DROP COLUMN deleted_at;
@@ -0,0 +1,79 @@
+-- Soft deletion for the hierarchy tiers. Deleting a row through the API
+-- stamps deleted_at on it and on everything beneath it, sharing one
+-- deletion_id so a restore brings the whole subtree back together. Rows
+-- in the trash keep their names and sys_ids reserved until purged.
+
+ALTER TABLE portfolios ADD COLUMN deleted_at TEXT;
+ALTER TABLE portfolios ADD COLUMN deletion_id TEXT;
+CREATE INDEX idx_portfolios_deletion ON portfolios(deletion_id);
+
+ALTER TABLE assets ADD COLUMN deleted_at TEXT;
+ALTER TABLE assets ADD COLUMN deletion_id TEXT;
+CREATE INDEX idx_assets_deletion ON assets(deletion_id);
+
+ALTER TABLE app_groupings ADD COLUMN deleted_at TEXT;
+ALTER TABLE app_groupings ADD COLUMN deletion_id TEXT;
+CREATE INDEX idx_app_groupings_deletion ON app_groupings(deletion_id);
+
+ALTER TABLE applications ADD COLUMN deleted_at TEXT;
+ALTER TABLE applications ADD COLUMN deletion_id TEXT;
+CREATE INDEX idx_applications_deletion ON applications(deletion_id);
+
+ALTER TABLE components ADD COLUMN deleted_at TEXT;
+ALTER TABLE components ADD COLUMN deletion_id TEXT;
+CREATE INDEX idx_components_deletion ON components(deletion_id);
+
+-- ─── Live rows need live parents ────────────────────────────
+-- A row outside the trash may not point at a parent in it, whoever
+-- writes it (API, ServiceNow import, native host). The message reads as
+-- a foreign key failure, which is what it amounts to.
+
+CREATE TRIGGER trg_assets_live_parent_insert BEFORE INSERT ON assets
+WHEN NEW.deleted_at IS NULL AND (SELECT deleted_at FROM portfolios WHERE portfolio_id = NEW.portfolio_id) IS NOT NULL
+BEGIN
+  SELECT RAISE(ABORT, 'FOREIGN KEY constraint failed: parent is in the trash');
+END;
+CREATE TRIGGER trg_assets_live_parent_update BEFORE UPDATE OF portfolio_id, deleted_at ON assets
+WHEN NEW.deleted_at IS NULL AND (SELECT deleted_at FROM portfolios WHERE portfolio_id = NEW.portfolio_id) IS NOT NULL
+BEGIN
+  SELECT RAISE(ABORT, 'FOREIGN KEY constraint failed: parent is in the trash');
+END;
+
+CREATE TRIGGER trg_app_groupings_live_parent_insert BEFORE INSERT ON app_groupings
+WHEN NEW.deleted_at IS NULL AND (SELECT deleted_at FROM assets WHERE asset_id = NEW.asset_id) IS NOT NULL
+BEGIN
+  SELECT RAISE(ABORT, 'FOREIGN KEY constraint failed: parent is in the trash');
+END;
+CREATE TRIGGER trg_app_groupings_live_parent_update BEFORE UPDATE OF asset_id, deleted_at ON app_groupings
+WHEN NEW.deleted_at IS NULL AND (SELECT deleted_at FROM assets WHERE asset_id = NEW.asset_id) IS NOT NULL
+BEGIN
+  SELECT RAISE(ABORT, 'FOREIGN KEY constraint failed: parent is in the trash');
+END;
+
+CREATE TRIGGER trg_applications_live_parent_insert BEFORE INSERT ON applications
+WHEN NEW.deleted_at IS NULL AND (SELECT deleted_at FROM app_groupings WHERE app_grouping_id = NEW.app_grouping_id) IS NOT NULL
+BEGIN
+  SELECT RAISE(ABORT, 'FOREIGN KEY constraint failed: parent is in the trash');
+END;
+CREATE TRIGGER trg_applications_live_parent_update BEFORE UPDATE OF app_grouping_id, deleted_at ON applications
+WHEN NEW.deleted_at IS NULL AND (SELECT deleted_at FROM app_groupings WHERE app_grouping_id = NEW.app_grouping_id) IS NOT NULL
+BEGIN
+  SELECT RAISE(ABORT, 'FOREIGN KEY constraint failed: parent is in the trash');
+END;
+
+CREATE TRIGGER trg_components_live_parent_insert BEFORE INSERT ON components
+WHEN NEW.deleted_at IS NULL AND (SELECT deleted_at FROM applications WHERE application_id = NEW.application_id) IS NOT NULL
+BEGIN
+  SELECT RAISE(ABORT, 'FOREIGN KEY constraint failed: parent is in the trash');
+END;
+CREATE TRIGGER trg_components_live_parent_update BEFORE UPDATE OF application_id, deleted_at ON components
+WHEN NEW.deleted_at IS NULL AND (SELECT deleted_at FROM applications WHERE application_id = NEW.application_id) IS NOT NULL
+BEGIN
+  SELECT RAISE(ABORT, 'FOREIGN KEY constraint failed: parent is in the trash');
+END;
+
+CREATE TRIGGER trg_component_workloads_live_component BEFORE INSERT ON component_workloads
+WHEN (SELECT deleted_at FROM components WHERE component_id = NEW.component_id) IS NOT NULL
+BEGIN
+  SELECT RAISE(ABORT, 'FOREIGN KEY constraint failed: component is in the trash');
+END;
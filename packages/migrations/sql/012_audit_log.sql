@@ -0,0 +1,27 @@
+-- Change history for auditors. The API writes one entry per change in the
+-- same transaction as the change itself; entries are never edited.
+
+-- ─── Audit Log ──────────────────────────────────────────────
+-- entity / entity_id: the row changed (entity_id is NULL for batch imports)
+-- action: create | update | delete | link | unlink | restore | purge | import
+-- related_entity / related_id: a second row the change concerns, e.g. the
+--   workload of a link, so the entry also shows in that row's history
+-- before_data / after_data: the row as JSON before and after the change
+-- actor: the caller's X-Actor header; request_id: its X-Request-ID
+CREATE TABLE audit_log (
+  audit_id INTEGER PRIMARY KEY AUTOINCREMENT,
+  entity TEXT NOT NULL,
+  entity_id TEXT,
+  action TEXT NOT NULL,
+  related_entity TEXT,
+  related_id TEXT,
+  before_data TEXT,
+  after_data TEXT,
+  actor TEXT,
+  request_id TEXT,
+  created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id);
+CREATE INDEX idx_audit_log_related ON audit_log(related_entity, related_id);
+CREATE INDEX idx_audit_log_request ON audit_log(request_id);
+CREATE INDEX idx_audit_log_created ON audit_log(created_at);
@@ -0,0 +1,3 @@
+-- The addresses' written forms are not kept, and the canonical ones are
+-- valid before 013 too: nothing to undo.
+SELECT 1;
@@ -0,0 +1,16 @@
+-- Workload primary addresses in canonical form. The API now stores
+-- ip_address as lookups search for it: no surrounding space, IPv6 in
+-- lower case and IPv4-mapped IPv6 as plain IPv4. This rewrites what SQL
+-- can of the addresses stored before; one it cannot, such as a
+-- zero-padded IPv6 address, is still matched as written.
+
+UPDATE workloads SET ip_address = trim(ip_address)
+WHERE ip_address <> trim(ip_address);
+
+UPDATE workloads SET ip_address = NULL WHERE ip_address = '';
+
+UPDATE workloads SET ip_address = lower(ip_address)
+WHERE ip_address LIKE '%:%' AND ip_address <> lower(ip_address);
+
+UPDATE workloads SET ip_address = substr(ip_address, 8)
+WHERE ip_address LIKE '::ffff:%.%.%.%';
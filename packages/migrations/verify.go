@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Problem is an applied migration that no longer matches its file.
+type Problem struct {
+	Name     string `json:"name"`
+	Issue    string `json:"issue"` // modified | missing | unrecorded
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// Verify compares every applied migration with the embedded files:
+// modified means the file changed after it was applied, missing means the
+// file is gone, and unrecorded means it was applied before checksums were
+// tracked, so whether the file still matches is unknown until Accept
+// records it. Pending migrations are not problems.
+func Verify(db *sql.DB) ([]Problem, error) {
+	applied, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	for _, m := range applied {
+		sum, err := Checksum(m.Name)
+		switch {
+		case err != nil:
+			problems = append(problems, Problem{Name: m.Name, Issue: "missing", Expected: m.Checksum})
+		case m.Checksum == "":
+			problems = append(problems, Problem{Name: m.Name, Issue: "unrecorded", Actual: sum})
+		case m.Checksum != sum:
+			problems = append(problems, Problem{Name: m.Name, Issue: "modified", Expected: m.Checksum, Actual: sum})
+		}
+	}
+	return problems, nil
+}
+
+// Accept records the current checksum of every migration Verify reports
+// as unrecorded, vouching that each file still matches what was applied.
+// Run it once after upgrading a database from before checksums were
+// tracked, having checked its schema; it returns the names it recorded.
+// Modified and missing migrations are left for Verify to report.
+func Accept(db *sql.DB) ([]string, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+	problems, err := Verify(db)
+	if err != nil {
+		return nil, err
+	}
+	var accepted []string
+	for _, p := range problems {
+		if p.Issue != "unrecorded" {
+			continue
+		}
+		if _, err := db.Exec("UPDATE migrations SET checksum = ? WHERE name = ? AND checksum IS NULL", p.Actual, p.Name); err != nil {
+			return accepted, fmt.Errorf("record %s: %w", p.Name, err)
+		}
+		accepted = append(accepted, p.Name)
+	}
+	return accepted, nil
+}